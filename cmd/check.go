@@ -2,8 +2,17 @@ package cmd
 
 import (
 	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/charmbracelet/x/term"
+	"github.com/mattn/go-runewidth"
+
 	"github.com/naru-bot/upp/internal/checker"
 	"github.com/naru-bot/upp/internal/config"
 	"github.com/naru-bot/upp/internal/db"
@@ -21,17 +30,59 @@ func init() {
 Without arguments, checks all targets. With an argument, checks only the specified target.
 Use --tag to check only targets with a specific tag.
 
+On a terminal, a progress line shows how many targets are done and which one
+is being checked. It is left out with --quiet, --json or when output is piped.
+
+With --count and/or --interval, one target is checked repeatedly, every
+--interval (default 5s) regardless of its stored interval, and each result
+is printed as a row with its time and response time. Results are saved to
+history as usual, but no notifications are sent while watching.
+
+--watch checks one target until Ctrl+C (or --count checks) and, on a
+terminal, keeps a single status line updated in place instead of adding a
+row per check: the status, code and response time of the latest check and
+how long the target has been up or down. Ctrl+C prints the summary. It is
+a foreground monitor for a quick look, not a replacement for the daemon.
+
+Exit codes (stable, for CI gating):
+  0  all checked targets are up (or there was nothing to check)
+  1  some targets are down
+  2  usage or configuration error; no checks were run
+  3  all checked targets are down
+
+--format script prints one tab-separated line per target for shell
+pipelines: the name, the status and the exit code checking that target
+alone gives (0 up, 3 down). Nothing else is printed, so the lines can go
+straight to awk, cut or grep. The columns are stable; new ones will only
+ever be appended.
+
 Examples:
   upp check
   upp check "My Site"
   upp check https://example.com
-  upp check --tag my-sites`,
+  upp check --tag my-sites
+  upp check api --interval 5 --count 12   # watch a deploy roll out
+  upp check api --watch --interval 10
+  upp check --format script | awk -F'\t' '$3 != 0 { print $1 }'`,
 		Run: runCheck,
 	}
 	cmd.Flags().String("tag", "", "Only check targets with this tag")
+	cmd.Flags().IntP("count", "c", 0, "Check one target this many times and print the series (0 with --interval: until Ctrl+C)")
+	cmd.Flags().StringP("interval", "i", "5s", "Spacing between checks with --count or --watch (e.g. 5, 30s, 1m; bare numbers are seconds)")
+	cmd.Flags().BoolP("watch", "w", false, "Check one target repeatedly, updating one status line in place until Ctrl+C")
+	cmd.Flags().String("format", "text", "Output format: text, json, script (name<TAB>status<TAB>code lines)")
 	rootCmd.AddCommand(cmd)
 }
 
+// Exit codes of the check command. Pipelines depend on them, so they must
+// not be renumbered.
+const (
+	exitAllUp    = 0
+	exitSomeDown = 1
+	exitUsage    = 2
+	exitAllDown  = 3
+)
+
 type checkOutput struct {
 	Target       string `json:"target"`
 	URL          string `json:"url"`
@@ -39,111 +90,163 @@ type checkOutput struct {
 	StatusCode   int    `json:"status_code,omitempty"`
 	ResponseMs   int64  `json:"response_time_ms"`
 	ContentHash  string `json:"content_hash,omitempty"`
+	ContentType  string `json:"content_type,omitempty"`
 	Changed      bool   `json:"changed"`
 	Triggered    *bool  `json:"triggered,omitempty"`
+	CertChanged  bool   `json:"cert_changed,omitempty"`
+	RemoteIP     string `json:"remote_ip,omitempty"`
+	IPChanged    bool   `json:"ip_changed,omitempty"`
 	Error        string `json:"error,omitempty"`
 	SSLDaysLeft  *int   `json:"ssl_days_left,omitempty"`
+	Score        *int   `json:"score,omitempty"`
+}
+
+// newCheckOutput is the reported form of a check result.
+func newCheckOutput(t *db.Target, result *checker.Result) checkOutput {
+	out := checkOutput{
+		Target:      t.Name,
+		URL:         t.URL,
+		Status:      result.Status,
+		StatusCode:  result.StatusCode,
+		ResponseMs:  result.ResponseTime.Milliseconds(),
+		ContentHash: result.ContentHash,
+		ContentType: result.ContentType,
+		Changed:     result.Status == "changed",
+		CertChanged: result.CertChanged,
+		RemoteIP:    result.RemoteIP,
+		IPChanged:   result.IPChanged,
+		Error:       result.Error,
+		Score:       result.Score,
+	}
+	if result.SSLExpiry != nil {
+		days := int(time.Until(*result.SSLExpiry).Hours() / 24)
+		out.SSLDaysLeft = &days
+	}
+	return out
 }
 
 func runCheck(cmd *cobra.Command, args []string) {
 	var targets []db.Target
 
 	tag, _ := cmd.Flags().GetString("tag")
+	format, _ := cmd.Flags().GetString("format")
+	switch format {
+	case "text":
+	case "json":
+		jsonOutput = true
+	case "script":
+		if jsonOutput {
+			exitErrorCode("--format script can't be combined with --json", exitUsage)
+		}
+	default:
+		exitErrorCode(fmt.Sprintf("unknown --format %q (want text, json or script)", format), exitUsage)
+	}
+	script := format == "script"
+	if watch, _ := cmd.Flags().GetBool("watch"); watch || cmd.Flags().Changed("count") || cmd.Flags().Changed("interval") {
+		runCheckSeries(cmd, args, tag, script)
+		return
+	}
 	if len(args) > 0 {
 		t, err := db.GetTarget(args[0])
 		if err != nil {
-			exitError(err.Error())
+			exitErrorCode(err.Error(), exitUsage)
 		}
 		targets = []db.Target{*t}
 	} else if tag != "" {
 		var err error
 		targets, err = db.ListTargetsByTag(tag)
 		if err != nil {
-			exitError(err.Error())
+			exitErrorCode(err.Error(), exitUsage)
 		}
 	} else {
 		var err error
 		targets, err = db.ListTargets()
 		if err != nil {
-			exitError(err.Error())
+			exitErrorCode(err.Error(), exitUsage)
 		}
 	}
 
 	if len(targets) == 0 {
 		if jsonOutput {
 			printJSON([]interface{}{})
-		} else {
+		} else if !script {
 			fmt.Println("No targets to check. Use 'upp add <url>' first.")
 		}
 		return
 	}
 
+	// Composites roll up their members' latest results, so check the
+	// members first
+	rank := func(t db.Target) int {
+		if t.Type == "composite" {
+			return 1
+		}
+		return 0
+	}
+	slices.SortStableFunc(targets, func(a, b db.Target) int {
+		return rank(a) - rank(b)
+	})
+
 	var outputs []checkOutput
 
+	// With notify_on_startup, a run over every target ends in one summary
+	// instead of alerting again for targets that were already down
+	summarize := config.Get().NotifyOnStartup && len(args) == 0 && tag == ""
+	var wasDown map[int64]bool
+	if summarize {
+		_, wasDown = stateSummary(targets)
+	}
+
+	// The in-place progress line only makes sense on a terminal; piped
+	// output gets just the result lines.
+	showProgress := !jsonOutput && !script && !quiet && stdoutIsTerminal()
+	active := 0
+	for _, t := range targets {
+		if !t.Paused {
+			active++
+		}
+	}
+
+	done := 0
 	for _, t := range targets {
 		if t.Paused {
 			continue
 		}
 
-		if !jsonOutput && !quiet {
-			fmt.Printf("  ⟳ Checking %s...\r", t.Name)
+		if showProgress {
+			if active > 1 {
+				fmt.Printf("\r\033[K  ⟳ [%d/%d] Checking %s...", done+1, active, t.Name)
+			} else {
+				fmt.Printf("\r\033[K  ⟳ Checking %s...", t.Name)
+			}
 		}
 
-		result := checker.Check(&t)
+		result := checker.Check(cmd.Context(), &t)
 
 		// Save check result
-		cr := &db.CheckResult{
-			TargetID:     t.ID,
-			Status:       result.Status,
-			StatusCode:   result.StatusCode,
-			ResponseTime: result.ResponseTime.Milliseconds(),
-			ContentHash:  result.ContentHash,
-			Error:        result.Error,
+		if err := db.SaveCheckResult(result.Record(t.ID)); err != nil {
+			slog.Error("saving check result failed", "target", t.Name, "err", err)
 		}
-		db.SaveCheckResult(cr)
 
 		// Save snapshot if content available
-		if result.Content != "" && result.ContentHash != "" {
-			snaps, _ := db.GetLatestSnapshots(t.ID, 1)
-			if len(snaps) == 0 || snaps[0].Hash != result.ContentHash {
-				db.SaveSnapshot(t.ID, result.Content, result.ContentHash)
-			}
+		if err := saveSnapshot(&t, result); err != nil {
+			slog.Error("saving snapshot failed", "target", t.Name, "err", err)
 		}
 
-		out := checkOutput{
-			Target:      t.Name,
-			URL:         t.URL,
-			Status:      result.Status,
-			StatusCode:  result.StatusCode,
-			ResponseMs:  result.ResponseTime.Milliseconds(),
-			ContentHash: result.ContentHash,
-			Changed:     result.Status == "changed",
-			Error:       result.Error,
-		}
-
-		if result.SSLExpiry != nil {
-			days := int(time.Until(*result.SSLExpiry).Hours() / 24)
-			out.SSLDaysLeft = &days
-		}
+		out := newCheckOutput(&t, result)
 
+		// A target that was down before this run is left to the summary
+		out.Triggered = notifyResult(&t, result, wasDown[t.ID])
 		outputs = append(outputs, out)
+		done++
 
-		// Evaluate trigger rule and send notifications
-		if result.Status == "down" || result.Status == "changed" || result.Status == "error" {
-			shouldNotify := true
-			if t.TriggerRule != "" {
-				triggered, _ := trigger.Evaluate(t.TriggerRule, result.Content)
-				shouldNotify = triggered
-				out.Triggered = &triggered
+		if script {
+			printScriptLine(out)
+		} else if !jsonOutput {
+			if showProgress {
+				// Clear the progress line
+				fmt.Printf("\r\033[K")
 			}
-			if shouldNotify {
-				sendNotifications(t.Name, t.URL, result.Status, result.Error)
-			}
-		}
-
-		if !jsonOutput {
-			// Clear the "checking" line
-			fmt.Printf("\r\033[K")
 
 			icon := statusIcon(result.Status)
 			statusText := result.Status
@@ -152,17 +255,8 @@ func runCheck(cmd *cobra.Command, args []string) {
 			respText := fmt.Sprintf("[%dms]", result.ResponseTime.Milliseconds())
 
 			if !noColor {
-				switch result.Status {
-				case "up", "unchanged":
-					icon = colorGreen(icon)
-					statusText = colorGreen(statusText)
-				case "changed":
-					icon = colorYellow(icon)
-					statusText = colorYellow(statusText)
-				case "down", "error":
-					icon = colorRed(icon)
-					statusText = colorRed(statusText)
-				}
+				icon = colorStatus(result.Status, icon)
+				statusText = colorStatus(result.Status, statusText)
 				nameText = colorBold(t.Name)
 				urlText = colorCyan(fmt.Sprintf("(%s)", t.URL))
 			}
@@ -172,7 +266,7 @@ func runCheck(cmd *cobra.Command, args []string) {
 			if result.Error != "" {
 				errText := result.Error
 				if !noColor {
-					errText = colorRed(errText)
+					errText = colorStatus("down", errText)
 				}
 				fmt.Printf(" (%s)", errText)
 			}
@@ -191,51 +285,504 @@ func runCheck(cmd *cobra.Command, args []string) {
 					fmt.Printf(" %s", sslText)
 				}
 			}
+			if result.CertChanged {
+				certText := "[cert changed]"
+				if !noColor {
+					certText = colorYellow(certText)
+				}
+				fmt.Printf(" %s", certText)
+			}
+			if result.IPChanged {
+				ipText := fmt.Sprintf("[IP changed: %s → %s]", result.PrevRemoteIP, result.RemoteIP)
+				if !noColor {
+					ipText = colorYellow(ipText)
+				}
+				fmt.Printf(" %s", ipText)
+			}
 			fmt.Println()
 		}
 	}
 
+	if summarize {
+		summary, _ := stateSummary(targets)
+		sendSummary(summary)
+	}
+
 	if jsonOutput {
 		printJSON(outputs)
 	}
+	if code := checkExitCode(outputs); code != exitAllUp {
+		os.Exit(code)
+	}
+}
+
+// checkSeriesOutput is one row of a repeated check.
+type checkSeriesOutput struct {
+	checkOutput
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// runCheckSeries checks one target count times (until interrupted when
+// count is 0), spaced by --interval, printing a row per result and a
+// summary at the end. The stored interval is left alone and notifications
+// are not sent.
+func runCheckSeries(cmd *cobra.Command, args []string, tag string, script bool) {
+	count, _ := cmd.Flags().GetInt("count")
+	intervalStr, _ := cmd.Flags().GetString("interval")
+	watch, _ := cmd.Flags().GetBool("watch")
+	if len(args) != 1 || tag != "" {
+		exitErrorCode("--count, --interval and --watch need exactly one target (and no --tag)", exitUsage)
+	}
+	if watch && (jsonOutput || script) {
+		exitErrorCode("--watch can't be combined with --json or --format script", exitUsage)
+	}
+	if count < 0 {
+		exitErrorCode(fmt.Sprintf("--count must be 0 or more, got %d", count), exitUsage)
+	}
+	secs, err := parseSeconds(intervalStr)
+	if err != nil {
+		exitErrorCode("invalid --interval: "+err.Error(), exitUsage)
+	}
+	interval := time.Duration(secs) * time.Second
+	t, err := db.GetTarget(args[0])
+	if err != nil {
+		exitErrorCode(err.Error(), exitUsage)
+	}
+
+	// Ctrl+C ends the series early; what ran so far is still summarized
+	ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Piped, --watch prints rows as a plain series does; a line redrawn
+	// with \r only makes sense on a terminal
+	live := watch && stdoutIsTerminal()
+	var streak watchStreak
+	if !jsonOutput && !script {
+		runs := "until Ctrl+C"
+		if count > 0 {
+			runs = fmt.Sprintf("%d checks", count)
+		}
+		fmt.Printf("Checking %s (%s) every %s, %s\n\n", t.Name, t.URL, formatSeconds(secs), runs)
+		if !live {
+			fmt.Printf("%4s  %-8s  %-12s  %4s  %8s  %s\n", "#", "TIME", "STATUS", "CODE", "RESPONSE", "ERROR")
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var rows []checkSeriesOutput
+	for i := 0; count == 0 || i < count; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+			case <-ticker.C:
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		checkedAt := time.Now()
+		result := checker.Check(ctx, t)
+		if ctx.Err() != nil {
+			break // interrupted mid-check; don't record a spurious failure
+		}
+		if err := db.SaveCheckResult(result.Record(t.ID)); err != nil {
+			slog.Error("saving check result failed", "target", t.Name, "err", err)
+		}
+		if err := saveSnapshot(t, result); err != nil {
+			slog.Error("saving snapshot failed", "target", t.Name, "err", err)
+		}
+		row := checkSeriesOutput{checkOutput: newCheckOutput(t, result), CheckedAt: checkedAt}
+		rows = append(rows, row)
+
+		if script {
+			printScriptLine(row.checkOutput)
+		} else if live {
+			streak.add(row.Status, checkedAt)
+			fmt.Printf("\r\033[K%s", watchLine(row, checkedAt, streak, len(rows)))
+		} else if !jsonOutput {
+			status := fmt.Sprintf("%s %-10s", statusIcon(row.Status), row.Status)
+			code := "—"
+			if row.StatusCode != 0 {
+				code = fmt.Sprint(row.StatusCode)
+			}
+			fmt.Printf("%4d  %-8s  %s  %4s  %6dms  %s\n", i+1, formatTime(checkedAt, time.TimeOnly),
+				colorStatus(row.Status, status), code, row.ResponseMs, truncateStr(row.Error, config.Get().ErrorWidth()))
+		}
+	}
+
+	outputs := make([]checkOutput, len(rows))
+	for i, r := range rows {
+		outputs[i] = r.checkOutput
+	}
+	if live && len(rows) > 0 {
+		fmt.Println()
+	}
+	if jsonOutput {
+		printJSON(rows)
+	} else if len(rows) > 0 && !script {
+		var up int
+		var total, lo, hi int64
+		for i, o := range outputs {
+			if o.Status != "down" && o.Status != "error" {
+				up++
+			}
+			total += o.ResponseMs
+			if i == 0 || o.ResponseMs < lo {
+				lo = o.ResponseMs
+			}
+			hi = max(hi, o.ResponseMs)
+		}
+		fmt.Printf("\n--- %s: %d checks, %d up, avg %dms, min %dms, max %dms\n",
+			t.Name, len(rows), up, total/int64(len(rows)), lo, hi)
+	}
+	if code := checkExitCode(outputs); code != exitAllUp {
+		os.Exit(code)
+	}
+}
+
+// watchStreak is how long a watched target has been up or down: the run of
+// latest checks on the same side.
+type watchStreak struct {
+	down  bool
+	since time.Time
+	count int
+}
+
+// add extends the streak with a check, or starts a new one when the check
+// is on the other side.
+func (s *watchStreak) add(status string, at time.Time) {
+	down := status == "down" || status == "error"
+	if s.count == 0 || down != s.down {
+		*s = watchStreak{down: down, since: at}
+	}
+	s.count++
+}
+
+// String describes the streak, e.g. "up for 4m (25 checks)".
+func (s watchStreak) String() string {
+	side := "up"
+	if s.down {
+		side = "down"
+	}
+	return fmt.Sprintf("%s for %s (%d check%s)", side, humanizeDuration(time.Since(s.since)), s.count, pluralize(s.count))
 }
 
-func statusIcon(status string) string {
-	switch status {
-	case "up", "unchanged":
-		return "✓"
-	case "changed":
-		return "△"
-	case "down":
-		return "✗"
+// watchLine is the status line --watch redraws after each check. The error
+// is cut to keep the line on one terminal row, since \r can't return to a
+// row the line wrapped from.
+func watchLine(row checkSeriesOutput, at time.Time, streak watchStreak, checks int) string {
+	code := "—"
+	if row.StatusCode != 0 {
+		code = fmt.Sprint(row.StatusCode)
+	}
+	line := fmt.Sprintf("%s %s  %s  %dms  %s  #%d at %s", statusIcon(row.Status), row.Status, code,
+		row.ResponseMs, streak, checks, formatTime(at, time.TimeOnly))
+	line = colorStatus(row.Status, line)
+	if row.Error == "" {
+		return line
+	}
+	errText := "  " + row.Error
+	if width, _, err := term.GetSize(os.Stdout.Fd()); err == nil && width > 0 {
+		room := width - 1 - runewidth.StringWidth(ansiEscape.ReplaceAllString(line, ""))
+		if room < 8 {
+			return line
+		}
+		errText = runewidth.Truncate(errText, room, "...")
+	}
+	return line + colorStatus("down", errText)
+}
+
+// checkExitCode maps check results to the command's exit code. "down" and
+// "error" count as down; changed and unchanged content are up.
+func checkExitCode(outputs []checkOutput) int {
+	down := 0
+	for _, o := range outputs {
+		if o.Status == "down" || o.Status == "error" {
+			down++
+		}
+	}
+	switch {
+	case down == 0:
+		return exitAllUp
+	case down == len(outputs):
+		return exitAllDown
 	default:
-		return "?"
+		return exitSomeDown
 	}
 }
 
-func sendNotifications(target, url, status, errMsg string) {
-	configs, err := db.ListNotifyConfigs()
-	if err != nil || len(configs) == 0 {
+// scriptFieldReplacer keeps a --format script field on one line and in one
+// column.
+var scriptFieldReplacer = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+
+// printScriptLine prints a result as a --format script line: name, status
+// and the exit code checking that target alone would give, tab-separated.
+func printScriptLine(o checkOutput) {
+	fmt.Printf("%s\t%s\t%d\n", scriptFieldReplacer.Replace(o.Target), o.Status, checkExitCode([]checkOutput{o}))
+}
+
+// saveSnapshot stores the content of a check result as the target's
+// snapshot_mode asks: by default only when it differs from the latest
+// snapshot.
+func saveSnapshot(t *db.Target, r *checker.Result) error {
+	if r.Content == "" || r.ContentHash == "" {
+		return nil
+	}
+	latest, _ := db.GetLatestSnapshot(t.ID)
+	if !checker.WantSnapshot(t, r, latest, checker.DefaultSettings()) {
+		return nil
+	}
+	return db.SaveSnapshot(t.ID, r.Content, r.ContentHash, checker.ContentSignature(r.Content))
+}
+
+// notifyResult sends the notifications a check result calls for. Down,
+// changed and error results go through the target's trigger rule; a
+// certificate change on an alert_cert_change target, or an IP change on an
+// alert_on_ip_change target, always notifies. The trigger outcome is
+// returned when a rule was evaluated. Muted targets never evaluate
+// triggers or notify. Targets with an escalation policy
+// alert through its steps instead of every channel; notify_on_recovery
+// targets announce the end of an outage. A "redirect" result notifies
+// once, when the target starts redirecting. A flapping target alerts that
+// it is flapping instead of each time it goes down or recovers. An
+// alert_on_regression target alerts when a check turns much slower than
+// its recent median. announced marks a target a summary already reported
+// down: it isn't alerted again while it stays down, but
+// every other notification goes out as usual.
+func notifyResult(t *db.Target, r *checker.Result, announced bool) *bool {
+	if t.Muted {
+		return nil
+	}
+	flapping := notifyFlapping(t, r)
+	var triggered *bool
+	if r.Status == "down" || r.Status == "changed" || r.Status == "error" {
+		shouldNotify := true
+		if t.TriggerRule != "" {
+			ok, _ := trigger.EvaluateChange(t.TriggerRule, r.Content, r.PrevContent)
+			shouldNotify = ok
+			triggered = &ok
+		}
+		if flapping && r.Status != "changed" {
+			shouldNotify = false
+		}
+		if announced && r.Status != "changed" {
+			slog.Debug("still down since the summary, not alerting again", "target", t.Name)
+			shouldNotify = false
+		}
+		if shouldNotify {
+			if t.Escalation != "" && r.Status != "changed" {
+				escalate(t, resultEvent(t, r))
+			} else {
+				notifyTarget(t, resultEvent(t, r))
+			}
+		}
+	}
+	if r.Status != "down" && r.Status != "error" {
+		if t.Escalation != "" {
+			resolveEscalation(t, r)
+		} else {
+			notifyRecovery(t, r, flapping)
+		}
+		notifyRegression(t, r)
+	}
+	// A redirect alerts when it starts, not on every check while it lasts
+	if r.Status == "redirect" {
+		if ev := resultEvent(t, r); ev.PrevStatus != "redirect" {
+			notifyTarget(t, ev)
+		}
+	}
+	if r.CertChanged {
+		notifyTarget(t, targetEvent(t, "cert-changed", fmt.Sprintf("certificate changed: %s → %s",
+			checker.ShortFingerprint(r.PrevCertFingerprint), checker.ShortFingerprint(r.CertFingerprint))))
+	}
+	if r.IPChanged {
+		notifyTarget(t, targetEvent(t, "ip-changed", fmt.Sprintf("IP changed: %s → %s", r.PrevRemoteIP, r.RemoteIP)))
+	}
+	return triggered
+}
+
+// notifyRecovery sends a recovered notification, with the downtime, when the
+// result just saved ends a run of down or error results. Targets without
+// notify_on_recovery, and all targets when closingOnly is set, only tell
+// the channels that close alerts on it.
+func notifyRecovery(t *db.Target, r *checker.Result, closingOnly bool) {
+	var closing []string
+	if closingOnly || !t.NotifyOnRecovery {
+		if closing = alertClosingChannels(t); len(closing) == 0 {
+			return
+		}
+	}
+	ev := resultEvent(t, r)
+	if ev.PrevStatus != "down" && ev.PrevStatus != "error" {
+		return
+	}
+	slog.Info("target recovered", "target", t.Name, "down_for", ev.Downtime)
+	ev.Status = "recovered"
+	ev.Error = "back up after " + ev.Downtime
+	if closing != nil {
+		sendNotificationsTo(closing, ev)
 		return
 	}
+	notifyTarget(t, ev)
+}
+
+// alertClosingChannels returns the target's enabled channels that keep an
+// alert open until the target recovers.
+func alertClosingChannels(t *db.Target) []string {
+	configs, err := db.ListNotifyConfigs()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, c := range configs {
+		if c.Enabled && notify.ClosesAlerts(c.Type) && (len(t.Channels) == 0 || slices.Contains(t.Channels, c.Name)) {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
 
-	msg := fmt.Sprintf("[upp] %s (%s) is %s", target, url, status)
-	if errMsg != "" {
-		msg += ": " + errMsg
+// targetEvent starts a notification event about t.
+func targetEvent(t *db.Target, status, errMsg string) notify.Event {
+	tags, _ := db.GetTags(t.ID)
+	return notify.Event{
+		Target:   t.Name,
+		URL:      t.URL,
+		Type:     t.Type,
+		Status:   status,
+		Error:    previewInline(errMsg),
+		Tags:     tags,
+		Severity: notify.EffectiveSeverity(t.Severity),
+		Meta:     t.Meta,
+		Time:     time.Now().UTC().Format(time.RFC3339),
 	}
+}
 
-	event := notify.Event{
-		Target:  target,
-		URL:     url,
-		Status:  status,
-		Error:   errMsg,
-		Time:    time.Now().UTC().Format(time.RFC3339),
-		Message: msg,
+// resultEvent is the notification event for a saved check result, with the
+// previous status and how long the outage the result extends or ends has
+// lasted.
+func resultEvent(t *db.Target, r *checker.Result) notify.Event {
+	ev := targetEvent(t, r.Status, r.Error)
+	ev.StatusCode = r.StatusCode
+	ev.ResponseMs = r.ResponseTime.Milliseconds()
+	prev, downFor := recentOutage(t.ID)
+	ev.PrevStatus = prev
+	if downFor > 0 {
+		ev.Downtime = humanizeDuration(downFor)
+	}
+	return ev
+}
+
+// recentOutage looks back from the target's latest result. prev is the
+// status of the result before it; downFor is how long the run of down or
+// error results that includes the latest result, or ends just before it,
+// lasted up to the latest result.
+func recentOutage(targetID int64) (prev string, downFor time.Duration) {
+	const pageSize = 100
+	var latest, downSince time.Time
+	outage := func() time.Duration {
+		if downSince.IsZero() {
+			return 0
+		}
+		return latest.Sub(downSince)
+	}
+	for offset := 0; ; offset += pageSize {
+		results, _, err := db.GetCheckHistoryPage(targetID, db.Page{Limit: pageSize, Offset: offset})
+		if err != nil {
+			return prev, 0
+		}
+		for i, r := range results {
+			failed := r.Status == "down" || r.Status == "error"
+			switch offset + i {
+			case 0:
+				latest = r.CheckedAt
+				if failed {
+					downSince = r.CheckedAt
+				}
+				continue
+			case 1:
+				prev = r.Status
+			}
+			if !failed {
+				return prev, outage()
+			}
+			downSince = r.CheckedAt
+		}
+		if len(results) < pageSize {
+			return prev, outage()
+		}
 	}
+}
 
+// stateSummary is the summary event describing the latest results of the
+// unpaused targets, e.g. "18 up, 2 down: api, db". failing holds the
+// targets whose latest result is down or error.
+func stateSummary(targets []db.Target) (summary notify.Event, failing map[int64]bool) {
+	failing = make(map[int64]bool)
+	var up, unchecked int
+	var down []string
+	for _, t := range targets {
+		if t.Paused {
+			continue
+		}
+		last, err := db.GetCheckHistory(t.ID, 1)
+		if err != nil || len(last) == 0 {
+			unchecked++
+			continue
+		}
+		switch last[0].Status {
+		case "down", "error":
+			failing[t.ID] = true
+			down = append(down, t.Name)
+		default:
+			up++
+		}
+	}
+	text := fmt.Sprintf("%d up, %d down", up, len(down))
+	if len(down) > 0 {
+		text += ": " + strings.Join(down, ", ")
+	}
+	if unchecked > 0 {
+		text += fmt.Sprintf(" (%d not checked yet)", unchecked)
+	}
+	summary = notify.Event{Status: "summary", Summary: text, Up: up, Down: down, Unchecked: unchecked}
+	return summary, failing
+}
+
+// sendSummary sends a summary event to every enabled channel.
+func sendSummary(summary notify.Event) {
+	slog.Info("sending summary notification", "summary", summary.Summary)
+	summary.Time = time.Now().UTC().Format(time.RFC3339)
+	sendNotifications(summary)
+}
+
+// notifyTarget notifies the channels the target names, or every enabled
+// channel when it names none.
+func notifyTarget(t *db.Target, ev notify.Event) {
+	if len(t.Channels) == 0 {
+		sendNotifications(ev)
+		return
+	}
+	sendNotificationsTo(t.Channels, ev)
+}
+
+func sendNotifications(ev notify.Event) {
+	sendNotificationsTo(nil, ev)
+}
+
+// sendNotificationsTo notifies the named channels, or every enabled channel
+// when names is nil.
+func sendNotificationsTo(names []string, ev notify.Event) {
+	configs, err := db.ListNotifyConfigs()
+	if err != nil || len(configs) == 0 {
+		return
+	}
 	for _, c := range configs {
-		if c.Enabled {
-			notify.Send(c.Type, c.Config, event)
+		if c.Enabled && (names == nil || slices.Contains(names, c.Name)) {
+			if err := notify.Send(c.Type, c.Config, ev); err != nil {
+				slog.Warn("notification failed", "channel", c.Name, "target", ev.Target, "err", err)
+			}
 		}
 	}
 }
@@ -0,0 +1,35 @@
+package cmd
+
+import "testing"
+
+func TestCheckExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		want     int
+	}{
+		{"all up", []string{"up", "up"}, 0},
+		{"changed counts as up", []string{"up", "changed"}, 0},
+		{"some down", []string{"up", "down"}, 1},
+		{"error counts as down", []string{"changed", "error"}, 1},
+		{"all down", []string{"down", "error"}, 3},
+		{"single down", []string{"down"}, 3},
+		{"no targets", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var outputs []checkOutput
+			for _, s := range tt.statuses {
+				outputs = append(outputs, checkOutput{Status: s})
+			}
+			if got := checkExitCode(outputs); got != tt.want {
+				t.Errorf("checkExitCode(%v) = %d, want %d", tt.statuses, got, tt.want)
+			}
+		})
+	}
+	// The usage code isn't returned by checkExitCode but is part of the
+	// same contract
+	if exitUsage != 2 {
+		t.Errorf("exitUsage = %d, want 2", exitUsage)
+	}
+}
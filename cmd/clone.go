@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/naru-bot/upp/internal/db"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "clone <name|url|id>",
+		Short: "Duplicate a target with optional overrides",
+		Long: `Create a new target by copying every setting (and tag) of an existing one.
+
+Accepts the same flags as edit; they are applied to the copy before it is
+saved. Targets are unique by URL, type and selector, so at least one of
+those has to change.
+
+Examples:
+  upp clone "My Site" --name "Staging" --url https://staging.example.com
+  upp clone 1 --url https://eu.example.com --tag eu
+  upp clone "Pricing" --selector "div.discount" --name "Discount Watch"
+  upp clone "My API" --url https://api2.example.com --clear-auth`,
+		Args: requireArgs(1),
+		Run:  runClone,
+	}
+
+	addEditFlags(cmd)
+
+	rootCmd.AddCommand(cmd)
+}
+
+func runClone(cmd *cobra.Command, args []string) {
+	src, err := db.GetTarget(args[0])
+	if err != nil {
+		exitError(err.Error())
+	}
+	srcTags, _ := db.GetTags(src.ID)
+
+	t := *src
+	applyEditFlags(cmd, &t)
+	if !cmd.Flags().Changed("name") {
+		if src.Name == src.URL {
+			t.Name = "" // default to the new URL, like add does
+		} else {
+			t.Name = src.Name + " (copy)"
+		}
+	}
+
+	if t.URL == src.URL && t.Type == src.Type && t.Selector == src.Selector {
+		exitError("clone needs a different --url, --type or --selector (targets are unique by all three)")
+	}
+	if err := db.ValidateTarget(&t); err != nil {
+		exitError(err.Error())
+	}
+	if t.Type == "composite" {
+		if err := validateComposite(&db.Target{URL: t.URL, Quorum: t.Quorum}); err != nil {
+			exitError(err.Error())
+		}
+	}
+
+	var added *db.Target
+	err = db.WithTx(func(tx db.Store) error {
+		var err error
+		added, err = tx.AddTarget(t.Name, t.URL, t.Type, t.Interval, t.Selector, t.Headers, t.Expect, t.Timeout, t.Retries, t.Threshold, db.AddTargetOpts{
+			TriggerRule:          t.TriggerRule,
+			JQFilter:             t.JQFilter,
+			Method:               t.Method,
+			Body:                 t.Body,
+			NoFollow:             t.NoFollow,
+			AcceptStatus:         t.AcceptStatus,
+			Insecure:             t.Insecure,
+			HashHeaders:          t.HashHeaders,
+			ExpectContentType:    t.ExpectContentType,
+			Escalation:           t.Escalation,
+			NotifyOnRecovery:     t.NotifyOnRecovery,
+			MaxTotalTime:         t.MaxTotalTime,
+			StreamMode:           t.StreamMode,
+			ReadBytes:            t.ReadBytes,
+			Quorum:               t.Quorum,
+			ChangeThreshold:      t.ChangeThreshold,
+			ExpectMinTLS:         t.ExpectMinTLS,
+			AlertCertChange:      t.AlertCertChange,
+			CertPin:              t.CertPin,
+			SoftDownKeywords:     t.SoftDownKeywords,
+			RedirectStatus:       t.RedirectStatus,
+			Channels:             t.Channels,
+			Severity:             t.Severity,
+			SelectorType:         t.SelectorType,
+			JSONPath:             t.JSONPath,
+			AlertOnIPChange:      t.AlertOnIPChange,
+			ConnectTimeout:       t.ConnectTimeout,
+			Meta:                 t.Meta,
+			SnapshotMode:         t.SnapshotMode,
+			StrictSelector:       t.StrictSelector,
+			ExpectHash:           t.ExpectHash,
+			FailOnEmpty:          t.FailOnEmpty,
+			HeadOnly:             t.HeadOnly,
+			RetentionDays:        t.RetentionDays,
+			Port:                 t.Port,
+			Priority:             t.Priority,
+			CAFile:               t.CAFile,
+			ClientCert:           t.ClientCert,
+			ClientKey:            t.ClientKey,
+			ScoreRules:           t.ScoreRules,
+			ScoreWarn:            t.ScoreWarn,
+			ScoreMin:             t.ScoreMin,
+			ExpectAbsent:         t.ExpectAbsent,
+			Proxy:                t.Proxy,
+			AlertOnRegression:    t.AlertOnRegression,
+			RegressionMultiplier: t.RegressionMultiplier,
+		})
+		if err != nil {
+			return err
+		}
+		if err := tx.AddTags(added.ID, srcTags); err != nil {
+			return err
+		}
+		return applyEditTags(cmd, tx, added.ID)
+	})
+	if err != nil {
+		exitError(err.Error())
+	}
+
+	if jsonOutput {
+		printJSON(redactTarget(*added))
+		return
+	}
+	fmt.Printf("✓ Cloned %s → %s (%s)\n", src.Name, added.Name, added.URL)
+	printTargetSettings(added)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// compositeURL resolves a composite member list, given as comma-separated
+// target names, URLs or ids (with or without the composite: prefix), to
+// the stored form with ids only.
+func compositeURL(spec string) (string, error) {
+	spec = strings.TrimPrefix(spec, db.CompositePrefix)
+	var ids []int64
+	for _, ref := range strings.Split(spec, ",") {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+		m, err := db.GetTarget(ref)
+		if err != nil {
+			return "", fmt.Errorf("composite member: %w", err)
+		}
+		if slices.Contains(ids, m.ID) {
+			return "", fmt.Errorf("composite member %q is listed twice", ref)
+		}
+		ids = append(ids, m.ID)
+	}
+	if len(ids) == 0 {
+		return "", fmt.Errorf("composite targets need at least one member (e.g. upp add api,db --type composite --name Checkout)")
+	}
+	return db.CompositeURL(ids), nil
+}
+
+// printCompositeMembers lists a composite target's members with their
+// latest status, for upp view.
+func printCompositeMembers(t *db.Target) {
+	ids, err := db.CompositeMembers(t.URL)
+	if err != nil {
+		return
+	}
+	quorum := "all"
+	if t.Quorum > 0 {
+		quorum = fmt.Sprint(t.Quorum)
+	}
+	fmt.Printf("Members (quorum %s of %d):\n", quorum, len(ids))
+	for _, id := range ids {
+		m, err := db.GetTarget(fmt.Sprint(id))
+		if err != nil || m.ID != id {
+			fmt.Printf("  #%d (removed)\n", id)
+			continue
+		}
+		status := "not checked yet"
+		if m.Paused {
+			status = "paused"
+		} else if last, err := db.GetCheckHistory(m.ID, 1); err == nil && len(last) > 0 {
+			status = last[0].Status
+		}
+		fmt.Printf("  %s %s (#%d) — %s\n", statusIcon(status), m.Name, m.ID, status)
+	}
+}
+
+// validateComposite validates a composite target's members and quorum.
+func validateComposite(t *db.Target) error {
+	ids, err := db.CompositeMembers(t.URL)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(ids, t.ID) {
+		return fmt.Errorf("a composite target can't be its own member")
+	}
+	if t.Quorum > len(ids) {
+		return fmt.Errorf("quorum %d is more than the %d members", t.Quorum, len(ids))
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/naru-bot/upp/internal/checker"
+	"github.com/naru-bot/upp/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "confirm-serve",
+		Short: "Re-check targets for another upp instance before it alerts",
+		Long: `Serve down confirmations for another upp instance, so a target only
+alerts when it is down from two locations.
+
+The other instance sets confirm_url in its config to this server's
+/confirm endpoint. When one of its targets fails, it posts the target here;
+this instance checks it once, without storing anything, and answers with
+the status it saw. If that is up, the other instance records
+"down-local-only" instead of alerting.
+
+The token (--token, else confirm_token from config) must match the other
+instance's confirm_token; the server refuses to start without one. Only
+the URL, type, timeout and accepted statuses are taken from a request:
+headers, bodies, proxies, certificate files and unix socket URLs are
+rejected, so the server can't be used to reach local services.
+
+It listens on 127.0.0.1 by default; pass --listen to expose it.
+
+Examples:
+  upp confirm-serve --listen :8090 --token s3cret
+  # on the other instance, in config.yml:
+  #   confirm_url: http://this-host:8090/confirm
+  #   confirm_token: s3cret`,
+		Run: runConfirmServe,
+	}
+	cmd.Flags().String("listen", "127.0.0.1:8090", "Address to listen on")
+	cmd.Flags().String("token", "", "Bearer token callers must send (default: confirm_token from config)")
+	rootCmd.AddCommand(cmd)
+}
+
+func runConfirmServe(cmd *cobra.Command, args []string) {
+	listen, _ := cmd.Flags().GetString("listen")
+	token, _ := cmd.Flags().GetString("token")
+	if token == "" {
+		token = config.Get().ConfirmToken
+	}
+	if token == "" {
+		exitError("confirm-serve needs a token: pass --token or set confirm_token in config")
+	}
+
+	// Checks answered here are never confirmed again, or two instances
+	// pointing at each other would loop
+	settings := checker.DefaultSettings()
+	settings.ConfirmURL, settings.ConfirmToken = "", ""
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /confirm", func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req checker.ConfirmRequest
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		t, err := req.Target()
+		if err != nil {
+			http.Error(w, "invalid target: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		result := checker.Check(checker.WithEnv(r.Context(), nil, settings), t)
+		slog.Info("confirmation check", "url", t.URL, "status", result.Status, "from", r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(checker.ConfirmResponse{Status: result.Status, Error: result.Error})
+	})
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	srv := &http.Server{Addr: listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Serving down confirmations on %s/confirm\n", listen)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		exitError(err.Error())
+	}
+}
@@ -1,15 +1,19 @@
 package cmd
 
 import (
+	"cmp"
 	"fmt"
-	"os"
+	"log/slog"
 	"os/signal"
+	"slices"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/naru-bot/upp/internal/checker"
+	"github.com/naru-bot/upp/internal/config"
 	"github.com/naru-bot/upp/internal/db"
-	"github.com/naru-bot/upp/internal/trigger"
+	"github.com/naru-bot/upp/internal/notify"
 	"github.com/spf13/cobra"
 )
 
@@ -20,6 +24,29 @@ func init() {
 		Long: `Start upp as a long-running process that checks all targets
 on their configured intervals.
 
+Targets are checked concurrently. If a target's previous check is still
+running when it comes due again (a slow endpoint with a long timeout), the
+new check is skipped and logged as "skipped: overlapping" instead of piling
+up. The result the running check saves records how many were skipped, and
+'upp history' shows it.
+
+A restarted daemon doesn't check a target again whose last stored result
+is newer than its interval less daemon.dedup_slack (10 seconds by
+default); it logs "skipped: checked ... ago" and checks it when it next
+falls due. 'upp check' always checks.
+
+concurrency.max_checks in the config caps how many checks run at once, and
+concurrency.max_per_host how many run against one host, so a degraded host
+can't take every slot. Checks over a cap wait for a free slot.
+
+Within each pass, targets with a higher --priority are started first and
+are first in line for a free slot, so after a restart, when every target
+is due at once, the critical ones have a status first. Equal priorities go
+in id order.
+
+Once an hour the daemon deletes history older than storage.retention_days,
+or a target's own --retention (see 'upp prune').
+
 Examples:
   upp daemon
   upp daemon &           # run in background
@@ -32,71 +59,207 @@ func runDaemon(cmd *cobra.Command, args []string) {
 	fmt.Println("🐕 Upp daemon started")
 	fmt.Println("Press Ctrl+C to stop")
 
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	// Cancelled on SIGINT/SIGTERM, which also aborts any in-flight check
+	ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	ticker := time.NewTicker(10 * time.Second)
+	slog.Info("daemon started", "instance", db.InstanceID())
+
+	ticker := time.NewTicker(daemonTick)
 	defer ticker.Stop()
 
 	lastCheck := make(map[int64]time.Time)
+	var lastPrune time.Time
+
+	// With notify_on_startup, one summary reports the targets that are
+	// already down; their first check here doesn't alert for them again
+	var knownDown map[int64]bool
+	if config.Get().NotifyOnStartup {
+		if targets, err := db.ListTargets(); err != nil {
+			slog.Error("listing targets failed", "err", err)
+		} else {
+			var summary notify.Event
+			summary, knownDown = stateSummary(targets)
+			sendSummary(summary)
+		}
+	}
+
+	// Checks run concurrently so a slow target doesn't hold up the rest.
+	// inFlight keeps a target from being checked again before its previous
+	// check finished, and overlaps counts the checks skipped meanwhile;
+	// saveMu serializes the writes that follow a check.
+	// limiter holds checks back past the configured concurrency caps.
+	cc := config.Get().Concurrency
+	slack := config.Get().DedupSlack()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		inFlight = make(map[int64]bool)
+		overlaps = make(map[int64]int)
+		saveMu   sync.Mutex
+		limiter  = newCheckLimiter(cc.MaxChecks, cc.MaxPerHost)
+	)
 
 	for {
 		select {
-		case <-sig:
+		case <-ctx.Done():
+			wg.Wait()
+			slog.Info("daemon stopping")
 			fmt.Println("\n🐕 Upp daemon stopped")
 			return
 		case <-ticker.C:
+			// Later escalation steps fall due between checks
+			escalateOpenIncidents()
+
 			targets, err := db.ListTargets()
 			if err != nil {
+				slog.Error("listing targets failed", "err", err)
 				continue
 			}
 
 			now := time.Now()
+			if now.Sub(lastPrune) >= pruneInterval {
+				saveMu.Lock()
+				pruneDaemonHistory(targets, now)
+				saveMu.Unlock()
+				lastPrune = now
+			}
+
+			// Higher priorities go first, so after a restart, when every
+			// target is due at once, the important ones report first
+			slices.SortStableFunc(targets, func(a, b db.Target) int {
+				return cmp.Compare(b.Priority, a.Priority)
+			})
 			for _, t := range targets {
+				if ctx.Err() != nil {
+					break
+				}
 				if t.Paused {
 					continue
 				}
 
 				last, ok := lastCheck[t.ID]
+				if !ok {
+					// Not checked since startup: a result the previous run
+					// saved moments ago still counts
+					if at, recent := checkedRecently(&t, now, slack); recent {
+						slog.Info("checked recently, skipping", "target", t.Name, "checked", at)
+						fmt.Printf("[%s] %s %s — skipped: checked %s\n",
+							now.Format("15:04:05"), colorYellow("⏭"), t.Name, relativeTime(at))
+						lastCheck[t.ID] = at
+						continue
+					}
+				}
 				if ok && now.Sub(last) < time.Duration(t.Interval)*time.Second {
 					continue
 				}
 
-				result := checker.Check(&t)
-				lastCheck[t.ID] = now
-
-				cr := &db.CheckResult{
-					TargetID:     t.ID,
-					Status:       result.Status,
-					StatusCode:   result.StatusCode,
-					ResponseTime: result.ResponseTime.Milliseconds(),
-					ContentHash:  result.ContentHash,
-					Error:        result.Error,
+				mu.Lock()
+				busy := inFlight[t.ID]
+				if busy {
+					overlaps[t.ID]++
 				}
-				db.SaveCheckResult(cr)
-
-				if result.Content != "" && result.ContentHash != "" {
-					snaps, _ := db.GetLatestSnapshots(t.ID, 1)
-					if len(snaps) == 0 || snaps[0].Hash != result.ContentHash {
-						db.SaveSnapshot(t.ID, result.Content, result.ContentHash)
-					}
+				mu.Unlock()
+				if busy {
+					slog.Warn("previous check still running, skipping", "target", t.Name, "started", last)
+					fmt.Printf("[%s] %s %s — skipped: overlapping (check started %s still running)\n",
+						now.Format("15:04:05"), colorYellow("⏭"), t.Name, last.Format("15:04:05"))
+					continue
 				}
 
-				icon := statusIcon(result.Status)
-				fmt.Printf("[%s] %s %s — %s [%dms]\n",
-					now.Format("15:04:05"), icon, t.Name, result.Status, result.ResponseTime.Milliseconds())
+				// Skip targets another daemon sharing the store is already handling
+				if ok, err := db.AcquireCheckLease(t.ID, checkLeaseTTL(&t)); err != nil {
+					slog.Warn("acquiring check lease failed, checking anyway", "target", t.Name, "err", err)
+				} else if !ok {
+					slog.Debug("target leased by another daemon", "target", t.Name)
+					continue
+				}
 
-				if result.Status == "down" || result.Status == "changed" || result.Status == "error" {
-					shouldNotify := true
-					if t.TriggerRule != "" {
-						triggered, _ := trigger.Evaluate(t.TriggerRule, result.Content)
-						shouldNotify = triggered
+				lastCheck[t.ID] = now
+				announced := knownDown[t.ID]
+				delete(knownDown, t.ID)
+				mu.Lock()
+				inFlight[t.ID] = true
+				mu.Unlock()
+				wg.Add(1)
+				queued := make(chan struct{})
+				go func(t db.Target) {
+					defer wg.Done()
+					defer func() {
+						mu.Lock()
+						delete(inFlight, t.ID)
+						mu.Unlock()
+					}()
+					release, err := limiter.acquire(ctx, &t, func() { close(queued) })
+					if err != nil {
+						return // shutting down while waiting for a slot
 					}
-					if shouldNotify {
-						sendNotifications(t.Name, t.URL, result.Status, result.Error)
+					defer release()
+					result := checker.Check(ctx, &t)
+					if ctx.Err() != nil {
+						// Shutting down mid-check; don't record a spurious failure
+						return
 					}
-				}
+					mu.Lock()
+					skipped := overlaps[t.ID]
+					delete(overlaps, t.ID)
+					mu.Unlock()
+					saveMu.Lock()
+					defer saveMu.Unlock()
+					recordDaemonCheck(&t, result, now, announced, skipped)
+				}(t)
+				// Let the check take its place in line before starting the
+				// next, lower-priority one
+				<-queued
 			}
 		}
 	}
 }
+
+// daemonTick is how often the daemon looks for targets that are due.
+const daemonTick = 10 * time.Second
+
+// checkLeaseTTL is how long a daemon holds the check lease on t: its
+// interval, but at least one tick, since a target with a shorter (or no)
+// interval is checked once a tick and a lease that expires sooner would
+// let another daemon check it in between.
+func checkLeaseTTL(t *db.Target) time.Duration {
+	return max(time.Duration(t.Interval)*time.Second, daemonTick)
+}
+
+// checkedRecently returns when t's last stored result was saved, and
+// whether that is within its interval less slack of now, so checking it
+// again would duplicate that result. A negative slack turns this off.
+func checkedRecently(t *db.Target, now time.Time, slack time.Duration) (time.Time, bool) {
+	if slack < 0 {
+		return time.Time{}, false
+	}
+	results, err := db.GetCheckHistory(t.ID, 1)
+	if err != nil || len(results) == 0 {
+		return time.Time{}, false
+	}
+	at := results[0].CheckedAt
+	return at, now.Sub(at) < time.Duration(t.Interval)*time.Second-slack
+}
+
+// recordDaemonCheck saves a finished check, prints its line and sends any
+// notifications it calls for. announced marks a target the startup summary
+// reported down, which isn't alerted again while it stays down. skipped is
+// the number of due checks skipped while this one ran, recorded with it.
+func recordDaemonCheck(t *db.Target, result *checker.Result, started time.Time, announced bool, skipped int) {
+	rec := result.Record(t.ID)
+	rec.SetSkippedOverlapping(skipped)
+	if err := db.SaveCheckResult(rec); err != nil {
+		slog.Error("saving check result failed", "target", t.Name, "err", err)
+	}
+
+	if err := saveSnapshot(t, result); err != nil {
+		slog.Error("saving snapshot failed", "target", t.Name, "err", err)
+	}
+
+	icon := statusIcon(result.Status)
+	fmt.Printf("[%s] %s %s — %s [%dms]\n",
+		started.Format("15:04:05"), icon, t.Name, result.Status, result.ResponseTime.Milliseconds())
+
+	notifyResult(t, result, announced)
+}
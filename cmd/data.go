@@ -49,13 +49,13 @@ func runData(cmd *cobra.Command, args []string) {
 
 	snap := snaps[0]
 	if jsonOutput {
-		printJSON(dataOutput{Target: *t, Snapshot: snap})
+		printJSON(dataOutput{Target: redactTarget(*t), Snapshot: snap})
 		return
 	}
 
 	fmt.Printf("Target: %s (id %d)\n", t.Name, t.ID)
 	fmt.Printf("URL: %s\n", t.URL)
-	fmt.Printf("Snapshot: %s\n\n", snap.CreatedAt.Format(time.RFC3339))
+	fmt.Printf("Snapshot: %s\n\n", formatTime(snap.CreatedAt, time.RFC3339))
 	fmt.Print(snap.Content)
 	if len(snap.Content) > 0 && snap.Content[len(snap.Content)-1] != '\n' {
 		fmt.Print("\n")
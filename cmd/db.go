@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/naru-bot/upp/internal/config"
+	"github.com/naru-bot/upp/internal/db"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	dbCmd := &cobra.Command{
+		Use:   "db",
+		Short: "Inspect and upgrade the database schema",
+		Long: `Inspect and upgrade the database schema.
+
+Every other command applies pending migrations automatically when it opens
+the database. The db commands don't, so an upgrade can be inspected before
+it runs.
+
+Examples:
+  upp db version
+  upp db migrate`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			db.SetAutoMigrate(false)
+			return rootCmd.PersistentPreRunE(cmd, args)
+		},
+	}
+
+	versionCmd := &cobra.Command{
+		Use:   "version",
+		Short: "Show the schema version and pending migrations",
+		Args:  cobra.NoArgs,
+		Run:   runDBVersion,
+	}
+
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply pending schema migrations",
+		Long: `Apply pending schema migrations, in order. Each migration runs in its
+own transaction and is recorded, so running migrate again (or from two
+processes at once) never applies one twice.`,
+		Args: cobra.NoArgs,
+		Run:  runDBMigrate,
+	}
+
+	dbCmd.AddCommand(versionCmd, migrateCmd)
+	rootCmd.AddCommand(dbCmd)
+}
+
+type dbVersionOutput struct {
+	Database   string               `json:"database"`
+	Version    int                  `json:"version"`
+	Latest     int                  `json:"latest"`
+	Migrations []db.MigrationStatus `json:"migrations"`
+}
+
+// databaseLabel names the database in use: the Postgres backend or the
+// sqlite file path.
+func databaseLabel() string {
+	cfg := config.Get()
+	if _, explicit := databasePath(cfg); !explicit && cfg.Storage.DSN != "" {
+		return "postgres"
+	}
+	return db.GetDBPath()
+}
+
+func runDBVersion(cmd *cobra.Command, args []string) {
+	statuses, err := db.Migrations()
+	if err != nil {
+		exitError(err.Error())
+	}
+	out := dbVersionOutput{
+		Database:   databaseLabel(),
+		Version:    db.SchemaVersion(statuses),
+		Latest:     db.LatestSchemaVersion(),
+		Migrations: statuses,
+	}
+	if jsonOutput {
+		printJSON(out)
+		return
+	}
+
+	fmt.Printf("Database: %s\n", out.Database)
+	fmt.Printf("Schema version: %d (latest: %d)\n", out.Version, out.Latest)
+	pending := 0
+	for _, st := range statuses {
+		switch {
+		case st.Version == 0:
+			fmt.Printf("  %s ?  %s — applied %s by a newer upp\n", colorYellow("!"), st.Name, displayTime(*st.AppliedAt, time.RFC3339))
+		case st.AppliedAt != nil:
+			fmt.Printf("  %s %2d %s — applied %s\n", colorGreen("✓"), st.Version, st.Name, displayTime(*st.AppliedAt, time.RFC3339))
+		default:
+			pending++
+			fmt.Printf("  %s %2d %s — pending\n", colorYellow("○"), st.Version, st.Name)
+		}
+	}
+	if pending > 0 {
+		fmt.Printf("%d pending; run 'upp db migrate' (or any other command) to apply.\n", pending)
+	}
+}
+
+func runDBMigrate(cmd *cobra.Command, args []string) {
+	applied, err := db.Migrate()
+	if err != nil {
+		exitError(err.Error())
+	}
+	statuses, err := db.Migrations()
+	if err != nil {
+		exitError(err.Error())
+	}
+	version := db.SchemaVersion(statuses)
+	if jsonOutput {
+		if applied == nil {
+			applied = []db.MigrationStatus{}
+		}
+		printJSON(map[string]any{"database": databaseLabel(), "version": version, "applied": applied})
+		return
+	}
+
+	if len(applied) == 0 {
+		fmt.Printf("✓ Schema is up to date (version %d)\n", version)
+		return
+	}
+	for _, m := range applied {
+		fmt.Printf("  %s %2d %s\n", colorGreen("✓"), m.Version, m.Name)
+	}
+	fmt.Printf("✓ Applied %d migration(s); schema version %d\n", len(applied), version)
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/naru-bot/upp/internal/db"
 	"github.com/naru-bot/upp/internal/diff"
@@ -9,20 +10,26 @@ import (
 )
 
 func init() {
-	rootCmd.AddCommand(&cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "diff <name|url|id>",
 		Short: "Show content changes between snapshots",
 		Long: `Show what changed in the monitored page content.
 
-Compares the two most recent snapshots and displays a unified diff.
+Compares the two most recent snapshots and displays a unified diff. Text
+matching the target's expect keyword or trigger rule is highlighted, and a
+summary says whether each pattern is found in the current snapshot. A
+diff longer than display.preview_bytes (default 4 KiB) is cut short unless
+--full is given.
 
 Examples:
   upp diff "My Site"
   upp diff https://example.com
-  upp diff 1`,
+  upp diff 1 --full`,
 		Args: requireArgs(1),
 		Run:  runDiff,
-	})
+	}
+	cmd.Flags().Bool("full", false, "Show the whole diff instead of a preview")
+	rootCmd.AddCommand(cmd)
 }
 
 type diffOutput struct {
@@ -80,7 +87,21 @@ func runDiff(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	defer pageOutput()()
 	fmt.Printf("Changes for: %s (%s)\n", t.Name, t.URL)
-	fmt.Printf("Old: %s\nNew: %s\n\n", snaps[1].CreatedAt.Format("2006-01-02 15:04:05"), snaps[0].CreatedAt.Format("2006-01-02 15:04:05"))
-	fmt.Print(diff.FormatUnified(d, "previous", "current"))
+	fmt.Printf("Old: %s\nNew: %s\n\n", formatTime(snaps[1].CreatedAt, time.DateTime), formatTime(snaps[0].CreatedAt, time.DateTime))
+	patterns := targetPatterns(t)
+	for i := range d.Changes {
+		d.Changes[i].Line = highlightMatches(d.Changes[i].Line, patterns)
+	}
+	full, _ := cmd.Flags().GetBool("full")
+	shown, note := preview(diff.FormatUnified(d, "previous", "current"), full)
+	fmt.Print(shown)
+	if note != "" {
+		fmt.Println(note)
+	}
+	if len(patterns) > 0 {
+		fmt.Println()
+		printMatchSummary(snaps[0].Content, snaps[1].Content, patterns)
+	}
 }
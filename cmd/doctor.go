@@ -1,11 +1,17 @@
 package cmd
 
 import (
+	"crypto/tls"
 	"fmt"
 	"os/exec"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/naru-bot/upp/internal/checker"
+	"github.com/naru-bot/upp/internal/config"
+	"github.com/naru-bot/upp/internal/db"
 	"github.com/spf13/cobra"
 )
 
@@ -17,6 +23,11 @@ func init() {
 
 This command verifies that required tools are available for advanced features:
 - Headless browser (required for visual checks)
+- CA files and client certificates (from defaults and each target's
+  --ca-file and --client-cert), when any are set
+
+It also reports how much space stored snapshots take and, with
+storage.compress_snapshots enabled in the config, how much compression saved.
 
 Examples:
   upp doctor`,
@@ -56,6 +67,13 @@ func runDoctor(cmd *cobra.Command, args []string) {
 		issueCount++
 	}
 	checks = append(checks, browserCheck)
+	checks = append(checks, checkSnapshotStorage())
+	if tlsCheck, ok := checkTLSFiles(); ok {
+		if tlsCheck.Status != "ok" {
+			issueCount++
+		}
+		checks = append(checks, tlsCheck)
+	}
 
 	if jsonOutput {
 		printJSON(doctorOutput{
@@ -71,6 +89,10 @@ func runDoctor(cmd *cobra.Command, args []string) {
 		
 		switch check.Status {
 		case "ok":
+			if check.Path == "" {
+				fmt.Printf("  %s %s\n", colorGreen("✅"), check.Message)
+				break
+			}
 			fmt.Printf("  %s %s %s (%s)\n", colorGreen("✅"), check.Name, check.Version, check.Path)
 		case "missing":
 			fmt.Printf("  %s %s\n", colorRed("❌"), check.Message)
@@ -135,6 +157,108 @@ func checkHeadlessBrowser() doctorCheck {
 	}
 }
 
+// checkSnapshotStorage reports snapshot disk usage. It is informational and
+// never counts as an issue.
+func checkSnapshotStorage() doctorCheck {
+	st, err := db.GetSnapshotStorage()
+	if err != nil {
+		return doctorCheck{
+			Name:        "snapshots",
+			Description: "Snapshot storage",
+			Status:      "error",
+			Message:     err.Error(),
+		}
+	}
+	msg := fmt.Sprintf("%d snapshot%s, %s stored", st.Snapshots, pluralize(st.Snapshots), formatBytes(st.StoredBytes))
+	if st.Compressed > 0 {
+		msg += fmt.Sprintf(" (%d compressed, %s saved)", st.Compressed, formatBytes(st.SavedBytes()))
+	} else if !config.Load().Storage.CompressSnapshots {
+		msg += " (compression off; set storage.compress_snapshots to enable)"
+	}
+	return doctorCheck{
+		Name:        "snapshots",
+		Description: "Snapshot storage",
+		Status:      "ok",
+		Message:     msg,
+	}
+}
+
+// tlsFile is a CA bundle, or a client certificate and its key, named in
+// the config or on a target.
+type tlsFile struct {
+	ca        bool
+	cert, key string
+}
+
+// checkTLSFiles verifies that the CA bundles and client certificates named
+// in defaults and on targets still load, and that no client certificate
+// has expired. ok is false when none are configured.
+func checkTLSFiles() (check doctorCheck, ok bool) {
+	check = doctorCheck{Name: "tls-files", Description: "CA files and client certificates"}
+	users := map[tlsFile][]string{} // who uses each file
+	d := config.Get().Defaults
+	if d.CAFile != "" {
+		users[tlsFile{ca: true, cert: d.CAFile}] = []string{"defaults.ca_file"}
+	}
+	if d.ClientCert != "" {
+		users[tlsFile{cert: d.ClientCert, key: d.ClientKey}] = []string{"defaults.client_cert"}
+	}
+	targets, err := db.ListTargets()
+	if err != nil {
+		check.Status = "error"
+		check.Message = err.Error()
+		return check, true
+	}
+	for _, t := range targets {
+		if t.CAFile != "" {
+			f := tlsFile{ca: true, cert: t.CAFile}
+			users[f] = append(users[f], t.Name)
+		}
+		if t.ClientCert != "" {
+			f := tlsFile{cert: t.ClientCert, key: t.ClientKey}
+			users[f] = append(users[f], t.Name)
+		}
+	}
+	if len(users) == 0 {
+		return check, false
+	}
+	files := make([]tlsFile, 0, len(users))
+	for f := range users {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].cert != files[j].cert {
+			return files[i].cert < files[j].cert
+		}
+		return files[i].key < files[j].key
+	})
+	var problems []string
+	cas, certs := 0, 0
+	for _, f := range files {
+		if f.ca {
+			cas++
+			err = checker.ValidateCAFile(f.cert)
+		} else {
+			certs++
+			var pair *tls.Certificate
+			if pair, err = checker.LoadClientCert(f.cert, f.key); err == nil && time.Now().After(pair.Leaf.NotAfter) {
+				err = fmt.Errorf("client certificate %s expired on %s", f.cert, pair.Leaf.NotAfter.Format("2006-01-02"))
+			}
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%v (used by %s)", err, strings.Join(users[f], ", ")))
+		}
+	}
+	if len(problems) > 0 {
+		check.Status = "error"
+		check.Message = strings.Join(problems, "; ")
+		return check, true
+	}
+	check.Status = "ok"
+	check.Message = fmt.Sprintf("%d CA file%s, %d client certificate%s readable", cas, pluralize(cas), certs, pluralize(certs))
+	return check, true
+}
+
 func getInstallInstructions(component string) string {
 	if component != "headless-browser" {
 		return ""
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/naru-bot/upp/internal/checker"
+	"github.com/naru-bot/upp/internal/config"
+	"github.com/naru-bot/upp/internal/db"
+	"github.com/naru-bot/upp/internal/notify"
+)
+
+// escalationStep is a parsed config.EscalationStep.
+type escalationStep struct {
+	after    time.Duration
+	channels []string
+}
+
+// escalationPolicy looks up a policy from the escalations config section,
+// with its steps ordered by delay.
+func escalationPolicy(name string) ([]escalationStep, error) {
+	cfg := config.Get()
+	raw, ok := cfg.Escalations[name]
+	if !ok {
+		var names []string
+		for n := range cfg.Escalations {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("unknown escalation policy %q (none are defined under escalations in the config)", name)
+		}
+		return nil, fmt.Errorf("unknown escalation policy %q (have: %s)", name, strings.Join(names, ", "))
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("escalation policy %q has no steps", name)
+	}
+
+	steps := make([]escalationStep, len(raw))
+	for i, s := range raw {
+		var after time.Duration
+		if a := strings.TrimSpace(s.After); a != "" && a != "0" {
+			secs, err := parseSeconds(a)
+			if err != nil {
+				return nil, fmt.Errorf("escalation policy %q step %d: after: %w", name, i+1, err)
+			}
+			after = time.Duration(secs) * time.Second
+		}
+		if len(s.Notify) == 0 {
+			return nil, fmt.Errorf("escalation policy %q step %d has no notify channels", name, i+1)
+		}
+		steps[i] = escalationStep{after: after, channels: s.Notify}
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].after < steps[j].after })
+	return steps, nil
+}
+
+// escalate records a down result against the target's open incident and
+// sends whichever policy steps are due.
+func escalate(t *db.Target, ev notify.Event) {
+	steps, err := escalationPolicy(t.Escalation)
+	if err != nil {
+		// A policy removed from config shouldn't silence the target
+		slog.Warn("escalation policy unusable, notifying the target's channels", "target", t.Name, "err", err)
+		notifyTarget(t, ev)
+		return
+	}
+	inc, err := db.OpenIncident(t.ID, ev.Error)
+	if err != nil {
+		slog.Error("opening incident failed", "target", t.Name, "err", err)
+		return
+	}
+	runEscalation(t, inc, steps, ev)
+}
+
+// runEscalation sends the steps of steps that have come due since the
+// incident started and were not sent yet.
+func runEscalation(t *db.Target, inc *db.Incident, steps []escalationStep, ev notify.Event) {
+	down := time.Since(inc.StartedAt)
+	due := 0
+	for due < len(steps) && steps[due].after <= down {
+		due++
+	}
+	if due <= inc.StepsSent {
+		return
+	}
+	ok, err := db.AdvanceIncident(inc.ID, inc.StepsSent, due)
+	if err != nil {
+		slog.Error("advancing incident failed", "target", t.Name, "err", err)
+		return
+	}
+	if !ok {
+		return // another daemon sent these steps
+	}
+	errMsg := ev.Error
+	ev.Downtime = humanizeDuration(down)
+	for i := inc.StepsSent; i < due; i++ {
+		slog.Info("escalating", "target", t.Name, "step", i+1, "channels", steps[i].channels, "down_for", down.Round(time.Second))
+		if i > 0 {
+			ev.Error = fmt.Sprintf("%s (still %s after %s, escalation step %d)", errMsg, ev.Status, ev.Downtime, i+1)
+		}
+		sendNotificationsTo(steps[i].channels, ev)
+	}
+	inc.StepsSent = due
+}
+
+// resolveEscalation closes the target's open incident, telling every
+// channel that was alerted that the target recovered.
+func resolveEscalation(t *db.Target, r *checker.Result) {
+	inc, err := db.ResolveIncident(t.ID)
+	if err != nil {
+		slog.Error("resolving incident failed", "target", t.Name, "err", err)
+		return
+	}
+	if inc == nil || inc.StepsSent == 0 {
+		return
+	}
+	ev := resultEvent(t, r)
+	ev.Status = "recovered"
+	ev.Downtime = humanizeDuration(time.Since(inc.StartedAt))
+	ev.Error = "back up after " + ev.Downtime
+	steps, err := escalationPolicy(t.Escalation)
+	if err != nil {
+		notifyTarget(t, ev)
+		return
+	}
+	seen := make(map[string]bool)
+	var channels []string
+	for _, s := range steps[:min(inc.StepsSent, len(steps))] {
+		for _, c := range s.channels {
+			if !seen[c] {
+				seen[c] = true
+				channels = append(channels, c)
+			}
+		}
+	}
+	sendNotificationsTo(channels, ev)
+}
+
+// escalateOpenIncidents re-evaluates every open incident, so later steps go
+// out on time even when the target's check interval is longer than the
+// step delays. The daemon calls it on every tick.
+func escalateOpenIncidents() {
+	incs, err := db.ListOpenIncidents()
+	if err != nil {
+		slog.Error("listing incidents failed", "err", err)
+		return
+	}
+	for i := range incs {
+		inc := &incs[i]
+		t, err := db.GetTarget(strconv.FormatInt(inc.TargetID, 10))
+		if err != nil || t.Paused || t.Muted {
+			continue
+		}
+		if t.Escalation == "" {
+			// Policy removed from the target; nothing left to escalate
+			db.ResolveIncident(t.ID)
+			continue
+		}
+		steps, err := escalationPolicy(t.Escalation)
+		if err != nil {
+			continue
+		}
+		runEscalation(t, inc, steps, targetEvent(t, "down", inc.Error))
+	}
+}
@@ -14,7 +14,8 @@ func init() {
 	cmd := &cobra.Command{
 		Use:   "export",
 		Short: "Export monitoring data as JSON or CSV",
-		Long: `Export all targets and their check history.
+		Long: `Export all targets and their check history. Proxy passwords are
+redacted.
 
 Examples:
   upp export --json > data.json
@@ -25,9 +26,15 @@ Examples:
 	rootCmd.AddCommand(cmd)
 }
 
+// exportVersion is bumped when an exported field changes meaning, so
+// import can convert files written before. Version 1 counts retries as
+// extra attempts after a failure; unversioned exports counted all attempts.
+const exportVersion = 1
+
 type exportData struct {
-	Targets []db.Target       `json:"targets"`
-	Results []db.CheckResult  `json:"check_results"`
+	Version int              `json:"version"`
+	Targets []db.Target      `json:"targets"`
+	Results []db.CheckResult `json:"check_results"`
 }
 
 func runExport(cmd *cobra.Command, args []string) {
@@ -69,7 +76,7 @@ func runExport(cmd *cobra.Command, args []string) {
 		allResults = append(allResults, results...)
 	}
 
-	data := exportData{Targets: targets, Results: allResults}
+	data := exportData{Version: exportVersion, Targets: redactTargets(targets), Results: allResults}
 	if targets == nil {
 		data.Targets = []db.Target{}
 	}
@@ -7,7 +7,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
+	"github.com/naru-bot/upp/internal/checker"
 	"github.com/spf13/cobra"
 )
 
@@ -15,16 +15,21 @@ func init() {
 	cmd := &cobra.Command{
 		Use:   "extract <url>",
 		Short: "Fetch a URL and show extracted content",
-		Long: `Fetch a URL once and print the extracted content.
+		Long: `Fetch a URL once and print the extracted content. Content longer than
+display.preview_bytes (default 4 KiB) is cut short unless --full is given.
 
 Examples:
   upp extract https://example.com --selector "main"
-  upp extract https://example.com/pricing --selector "div.price"`,
+  upp extract https://example.com/pricing --selector "div.price"
+  upp extract https://example.com --full
+  upp extract https://example.com/pricing --selector "//div[@class='price']/@data-amount" --selector-type xpath`,
 		Args: requireArgs(1),
 		Run:  runExtract,
 	}
 	cmd.Flags().StringP("selector", "s", "", "CSS selector to extract content")
+	cmd.Flags().String("selector-type", "", "How --selector is read: css (default) or xpath")
 	cmd.Flags().Int("timeout", 30, "Request timeout in seconds")
+	cmd.Flags().Bool("full", false, "Show all of the content instead of a preview")
 	rootCmd.AddCommand(cmd)
 }
 
@@ -39,6 +44,11 @@ type extractOutput struct {
 func runExtract(cmd *cobra.Command, args []string) {
 	url := args[0]
 	selector, _ := cmd.Flags().GetString("selector")
+	selectorType, _ := cmd.Flags().GetString("selector-type")
+	selectorType = strings.ToLower(selectorType)
+	if err := checker.ValidateSelector(selectorType, selector); err != nil {
+		exitError("--selector: " + err.Error())
+	}
 	timeoutSeconds, _ := cmd.Flags().GetInt("timeout")
 	if timeoutSeconds <= 0 {
 		timeoutSeconds = 30
@@ -67,19 +77,8 @@ func runExtract(cmd *cobra.Command, args []string) {
 
 	content := string(body)
 	if selector != "" {
-		doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
-		if err == nil {
-			var selected []string
-			doc.Find(selector).Each(func(i int, s *goquery.Selection) {
-				// Strip style/script so CSS/JS doesn't pollute extracted text.
-				s.Find("style,script").Remove()
-				selected = append(selected, strings.TrimSpace(s.Text()))
-			})
-			if len(selected) > 0 {
-				content = strings.Join(selected, "\n")
-			} else {
-				content = ""
-			}
+		if selected, _, err := checker.SelectText(selectorType, selector, content); err == nil {
+			content = strings.Join(selected, "\n")
 		}
 	}
 
@@ -99,8 +98,13 @@ func runExtract(cmd *cobra.Command, args []string) {
 	} else {
 		fmt.Printf("URL: %s\nStatus: %d\n\n", url, resp.StatusCode)
 	}
-	fmt.Print(content)
-	if len(content) > 0 && content[len(content)-1] != '\n' {
+	full, _ := cmd.Flags().GetBool("full")
+	shown, note := preview(content, full)
+	fmt.Print(shown)
+	if len(shown) > 0 && shown[len(shown)-1] != '\n' {
 		fmt.Print("\n")
 	}
+	if note != "" {
+		fmt.Println(note)
+	}
 }
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/naru-bot/upp/internal/checker"
+	"github.com/naru-bot/upp/internal/config"
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// flapSettings returns the flapping window and threshold from config. A
+// window of 0 turns flap detection off.
+func flapSettings() (window, threshold int) {
+	f := config.Get().Flapping
+	if f.Window <= 0 {
+		return 0, 0
+	}
+	threshold = f.Threshold
+	if threshold <= 0 {
+		threshold = max(f.Window/2, 1)
+	}
+	return f.Window, threshold
+}
+
+// flapState reports whether the target is flapping as of its latest
+// result, whether it already was as of the result before, and how many
+// status changes the latest window holds. A status change is a check
+// going down or error from any other status, or back.
+func flapState(targetID int64) (flapping, wasFlapping bool, changes int) {
+	window, _ := flapSettings()
+	if window == 0 {
+		return false, false, 0
+	}
+	results, err := db.GetCheckHistory(targetID, window+1)
+	if err != nil {
+		return false, false, 0
+	}
+	return flapStateOf(results)
+}
+
+// flapStateOf is flapState for a target's history, newest first, already
+// read: at least the latest flapping window plus one result, if stored.
+func flapStateOf(results []db.CheckResult) (flapping, wasFlapping bool, changes int) {
+	window, threshold := flapSettings()
+	if window == 0 {
+		return false, false, 0
+	}
+	results = results[:min(window+1, len(results))]
+	changes = statusChanges(results[:min(window, len(results))])
+	if len(results) > 1 {
+		wasFlapping = statusChanges(results[1:]) >= threshold
+	}
+	return changes >= threshold, wasFlapping, changes
+}
+
+func statusChanges(results []db.CheckResult) int {
+	n := 0
+	for i := 1; i < len(results); i++ {
+		if isFailure(results[i].Status) != isFailure(results[i-1].Status) {
+			n++
+		}
+	}
+	return n
+}
+
+func isFailure(status string) bool {
+	return status == "down" || status == "error"
+}
+
+// notifyFlapping sends a "flapping" notification when the result just saved
+// starts the target flapping, and a "stable" one when it ends it. It
+// reports whether the target is flapping now, so per-change alerts can be
+// held back.
+func notifyFlapping(t *db.Target, r *checker.Result) bool {
+	flapping, wasFlapping, changes := flapState(t.ID)
+	window, _ := flapSettings()
+	switch {
+	case flapping && !wasFlapping:
+		slog.Warn("target is flapping", "target", t.Name, "changes", changes, "window", window)
+		ev := resultEvent(t, r)
+		ev.Status = "flapping"
+		ev.Error = fmt.Sprintf("%d status changes in the last %d checks; holding back alerts until it settles", changes, window)
+		notifyTarget(t, ev)
+	case !flapping && wasFlapping:
+		slog.Info("target stopped flapping", "target", t.Name, "status", r.Status)
+		ev := resultEvent(t, r)
+		ev.Status = "stable"
+		ev.Error = "stopped flapping, now " + r.Status
+		notifyTarget(t, ev)
+	}
+	return flapping
+}
@@ -3,7 +3,9 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"slices"
 	"text/tabwriter"
+	"time"
 
 	"github.com/naru-bot/upp/internal/db"
 	"github.com/spf13/cobra"
@@ -13,44 +15,106 @@ func init() {
 	cmd := &cobra.Command{
 		Use:   "history <name|url|id>",
 		Short: "Show check history for a target",
-		Args:  requireArgs(1),
-		Run:   runHistory,
+		Long: `Show stored check results for a target, newest first.
+
+JSON output is an object with the page of results under "items" and
+total/limit/offset/has_more paging fields.
+
+Examples:
+  upp history "My Site"
+  upp history "My Site" --limit 100 --offset 100
+  upp history 1 --all --json`,
+		Args: requireArgs(1),
+		Run:  runHistory,
 	}
-	cmd.Flags().IntP("limit", "l", 20, "Number of results to show")
+	addPageFlags(cmd, 20)
 	rootCmd.AddCommand(cmd)
 }
 
 func runHistory(cmd *cobra.Command, args []string) {
-	limit, _ := cmd.Flags().GetInt("limit")
+	page := pageFromFlags(cmd)
 
 	t, err := db.GetTarget(args[0])
 	if err != nil {
 		exitError(err.Error())
 	}
 
-	results, err := db.GetCheckHistory(t.ID, limit)
+	results, total, err := db.GetCheckHistoryPage(t.ID, page)
 	if err != nil {
 		exitError(err.Error())
 	}
 
 	if jsonOutput {
-		printJSON(results)
+		if results == nil {
+			results = []db.CheckResult{}
+		}
+		printJSON(newPagedOutput(results, len(results), total, page))
 		return
 	}
+	defer pageOutput()()
 
+	if len(results) == 0 && total > 0 {
+		fmt.Printf("No results at offset %d (%d in total).\n", page.Offset, total)
+		return
+	}
 	if len(results) == 0 {
 		fmt.Println("No check history. Run 'upp check' first.")
 		return
 	}
 
+	showIP := slices.ContainsFunc(results, func(r db.CheckResult) bool { return r.RemoteIP != "" })
+
 	fmt.Printf("History for: %s (%s)\n\n", t.Name, t.URL)
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintf(w, "TIME\tSTATUS\tCODE\tRESPONSE\tERROR\n")
-	fmt.Fprintf(w, "────\t──────\t────\t────────\t─────\n")
+	if showIP {
+		fmt.Fprintf(w, "TIME\tSTATUS\tCODE\tRESPONSE\tIP\tERROR\n")
+		fmt.Fprintf(w, "────\t──────\t────\t────────\t──\t─────\n")
+	} else {
+		fmt.Fprintf(w, "TIME\tSTATUS\tCODE\tRESPONSE\tERROR\n")
+		fmt.Fprintf(w, "────\t──────\t────\t────────\t─────\n")
+	}
 
-	for _, r := range results {
+	for i, r := range results {
+		if showIP {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%dms\t%s\t%s\n",
+				formatTime(r.CheckedAt, time.DateTime), r.Status, r.StatusCode, r.ResponseTime, historyIP(results, i), historyError(&r))
+			continue
+		}
 		fmt.Fprintf(w, "%s\t%s\t%d\t%dms\t%s\n",
-			r.CheckedAt.Format("2006-01-02 15:04:05"), r.Status, r.StatusCode, r.ResponseTime, r.Error)
+			formatTime(r.CheckedAt, time.DateTime), r.Status, r.StatusCode, r.ResponseTime, historyError(&r))
 	}
 	w.Flush()
+	printPageFooter(len(results), total, page, "results")
+}
+
+// historyError is the error column of r, noting the daemon checks skipped
+// while it ran.
+func historyError(r *db.CheckResult) string {
+	n := r.SkippedOverlapping()
+	if n == 0 {
+		return r.Error
+	}
+	note := fmt.Sprintf("(skipped %d overlapping check%s)", n, pluralize(n))
+	if r.Error == "" {
+		return note
+	}
+	return r.Error + " " + note
+}
+
+// historyIP is the IP column of results[i], marked "(changed)" when it
+// differs from the IP of the next older result that connected.
+func historyIP(results []db.CheckResult, i int) string {
+	ip := results[i].RemoteIP
+	if ip == "" {
+		return "-"
+	}
+	for _, older := range results[i+1:] {
+		if older.RemoteIP != "" {
+			if older.RemoteIP != ip {
+				return ip + " (changed)"
+			}
+			break
+		}
+	}
+	return ip
 }
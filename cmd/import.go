@@ -3,8 +3,12 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/naru-bot/upp/internal/checker"
 	"github.com/naru-bot/upp/internal/db"
+	"github.com/naru-bot/upp/internal/notify"
+	"github.com/naru-bot/upp/internal/trigger"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 )
@@ -29,6 +33,10 @@ YAML format:
       url: 192.168.1.1:3306
       type: tcp
 
+retries counts extra attempts after a failure. An 'upp export' file
+written before exports carried a version counted every attempt; its
+retries are lowered by one on import to match.
+
 Examples:
   upp import targets.yml
   upp import targets.yml --json`,
@@ -38,27 +46,74 @@ Examples:
 }
 
 type importFile struct {
+	Version int            `yaml:"version"`
 	Targets []importTarget `yaml:"targets"`
+	Results yaml.Node      `yaml:"check_results"` // only read to tell an export from a hand-written file
+}
+
+// legacyExport reports whether f is an 'upp export' file written before
+// exports were versioned, whose retries count every attempt.
+func (f *importFile) legacyExport() bool {
+	return f.Version == 0 && f.Results.Kind != 0
 }
 
 type importTarget struct {
-	Name      string  `yaml:"name"`
-	URL       string  `yaml:"url"`
-	Type      string  `yaml:"type"`
-	Interval  int     `yaml:"interval"`
-	Selector  string  `yaml:"selector"`
-	Headers   string  `yaml:"headers"`
-	Expect    string  `yaml:"expect"`
-	Timeout   int     `yaml:"timeout"`
-	Retries   int     `yaml:"retries"`
-	Threshold   float64 `yaml:"threshold"`
-	TriggerRule   string  `yaml:"trigger_rule"`
-	JQFilter      string  `yaml:"jq_filter"`
-	Method        string  `yaml:"method"`
-	Body          string  `yaml:"body"`
-	NoFollow      bool    `yaml:"no_follow"`
-	AcceptStatus  string  `yaml:"accept_status"`
-	Insecure      bool    `yaml:"insecure"`
+	Name                 string            `yaml:"name"`
+	URL                  string            `yaml:"url"`
+	Type                 string            `yaml:"type"`
+	Interval             int               `yaml:"interval"`
+	Selector             string            `yaml:"selector"`
+	Headers              string            `yaml:"headers"`
+	Expect               string            `yaml:"expect"`
+	Timeout              int               `yaml:"timeout"`
+	Retries              int               `yaml:"retries"`
+	Threshold            float64           `yaml:"threshold"`
+	TriggerRule          string            `yaml:"trigger_rule"`
+	JQFilter             string            `yaml:"jq_filter"`
+	Method               string            `yaml:"method"`
+	Body                 string            `yaml:"body"`
+	NoFollow             bool              `yaml:"no_follow"`
+	AcceptStatus         string            `yaml:"accept_status"`
+	Insecure             bool              `yaml:"insecure"`
+	HashHeaders          []string          `yaml:"hash_headers"`
+	ExpectContentType    string            `yaml:"expect_content_type"`
+	SoftDownKeywords     []string          `yaml:"soft_down_keywords"`
+	CertPin              string            `yaml:"cert_pin"`
+	AlertCertChange      bool              `yaml:"alert_cert_change"`
+	ExpectMinTLS         string            `yaml:"expect_min_tls"`
+	Escalation           string            `yaml:"escalation"`
+	NotifyOnRecovery     bool              `yaml:"notify_on_recovery"`
+	MaxTotalTime         int               `yaml:"max_total_time"`
+	StreamMode           bool              `yaml:"stream_mode"`
+	ReadBytes            int               `yaml:"read_bytes"`
+	Quorum               int               `yaml:"quorum"`
+	ChangeThreshold      float64           `yaml:"change_threshold"`
+	RedirectStatus       string            `yaml:"redirect_status"`
+	Channels             []string          `yaml:"channels"`
+	Severity             string            `yaml:"severity"`
+	SelectorType         string            `yaml:"selector_type"`
+	JSONPath             string            `yaml:"json_path"`
+	AlertOnIPChange      bool              `yaml:"alert_on_ip_change"`
+	ConnectTimeout       int               `yaml:"connect_timeout"`
+	Meta                 map[string]string `yaml:"meta"`
+	SnapshotMode         string            `yaml:"snapshot_mode"`
+	StrictSelector       bool              `yaml:"strict_selector"`
+	ExpectHash           string            `yaml:"expect_hash"`
+	FailOnEmpty          bool              `yaml:"fail_on_empty"`
+	HeadOnly             bool              `yaml:"head_only"`
+	RetentionDays        int               `yaml:"retention_days"`
+	Port                 int               `yaml:"port"`
+	Priority             int               `yaml:"priority"`
+	CAFile               string            `yaml:"ca_file"`
+	ClientCert           string            `yaml:"client_cert"`
+	ClientKey            string            `yaml:"client_key"`
+	ScoreRules           []string          `yaml:"score_rules"`
+	ScoreWarn            int               `yaml:"score_warn"`
+	ScoreMin             int               `yaml:"score_min"`
+	ExpectAbsent         string            `yaml:"expect_absent"`
+	Proxy                string            `yaml:"proxy"`
+	AlertOnRegression    bool              `yaml:"alert_on_regression"`
+	RegressionMultiplier float64           `yaml:"regression_multiplier"`
 }
 
 func runImport(cmd *cobra.Command, args []string) {
@@ -85,6 +140,7 @@ func runImport(cmd *cobra.Command, args []string) {
 
 	var results []result
 	added := 0
+	legacy := imp.legacyExport()
 
 	for _, t := range imp.Targets {
 		if t.URL == "" {
@@ -99,16 +155,22 @@ func runImport(cmd *cobra.Command, args []string) {
 		if t.Timeout <= 0 {
 			t.Timeout = 30
 		}
-		if t.Retries <= 0 {
-			t.Retries = 1
+		if t.Retries < db.NoRetry {
+			t.Retries = 0
+		}
+		if legacy && t.Retries > 0 {
+			// Same conversion as the retries_are_extra migration
+			t.Retries--
 		}
 		if t.Threshold <= 0 {
 			t.Threshold = 5.0
 		}
-
-		_, err := db.AddTarget(t.Name, t.URL, t.Type, t.Interval, t.Selector, t.Headers, t.Expect, t.Timeout, t.Retries, t.Threshold, db.AddTargetOpts{
-				TriggerRule: t.TriggerRule, JQFilter: t.JQFilter, Method: t.Method, Body: t.Body, NoFollow: t.NoFollow, AcceptStatus: t.AcceptStatus, Insecure: t.Insecure,
+		err := normalizeImport(&t)
+		if err == nil {
+			_, err = db.AddTarget(t.Name, t.URL, t.Type, t.Interval, t.Selector, t.Headers, t.Expect, t.Timeout, t.Retries, t.Threshold, db.AddTargetOpts{
+				TriggerRule: t.TriggerRule, JQFilter: t.JQFilter, Method: t.Method, Body: t.Body, NoFollow: t.NoFollow, AcceptStatus: t.AcceptStatus, Insecure: t.Insecure, HashHeaders: t.HashHeaders, ExpectContentType: t.ExpectContentType, SoftDownKeywords: t.SoftDownKeywords, CertPin: t.CertPin, AlertCertChange: t.AlertCertChange, ExpectMinTLS: t.ExpectMinTLS, Escalation: t.Escalation, NotifyOnRecovery: t.NotifyOnRecovery, MaxTotalTime: t.MaxTotalTime, StreamMode: t.StreamMode, ReadBytes: t.ReadBytes, Quorum: t.Quorum, ChangeThreshold: t.ChangeThreshold, RedirectStatus: t.RedirectStatus, Channels: t.Channels, Severity: t.Severity, SelectorType: t.SelectorType, JSONPath: t.JSONPath, AlertOnIPChange: t.AlertOnIPChange, ConnectTimeout: t.ConnectTimeout, Meta: t.Meta, SnapshotMode: t.SnapshotMode, StrictSelector: t.StrictSelector, ExpectHash: t.ExpectHash, FailOnEmpty: t.FailOnEmpty, HeadOnly: t.HeadOnly, RetentionDays: t.RetentionDays, Port: t.Port, Priority: t.Priority, CAFile: t.CAFile, ClientCert: t.ClientCert, ClientKey: t.ClientKey, ScoreRules: t.ScoreRules, ScoreWarn: t.ScoreWarn, ScoreMin: t.ScoreMin, ExpectAbsent: t.ExpectAbsent, Proxy: t.Proxy, AlertOnRegression: t.AlertOnRegression, RegressionMultiplier: t.RegressionMultiplier,
 			})
+		}
 		r := result{Name: t.Name, URL: t.URL}
 		if err != nil {
 			r.Status = "error"
@@ -138,3 +200,60 @@ func runImport(cmd *cobra.Command, args []string) {
 		fmt.Printf("\n%s imported, %d total\n", colorBold(fmt.Sprintf("%d", added)), len(imp.Targets))
 	}
 }
+
+// normalizeImport checks an imported target's options the way add checks
+// its flags, and rewrites them in the form add would store.
+func normalizeImport(t *importTarget) error {
+	headers, err := normalizeHeaders(t.Headers)
+	if err != nil {
+		return fmt.Errorf("headers: %v", err)
+	}
+	t.Headers = headers
+	if err := validateProxy(t.Proxy, t.Type, t.URL); err != nil {
+		return fmt.Errorf("proxy: %v", err)
+	}
+	if t.CAFile, err = resolveCAFile(t.CAFile); err != nil {
+		return fmt.Errorf("ca_file: %v", err)
+	}
+	if t.ClientCert, t.ClientKey, err = resolveClientCert(t.ClientCert, t.ClientKey); err != nil {
+		return err
+	}
+	if t.CertPin != "" {
+		if t.CertPin, err = checker.NormalizeFingerprint(t.CertPin); err != nil {
+			return fmt.Errorf("cert_pin: %v", err)
+		}
+	}
+	if t.ExpectHash != "" {
+		if t.ExpectHash, err = checker.NormalizeFingerprint(t.ExpectHash); err != nil {
+			return fmt.Errorf("expect_hash: %v", err)
+		}
+	}
+	// Exports from before regression alerts had their own fields keep
+	// them in the trigger rule, as the regression_out_of_trigger_rule
+	// migration found them
+	if multiplier, ok := trigger.RegressionMultiplier(t.TriggerRule); ok {
+		t.TriggerRule = ""
+		t.AlertOnRegression, t.RegressionMultiplier = true, multiplier
+	}
+	if err := validateRegressionMultiplier(t.RegressionMultiplier); err != nil {
+		return fmt.Errorf("regression_multiplier: %v", err)
+	}
+	t.SelectorType = strings.ToLower(t.SelectorType)
+	if err := checker.ValidateSelector(t.SelectorType, t.Selector); err != nil {
+		return fmt.Errorf("selector: %v", err)
+	}
+	if err := validateJSONPath(t.JSONPath, t.JQFilter); err != nil {
+		return fmt.Errorf("json_path: %v", err)
+	}
+	if err := checker.ValidateRedirectStatus(t.RedirectStatus); err != nil {
+		return fmt.Errorf("redirect_status: %v", err)
+	}
+	if err := checker.ValidateSnapshotMode(t.SnapshotMode); err != nil {
+		return fmt.Errorf("snapshot_mode: %v", err)
+	}
+	t.Severity = strings.ToLower(t.Severity)
+	if err := notify.ValidateSeverity(t.Severity); err != nil {
+		return fmt.Errorf("severity: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// checkLimiter caps the checks running at once, overall and per host
+// (concurrency.max_checks and max_per_host). A zero cap is no limit. Checks
+// waiting for a slot get it in priority order, highest first.
+type checkLimiter struct {
+	global  *prioritySem
+	perHost int
+
+	mu    sync.Mutex
+	hosts map[string]*hostSlots
+}
+
+// hostSlots is one host's semaphore and how many checks hold or wait for it.
+type hostSlots struct {
+	sem   *prioritySem
+	users int
+}
+
+func newCheckLimiter(maxChecks, maxPerHost int) *checkLimiter {
+	l := &checkLimiter{perHost: maxPerHost, hosts: make(map[string]*hostSlots)}
+	if maxChecks > 0 {
+		l.global = newPrioritySem(maxChecks)
+	}
+	return l
+}
+
+// acquire waits for a slot for t: its host's first, so checks queued on a
+// saturated host don't hold global slots other hosts could use. The
+// returned func releases both. It fails only when ctx is done.
+//
+// queued is called once t holds its slots or waits in line for one, so a
+// caller starting checks in priority order can wait for each to take its
+// place before starting the next.
+func (l *checkLimiter) acquire(ctx context.Context, t *db.Target, queued func()) (func(), error) {
+	queued = sync.OnceFunc(queued)
+	defer queued()
+	key := targetHost(t)
+	host := l.hostSlots(key)
+	if host != nil {
+		if err := host.sem.acquire(ctx, t.Priority, queued); err != nil {
+			l.leaveHost(key, host)
+			return nil, err
+		}
+	}
+	if l.global != nil {
+		if err := l.global.acquire(ctx, t.Priority, queued); err != nil {
+			if host != nil {
+				host.sem.release()
+				l.leaveHost(key, host)
+			}
+			return nil, err
+		}
+	}
+	return func() {
+		if l.global != nil {
+			l.global.release()
+		}
+		if host != nil {
+			host.sem.release()
+			l.leaveHost(key, host)
+		}
+	}, nil
+}
+
+// hostSlots returns the semaphore for host, creating it on first use, or
+// nil when hosts aren't limited.
+func (l *checkLimiter) hostSlots(host string) *hostSlots {
+	if l.perHost <= 0 || host == "" {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.hosts[host]
+	if !ok {
+		h = &hostSlots{sem: newPrioritySem(l.perHost)}
+		l.hosts[host] = h
+	}
+	h.users++
+	return h
+}
+
+// leaveHost drops a user of host's semaphore, forgetting it once unused so
+// the map doesn't grow with every host ever checked.
+func (l *checkLimiter) leaveHost(host string, h *hostSlots) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if h.users--; h.users == 0 {
+		delete(l.hosts, host)
+	}
+}
+
+// prioritySem is a counting semaphore whose waiters are served highest
+// priority first, and in arrival order within a priority.
+type prioritySem struct {
+	mu      sync.Mutex
+	size    int
+	held    int
+	waiters []*semWaiter // in arrival order
+}
+
+type semWaiter struct {
+	priority int
+	ready    chan struct{} // closed when the slot is handed over
+}
+
+func newPrioritySem(size int) *prioritySem {
+	return &prioritySem{size: size}
+}
+
+// acquire takes a slot, waiting for one if all are held; queued is called
+// once it waits in line. It fails only when ctx is done, and then holds
+// nothing.
+func (s *prioritySem) acquire(ctx context.Context, priority int, queued func()) error {
+	s.mu.Lock()
+	if s.held < s.size && len(s.waiters) == 0 {
+		s.held++
+		s.mu.Unlock()
+		return nil
+	}
+	w := &semWaiter{priority: priority, ready: make(chan struct{})}
+	s.waiters = append(s.waiters, w)
+	s.mu.Unlock()
+	queued()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	select {
+	case <-w.ready:
+		// Handed a slot while giving up; pass it on
+		s.mu.Unlock()
+		s.release()
+		return ctx.Err()
+	default:
+	}
+	for i, other := range s.waiters {
+		if other == w {
+			s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+			break
+		}
+	}
+	s.mu.Unlock()
+	return ctx.Err()
+}
+
+// release frees a slot, handing it straight to the first waiter in
+// priority order if there is one.
+func (s *prioritySem) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.waiters) == 0 {
+		s.held--
+		return
+	}
+	next := 0
+	for i, w := range s.waiters {
+		if w.priority > s.waiters[next].priority {
+			next = i
+		}
+	}
+	w := s.waiters[next]
+	s.waiters = append(s.waiters[:next], s.waiters[next+1:]...)
+	close(w.ready)
+}
+
+// targetHost is the host a target's checks connect to: the host name of
+// a URL, the host of a host:port, or the bare host or domain of ping,
+// tcp-ping, dns and whois targets. Composites check nothing themselves and have none.
+func targetHost(t *db.Target) string {
+	if t.Type == "composite" {
+		return ""
+	}
+	if u, err := url.Parse(t.URL); err == nil && u.Host != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	if host, _, err := net.SplitHostPort(t.URL); err == nil {
+		return strings.ToLower(host)
+	}
+	return strings.ToLower(t.URL)
+}
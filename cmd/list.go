@@ -3,11 +3,13 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/naru-bot/upp/internal/db"
+	"github.com/naru-bot/upp/internal/notify"
 	"github.com/spf13/cobra"
 )
 
@@ -21,11 +23,18 @@ func init() {
 Examples:
   upp list
   upp list --tag my-sites
-  upp list --tags           # list all tags with counts`,
+  upp list --tags           # list all tags with counts
+  upp list --no-bar         # hide the recent-history bar
+  upp list --sort priority  # highest priority first, as the daemon checks them
+  upp list --limit 50 --offset 100
+  upp list --all            # no 100-target cap`,
 		Run: runList,
 	}
 	cmd.Flags().String("tag", "", "Filter targets by tag")
 	cmd.Flags().Bool("tags", false, "List all tags with target counts")
+	cmd.Flags().Bool("no-bar", false, "Hide the recent check history bar")
+	cmd.Flags().String("sort", "id", "Order targets by: "+strings.Join(db.TargetOrders, ", "))
+	addPageFlags(cmd, 100)
 	rootCmd.AddCommand(cmd)
 }
 
@@ -37,22 +46,28 @@ func runList(cmd *cobra.Command, args []string) {
 	}
 
 	tag, _ := cmd.Flags().GetString("tag")
-	var targets []db.Target
-	var err error
-	if tag != "" {
-		targets, err = db.ListTargetsByTag(tag)
-	} else {
-		targets, err = db.ListTargets()
+	sort, _ := cmd.Flags().GetString("sort")
+	if !slices.Contains(db.TargetOrders, sort) {
+		exitError(fmt.Sprintf("--sort must be one of %s", strings.Join(db.TargetOrders, ", ")))
 	}
+	page := pageFromFlags(cmd)
+	targets, total, err := db.ListTargetsPage(tag, sort, page)
 	if err != nil {
 		exitError(err.Error())
 	}
 
 	if jsonOutput {
-		printJSON(targets)
+		if targets == nil {
+			targets = []db.Target{}
+		}
+		printJSON(newPagedOutput(redactTargets(targets), len(targets), total, page))
 		return
 	}
 
+	if len(targets) == 0 && total > 0 {
+		fmt.Printf("No targets at offset %d (%d in total).\n", page.Offset, total)
+		return
+	}
 	if len(targets) == 0 {
 		if tag != "" {
 			fmt.Printf("No targets with tag %q. Use 'upp list --tags' to see all tags.\n", tag)
@@ -63,21 +78,71 @@ func runList(cmd *cobra.Command, args []string) {
 	}
 
 	tagMap, _ := db.GetTagMap()
+	noBar, _ := cmd.Flags().GetBool("no-bar")
+	showBar := !noBar && uptimeBarEnabled()
+
+	ids := make([]int64, len(targets))
+	for i, t := range targets {
+		ids[i] = t.ID
+	}
+	lastErrors, _ := db.GetLatestErrors(ids)
+	// One query reads the history behind the status, [flapping] and the bar
+	window, _ := flapSettings()
+	histories, historyErr := db.GetRecentHistory(ids, max(uptimeBarWidth, window+1))
+
+	// Severity is shown once any target is set below the critical default
+	showSeverity := slices.ContainsFunc(targets, func(t db.Target) bool {
+		return notify.EffectiveSeverity(t.Severity) != notify.SeverityCritical
+	})
+
+	// Priority is shown once any target has one, or when sorting by it
+	showPriority := sort == "priority" || slices.ContainsFunc(targets, func(t db.Target) bool {
+		return t.Priority != 0
+	})
+
+	cols := []string{"ID", "NAME", "URL", "TYPE", "INTERVAL", "TAGS", "STATUS"}
+	if showPriority {
+		cols = append(cols, "PRIORITY")
+	}
+	if showSeverity {
+		cols = append(cols, "SEVERITY")
+	}
+	if len(lastErrors) > 0 {
+		cols = append(cols, "LAST ERROR")
+	}
+	if showBar {
+		// The bar holds color escapes, so it goes last where tabwriter's
+		// byte-based widths can't misalign anything after it.
+		cols = append(cols, "HISTORY")
+	}
+	rules := make([]string, len(cols))
+	for i, c := range cols {
+		rules[i] = strings.Repeat("─", len(c))
+	}
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintf(w, "ID\tNAME\tURL\tTYPE\tINTERVAL\tTAGS\tSTATUS\n")
-	fmt.Fprintf(w, "──\t────\t───\t────\t────────\t────\t──────\n")
+	fmt.Fprintln(w, strings.Join(cols, "\t"))
+	fmt.Fprintln(w, strings.Join(rules, "\t"))
 
+	now := time.Now()
 	for _, t := range targets {
 		status := "active"
 		if t.Paused {
 			status = "paused"
 		}
-		results, err := db.GetCheckHistory(t.ID, 1)
-		if err == nil && len(results) > 0 {
+		results := histories[t.ID]
+		if historyErr == nil && len(results) > 0 {
 			last := results[0]
-			age := time.Since(last.CheckedAt).Round(time.Second)
-			status = fmt.Sprintf("%s (%s ago)", last.Status, age)
+			status = fmt.Sprintf("%s (%s)", last.Status, relativeTime(last.CheckedAt))
+			if isStale(&t, last.CheckedAt, now) {
+				status += " [stale]"
+			}
+		}
+		if t.Muted {
+			status += " [muted]"
+		}
+		if flapping, _, _ := flapStateOf(results); flapping {
+			status += " [flapping]"
 		}
 
 		tags := ""
@@ -85,10 +150,55 @@ func runList(cmd *cobra.Command, args []string) {
 			tags = strings.Join(tt, ",")
 		}
 
-		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%ds\t%s\t%s\n",
-			t.ID, t.Name, truncate(t.URL, 40), t.Type, t.Interval, tags, status)
+		row := []string{fmt.Sprint(t.ID), t.Name, truncate(t.URL, 40), t.Type, formatSeconds(t.Interval), tags, status}
+		if showPriority {
+			row = append(row, fmt.Sprint(t.Priority))
+		}
+		if showSeverity {
+			row = append(row, notify.EffectiveSeverity(t.Severity))
+		}
+		if len(lastErrors) > 0 {
+			row = append(row, briefError(lastErrors[t.ID]))
+		}
+		if showBar {
+			row = append(row, uptimeBar(results))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
 	}
 	w.Flush()
+	printPageFooter(len(targets), total, page, "targets")
+}
+
+// uptimeBarWidth is the number of recent checks shown in the history bar.
+const uptimeBarWidth = 30
+
+// uptimeBarEnabled reports whether the colored history bar can be shown:
+// it needs color and an interactive terminal.
+func uptimeBarEnabled() bool {
+	if noColor || jsonOutput {
+		return false
+	}
+	return stdoutIsTerminal()
+}
+
+// uptimeBar renders recent check results (newest first, as returned by
+// GetCheckHistory) as one colored block per check, oldest on the left.
+// Slots without a check yet are shown as dots.
+func uptimeBar(history []db.CheckResult) string {
+	if len(history) > uptimeBarWidth {
+		history = history[:uptimeBarWidth]
+	}
+	var sb strings.Builder
+	sb.WriteString(strings.Repeat("·", uptimeBarWidth-len(history)))
+	for i := len(history) - 1; i >= 0; i-- {
+		switch status := history[i].Status; status {
+		case "down", "error", "changed", "redirect", "down-local-only":
+			sb.WriteString(colorStatus(status, "█"))
+		default:
+			sb.WriteString(colorStatus("up", "█"))
+		}
+	}
+	return sb.String()
 }
 
 func listTags() {
@@ -1,12 +1,12 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"text/tabwriter"
 
 	"github.com/naru-bot/upp/internal/db"
+	"github.com/naru-bot/upp/internal/notify"
 	"github.com/spf13/cobra"
 )
 
@@ -25,11 +25,23 @@ Examples:
   upp notify add --name alerts --type webhook --config '{"url":"https://hooks.slack.com/..."}'
   upp notify add --name telegram --type telegram --config '{"bot_token":"...","chat_id":"..."}'
   upp notify add --name discord --type discord --config '{"webhook_url":"..."}'
-  upp notify add --name runner --type command --config '{"command":"echo {target} is {status}"}'`,
+  upp notify add --name runner --type command --config '{"command":"echo {target} is {status}"}'
+  upp notify add --name opsgenie --type opsgenie --config '{"api_key":"...","region":"eu"}'
+  upp notify add --name teams --type teams --config '{"webhook_url":"https://....webhook.office.com/..."}'
+  upp notify add --name oncall --type slack --config '{"webhook_url":"...","template":"{{.Target}} is {{.Status}} ({{.StatusCode}}, down {{.Downtime}}) runbook: https://wiki/run/{{.Target}}"}'
+
+Every channel takes an optional "template" key: a Go text/template that
+renders the message from the fields .Target .URL .Type .Status .PrevStatus
+.StatusCode .ResponseMs .Downtime .Error .Tags and .Time.
+
+Opsgenie channels open an alert keyed to the target when it goes down and
+close it when the target recovers; the target's tags become alert tags.
+Teams channels post an Adaptive Card, or a legacy MessageCard with
+"format":"messagecard".`,
 		Run: runNotifyAdd,
 	}
 	addCmd.Flags().String("name", "", "Name for this notification channel")
-	addCmd.Flags().String("type", "", "Type: webhook, command, slack, telegram, discord")
+	addCmd.Flags().String("type", "", "Type: webhook, command, slack, telegram, discord, opsgenie, teams")
 	addCmd.Flags().String("config", "", "JSON configuration for the channel")
 	addCmd.MarkFlagRequired("name")
 	addCmd.MarkFlagRequired("type")
@@ -78,7 +90,22 @@ Examples:
 		},
 	}
 
-	notifyCmd.AddCommand(addCmd, listCmd, removeCmd)
+	testCmd := &cobra.Command{
+		Use:   "test <name|id>",
+		Short: "Send a test notification through a channel",
+		Long: `Send a test notification through a channel, to check its config.
+
+The test event is for a sample target "My Site" with the status "test".
+Disabled channels are sent to as well.
+
+Examples:
+  upp notify test teams
+  upp notify test 2`,
+		Args: requireArgs(1),
+		Run:  runNotifyTest,
+	}
+
+	notifyCmd.AddCommand(addCmd, listCmd, removeCmd, testCmd)
 	rootCmd.AddCommand(notifyCmd)
 }
 
@@ -87,10 +114,9 @@ func runNotifyAdd(cmd *cobra.Command, args []string) {
 	typ, _ := cmd.Flags().GetString("type")
 	config, _ := cmd.Flags().GetString("config")
 
-	// Validate JSON
-	var js json.RawMessage
-	if err := json.Unmarshal([]byte(config), &js); err != nil {
-		exitError("Invalid JSON config: " + err.Error())
+	// Validate JSON and the message template
+	if err := notify.ValidateConfig(typ, config); err != nil {
+		exitError(err.Error())
 	}
 
 	if err := db.SaveNotifyConfig(name, typ, config); err != nil {
@@ -103,3 +129,50 @@ func runNotifyAdd(cmd *cobra.Command, args []string) {
 		fmt.Printf("✓ Added notification channel: %s (%s)\n", name, typ)
 	}
 }
+
+func runNotifyTest(cmd *cobra.Command, args []string) {
+	configs, err := db.ListNotifyConfigs()
+	if err != nil {
+		exitError(err.Error())
+	}
+	var channel *db.NotifyConfig
+	for i, c := range configs {
+		if c.Name == args[0] || fmt.Sprint(c.ID) == args[0] {
+			channel = &configs[i]
+			break
+		}
+	}
+	if channel == nil {
+		exitError(fmt.Sprintf("notification channel not found: %s", args[0]))
+	}
+	if err := notify.Send(channel.Type, channel.Config, notify.TestEvent()); err != nil {
+		exitError(fmt.Sprintf("test notification through %s failed: %v", channel.Name, err))
+	}
+	if jsonOutput {
+		printJSON(map[string]string{"status": "sent", "name": channel.Name, "type": channel.Type})
+	} else {
+		fmt.Printf("✓ Sent test notification through %s (%s)\n", channel.Name, channel.Type)
+	}
+}
+
+// validateChannels checks that every name is a configured notification
+// channel.
+func validateChannels(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	configs, err := db.ListNotifyConfigs()
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(configs))
+	for _, c := range configs {
+		known[c.Name] = true
+	}
+	for _, name := range names {
+		if !known[name] {
+			return fmt.Errorf("unknown notification channel %q (see 'upp notify list')", name)
+		}
+	}
+	return nil
+}
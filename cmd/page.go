@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/naru-bot/upp/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// addPageFlags adds --limit, --offset and --all to a listing command.
+func addPageFlags(cmd *cobra.Command, defaultLimit int) {
+	cmd.Flags().IntP("limit", "l", defaultLimit, "Maximum number of entries to show")
+	cmd.Flags().Int("offset", 0, "Number of entries to skip")
+	cmd.Flags().Bool("all", false, "Show every entry (no limit)")
+	cmd.MarkFlagsMutuallyExclusive("all", "limit")
+	cmd.MarkFlagsMutuallyExclusive("all", "offset")
+}
+
+// pageFromFlags reads the flags added by addPageFlags.
+func pageFromFlags(cmd *cobra.Command) db.Page {
+	if all, _ := cmd.Flags().GetBool("all"); all {
+		return db.Page{}
+	}
+	limit, _ := cmd.Flags().GetInt("limit")
+	offset, _ := cmd.Flags().GetInt("offset")
+	if limit < 1 {
+		exitError("--limit must be at least 1 (use --all for no limit)")
+	}
+	if offset < 0 {
+		exitError("--offset cannot be negative")
+	}
+	return db.Page{Limit: limit, Offset: offset}
+}
+
+// pagedOutput is the JSON shape of paged listings: the page itself plus
+// what a caller needs to fetch the next one.
+type pagedOutput struct {
+	Items   interface{} `json:"items"`
+	Total   int         `json:"total"`
+	Limit   int         `json:"limit,omitempty"` // omitted with --all
+	Offset  int         `json:"offset"`
+	HasMore bool        `json:"has_more"`
+}
+
+func newPagedOutput(items interface{}, count, total int, page db.Page) pagedOutput {
+	return pagedOutput{
+		Items:   items,
+		Total:   total,
+		Limit:   page.Limit,
+		Offset:  page.Offset,
+		HasMore: page.Offset+count < total,
+	}
+}
+
+// printPageFooter tells the user when a listing was cut short.
+func printPageFooter(count, total int, page db.Page, noun string) {
+	if page.Offset+count >= total || count == 0 {
+		return
+	}
+	fmt.Printf("\nShowing %d-%d of %d %s. Use --offset %d for more, or --all.\n",
+		page.Offset+1, page.Offset+count, total, noun, page.Offset+count)
+}
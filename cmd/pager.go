@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/mattn/go-runewidth"
+
+	"github.com/naru-bot/upp/internal/config"
+)
+
+// noPager is --no-pager.
+var noPager bool
+
+// ansiEscape matches the color codes output is decorated with.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// pageOutput collects what a command prints to stdout and, once it is done,
+// shows it through a pager when it is taller than the terminal, as git
+// does. Call it at the start of a command and defer the function it
+// returns. Output isn't paged with --no-pager, --json, display.pager off,
+// or when stdout isn't a terminal, and is printed plainly when the pager
+// can't be started.
+func pageOutput() func() {
+	pager := pagerCommand()
+	if noPager || jsonOutput || pager == nil || !stdoutIsTerminal() {
+		return func() {}
+	}
+	width, height, err := term.GetSize(os.Stdout.Fd())
+	if err != nil || height <= 0 {
+		return func() {}
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return func() {}
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	var buf bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		io.Copy(&buf, r)
+		close(done)
+	}()
+
+	return func() {
+		os.Stdout = stdout
+		w.Close()
+		<-done
+		r.Close()
+		if outputRows(buf.String(), width) < height {
+			stdout.Write(buf.Bytes())
+			return
+		}
+		cmd := exec.Command(pager[0], pager[1:]...)
+		cmd.Stdin = &buf
+		cmd.Stdout = stdout
+		cmd.Stderr = os.Stderr
+		if os.Getenv("LESS") == "" {
+			// Keep colors, and leave the output on screen on exit
+			cmd.Env = append(os.Environ(), "LESS=FRX")
+		}
+		if err := cmd.Start(); err != nil {
+			stdout.Write(buf.Bytes())
+			return
+		}
+		cmd.Wait()
+	}
+}
+
+// pagerCommand returns the pager to run: display.pager, else $PAGER, else
+// less. It returns nil when paging is turned off with "off" (or "cat",
+// which would page nothing).
+func pagerCommand() []string {
+	pager := config.Get().Display.Pager
+	if pager == "" {
+		pager = os.Getenv("PAGER")
+	}
+	if pager == "" {
+		pager = "less"
+	}
+	fields := strings.Fields(pager)
+	if len(fields) == 0 || fields[0] == "off" || fields[0] == "cat" {
+		return nil
+	}
+	return fields
+}
+
+// outputRows counts the terminal rows s takes at the given width, long
+// lines wrapping.
+func outputRows(s string, width int) int {
+	rows := 0
+	for _, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+		n := runewidth.StringWidth(ansiEscape.ReplaceAllString(line, ""))
+		if width <= 0 || n <= width {
+			rows++
+			continue
+		}
+		rows += (n + width - 1) / width
+	}
+	return rows
+}
@@ -40,4 +40,43 @@ func init() {
 			}
 		},
 	})
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "mute <name|url|id>",
+		Short: "Keep checking a target but stop its notifications",
+		Long: `Silence notifications and trigger rules for a target. Unlike pause, a muted
+target is still checked and its results recorded, so history and uptime stay
+complete while the alerts are quiet.
+
+Examples:
+  upp mute "My Site"
+  upp unmute "My Site"`,
+		Args: requireArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := db.SetMuted(args[0], true); err != nil {
+				exitError(err.Error())
+			}
+			if jsonOutput {
+				printJSON(map[string]string{"status": "muted", "target": args[0]})
+			} else {
+				fmt.Printf("🔇 Muted: %s\n", args[0])
+			}
+		},
+	})
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "unmute <name|url|id>",
+		Short: "Restore notifications for a muted target",
+		Args:  requireArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := db.SetMuted(args[0], false); err != nil {
+				exitError(err.Error())
+			}
+			if jsonOutput {
+				printJSON(map[string]string{"status": "unmuted", "target": args[0]})
+			} else {
+				fmt.Printf("🔔 Unmuted: %s\n", args[0])
+			}
+		},
+	})
 }
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/naru-bot/upp/internal/checker"
+	"github.com/naru-bot/upp/internal/db"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "pin <name|url|id>",
+		Short: "Expect a target's current content hash from now on",
+		Long: `Fetch the target now and pin its content hash: from then on, any check
+whose content hashes differently is down. Use it for content that must
+never change, such as a published artifact or a fixed config file.
+
+The hash is taken after the target's selector, jq filter or JSON path, so
+pinning a region of a page only watches that region. The check run here is
+not saved to history. --clear removes the pin (as does 'upp edit
+--clear-expect-hash'); 'upp edit --expect-hash' sets a known hash instead.
+
+Examples:
+  upp pin "Release tarball"
+  upp pin 3 --clear`,
+		Args: requireArgs(1),
+		Run:  runPin,
+	}
+	cmd.Flags().Bool("clear", false, "Remove the expected content hash")
+	rootCmd.AddCommand(cmd)
+}
+
+type pinOutput struct {
+	Target       string `json:"target"`
+	URL          string `json:"url"`
+	ExpectHash   string `json:"expect_hash"`
+	PreviousHash string `json:"previous_hash,omitempty"`
+}
+
+func runPin(cmd *cobra.Command, args []string) {
+	t, err := db.GetTarget(args[0])
+	if err != nil {
+		exitError(err.Error())
+	}
+	previous := t.ExpectHash
+
+	if clear, _ := cmd.Flags().GetBool("clear"); clear {
+		t.ExpectHash = ""
+		if err := db.UpdateTarget(t); err != nil {
+			exitError(err.Error())
+		}
+		if jsonOutput {
+			printJSON(pinOutput{Target: t.Name, URL: t.URL, PreviousHash: previous})
+		} else {
+			fmt.Printf("✓ Unpinned: %s (%s)\n", t.Name, t.URL)
+		}
+		return
+	}
+
+	if t.Type != "" && t.Type != "http" && t.Type != "https" {
+		exitError(fmt.Sprintf("%s is a %s target; content hashes can only be pinned for http targets", t.Name, t.Type))
+	}
+	if t.StreamMode {
+		exitError(fmt.Sprintf("%s is in stream mode, which doesn't hash content", t.Name))
+	}
+	if t.HeadOnly {
+		exitError(fmt.Sprintf("%s only checks headers (head_only), which doesn't hash content", t.Name))
+	}
+
+	// Check without the old pin, so a mismatch doesn't stop the content
+	// from being read
+	probe := *t
+	probe.ExpectHash = ""
+	result := checker.Check(cmd.Context(), &probe)
+	if result.Status == "down" || result.Status == "error" {
+		exitError(fmt.Sprintf("check failed, nothing pinned: %s", result.Error))
+	}
+	if result.ContentHash == "" {
+		exitError("the check returned no content to pin")
+	}
+
+	t.ExpectHash = result.ContentHash
+	if err := db.UpdateTarget(t); err != nil {
+		exitError(err.Error())
+	}
+	if jsonOutput {
+		printJSON(pinOutput{Target: t.Name, URL: t.URL, ExpectHash: t.ExpectHash, PreviousHash: previous})
+		return
+	}
+	fmt.Printf("📌 Pinned: %s (%s)\n", t.Name, t.URL)
+	fmt.Printf("  Content hash: %s (%d bytes)\n", t.ExpectHash, len(result.Content))
+	if previous != "" && previous != t.ExpectHash {
+		fmt.Printf("  Replaces: %s\n", previous)
+	}
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/naru-bot/upp/internal/checker"
@@ -21,15 +22,20 @@ Useful for one-off diagnostics, scripting, and AI agent spot-checks.
 Examples:
   upp ping https://example.com
   upp ping https://example.com --selector "h1"
+  upp ping https://example.com --selector "//h1" --selector-type xpath
   upp ping 192.168.1.1:3306 --type tcp
   upp ping example.com --type dns
+  upp ping example.com --type tcp-ping --port 22 --count 5
+  upp ping https://www.githubstatus.com --type statuspage
   upp ping https://api.example.com --expect "ok"
   upp ping https://example.com --count 5`,
 		Args: requireArgs(1),
 		Run:  runPing,
 	}
-	cmd.Flags().StringP("type", "t", "http", "Check type: http, tcp, ping, dns")
+	cmd.Flags().StringP("type", "t", "http", "Check type: http, tcp, ping, tcp-ping, dns, statuspage")
+	cmd.Flags().Int("port", 0, "Port for --type tcp-ping (default 443)")
 	cmd.Flags().StringP("selector", "s", "", "CSS selector to extract")
+	cmd.Flags().String("selector-type", "", "How --selector is read: css (default) or xpath")
 	cmd.Flags().String("expect", "", "Expected keyword in response body")
 	cmd.Flags().IntP("count", "c", 1, "Number of checks to run")
 	cmd.Flags().Int("timeout", 30, "Timeout in seconds")
@@ -51,22 +57,40 @@ func runPing(cmd *cobra.Command, args []string) {
 	url := args[0]
 	typ, _ := cmd.Flags().GetString("type")
 	selector, _ := cmd.Flags().GetString("selector")
+	selectorType, _ := cmd.Flags().GetString("selector-type")
+	selectorType = strings.ToLower(selectorType)
+	if err := checker.ValidateSelector(selectorType, selector); err != nil {
+		exitError("--selector: " + err.Error())
+	}
 	expect, _ := cmd.Flags().GetString("expect")
 	count, _ := cmd.Flags().GetInt("count")
+	port, _ := cmd.Flags().GetInt("port")
+	if port != 0 && typ != "tcp-ping" {
+		exitError("--port only applies to --type tcp-ping (tcp targets take host:port)")
+	}
+	if port < 0 || port > 65535 {
+		exitError("--port must be between 1 and 65535")
+	}
 
 	// Create a temporary target (not saved to DB)
 	target := &db.Target{
-		URL:      url,
-		Name:     url,
-		Type:     typ,
-		Selector: selector,
+		URL:          url,
+		Name:         url,
+		Type:         typ,
+		Selector:     selector,
+		SelectorType: selectorType,
+		Port:         port,
 	}
 
 	var outputs []pingOutput
 	var totalMs int64
 
 	for i := 0; i < count; i++ {
-		result := checker.Check(target)
+		result := checker.Check(cmd.Context(), target)
+		if result.Status == "baseline" {
+			// Nothing is stored, so there is no baseline to speak of
+			result.Status = "up"
+		}
 		
 		out := pingOutput{
 			URL:         url,
@@ -95,13 +119,9 @@ func runPing(cmd *cobra.Command, args []string) {
 		outputs = append(outputs, out)
 
 		if !jsonOutput {
-			icon := statusIcon(result.Status)
+			icon := colorStatus(result.Status, statusIcon(result.Status))
 			if out.BodyMatch != nil && !*out.BodyMatch {
-				icon = colorYellow("⚠")
-			} else if result.Status == "up" || result.Status == "unchanged" {
-				icon = colorGreen("✓")
-			} else if result.Status == "down" || result.Status == "error" {
-				icon = colorRed("✗")
+				icon = colorStatus("changed", "⚠")
 			}
 
 			if count > 1 {
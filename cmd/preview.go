@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/naru-bot/upp/internal/config"
+)
+
+// preview shortens content, bodies and diffs for display to
+// display.preview_bytes. note is empty when nothing was cut, and otherwise
+// an ellipsis line saying how much is shown and how to see the rest. full
+// shows everything. JSON output is never shortened.
+func preview(text string, full bool) (shown, note string) {
+	return previewLines(text, 0, full)
+}
+
+// previewLines is preview that also stops after maxLines lines, for
+// content read line by line such as view's snapshot. 0 means no line limit.
+func previewLines(text string, maxLines int, full bool) (shown, note string) {
+	if full {
+		return text, ""
+	}
+	shown, cut := contentPreview(text, maxLines, config.Get().PreviewBytes())
+	if !cut {
+		return text, ""
+	}
+	return shown, fmt.Sprintf("… showing %s of %s; use --full for everything",
+		formatBytes(int64(len(shown))), formatBytes(int64(len(text))))
+}
+
+// previewInline is preview for text embedded in a line, such as the error
+// of a notification: the shortened text ends in an ellipsis and the size
+// of what was left out.
+func previewInline(text string) string {
+	shown, cut := contentPreview(text, 0, config.Get().PreviewBytes())
+	if !cut {
+		return text
+	}
+	return fmt.Sprintf("%s… (+%s)", strings.TrimRight(shown, "\n"), formatBytes(int64(len(text)-len(shown))))
+}
+
+// contentPreview shortens content to at most maxLines lines (0 for no
+// limit) and maxBytes bytes, cutting at a line break when there is one in
+// range and never inside a UTF-8 sequence. cut reports whether anything
+// was dropped.
+func contentPreview(content string, maxLines, maxBytes int) (preview string, cut bool) {
+	end := len(content)
+	if maxLines > 0 {
+		lines := 0
+		for i := 0; i < len(content); i++ {
+			if content[i] == '\n' {
+				if lines++; lines == maxLines {
+					end = i + 1
+					break
+				}
+			}
+		}
+	}
+	if end > maxBytes {
+		end = maxBytes
+		if nl := strings.LastIndexByte(content[:end], '\n'); nl > 0 {
+			end = nl + 1
+		}
+		for end > 0 && !utf8.RuneStart(content[end]) {
+			end--
+		}
+	}
+	return content[:end], end < len(content)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/naru-bot/upp/internal/checker"
+	"github.com/naru-bot/upp/internal/config"
+	"github.com/naru-bot/upp/internal/db"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "probe <url|name|id>",
+		Short: "Check once and exit 0 if up, 1 if down, printing nothing",
+		Long: `Check a target once and report the result only through the exit code:
+0 when it is up, 1 when it is down or can't be checked. Nothing is printed
+and nothing is saved, so it suits a Kubernetes exec probe or any script
+that only needs a yes or no.
+
+A URL (anything with "://", or any argument with --type) is checked as
+given, without opening the database at all. Anything else names a stored
+target, which is checked with its own settings and retries. Changed
+content counts as up. confirm_url is not consulted: a probe asks whether
+the target is reachable from here.
+
+--timeout bounds the whole check, retries included, and should be below
+the probe's timeoutSeconds. -v logs the check to stderr.
+
+Examples:
+  upp probe http://localhost:8080/healthz --timeout 2s
+  upp probe localhost:5432 --type tcp --timeout 1
+  upp probe "My API"
+
+In a pod spec:
+  livenessProbe:
+    exec:
+      command: ["upp", "probe", "http://localhost:8080/healthz", "--timeout", "2s"]
+    periodSeconds: 10
+    timeoutSeconds: 3`,
+		Args: requireArgs(1),
+		Run:  runProbe,
+	}
+	cmd.Flags().StringP("type", "t", "", "Check type for a URL: http (default), tcp, ping, tcp-ping, dns, statuspage")
+	cmd.Flags().String("timeout", "", "Deadline for the whole check, e.g. 2s or 5 (default: the target's timeout, 10s for a URL)")
+	rootCmd.AddCommand(cmd)
+}
+
+// probeURLTimeout is the check timeout of a probed URL without --timeout.
+const probeURLTimeout = 10
+
+func runProbe(cmd *cobra.Command, args []string) {
+	typ, _ := cmd.Flags().GetString("type")
+	timeoutStr, _ := cmd.Flags().GetString("timeout")
+	var timeout int
+	if timeoutStr != "" {
+		var err error
+		if timeout, err = parseSeconds(timeoutStr); err != nil {
+			exitError("--timeout: " + err.Error())
+		}
+	}
+
+	var target *db.Target
+	if typ != "" || strings.Contains(args[0], "://") {
+		if typ == "" {
+			typ = "http"
+		}
+		target = &db.Target{URL: args[0], Name: args[0], Type: typ, Timeout: probeURLTimeout}
+	} else {
+		if err := openDatabase(config.Get()); err != nil {
+			exitError(err.Error())
+		}
+		t, err := db.GetTarget(args[0])
+		if err != nil {
+			exitError(err.Error())
+		}
+		target = t
+	}
+	if timeout > 0 {
+		target.MaxTotalTime = timeout
+		if target.Timeout <= 0 || target.Timeout > timeout {
+			target.Timeout = timeout
+		}
+	}
+
+	// Nothing stored is compared against, except the members a composite
+	// rolls up
+	ctx := cmd.Context()
+	if target.Type != "composite" {
+		settings := checker.DefaultSettings()
+		settings.ConfirmURL = ""
+		ctx = checker.WithEnv(ctx, nil, settings)
+	}
+	if result := checker.Check(ctx, target); isFailure(result.Status) {
+		os.Exit(1)
+	}
+}
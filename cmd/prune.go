@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/naru-bot/upp/internal/config"
+	"github.com/naru-bot/upp/internal/db"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "prune [name|url|id...]",
+		Short: "Delete check history older than each target's retention",
+		Long: `Delete check results and snapshots older than the retention period.
+
+storage.retention_days in the config sets how many days of history are
+kept; a target's own --retention overrides it, so a critical target can
+keep a year while a noisy one keeps a week. Targets with neither, or with
+--retention forever, are left alone. The latest result and snapshot of a
+target are always kept. The daemon prunes the same way once an hour.
+
+Examples:
+  upp prune
+  upp prune --dry-run
+  upp prune "My Site" --json`,
+		Run: runPrune,
+	}
+	cmd.Flags().Bool("dry-run", false, "Show what would be deleted without deleting it")
+	rootCmd.AddCommand(cmd)
+}
+
+// pruneInterval is how often the daemon prunes history.
+const pruneInterval = time.Hour
+
+type pruneTarget struct {
+	Target        string    `json:"target"`
+	RetentionDays int       `json:"retention_days"`
+	Before        time.Time `json:"before"`
+	db.PruneStats
+}
+
+type pruneOutput struct {
+	DryRun    bool          `json:"dry_run"`
+	Targets   []pruneTarget `json:"targets"`
+	Results   int64         `json:"results"`
+	Snapshots int64         `json:"snapshots"`
+}
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+func runPrune(cmd *cobra.Command, args []string) {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	var targets []db.Target
+	if len(args) == 0 {
+		var err error
+		if targets, err = db.ListTargets(); err != nil {
+			exitError(err.Error())
+		}
+	}
+	for _, arg := range args {
+		t, err := db.GetTarget(arg)
+		if err != nil {
+			exitError(err.Error())
+		}
+		targets = append(targets, *t)
+	}
+
+	out := pruneOutput{DryRun: dryRun, Targets: []pruneTarget{}}
+	err := db.WithTx(func(tx db.Store) error {
+		pruned, err := pruneHistory(tx, targets, time.Now())
+		if err != nil {
+			return err
+		}
+		out.Targets = append(out.Targets, pruned...)
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		exitError(err.Error())
+	}
+	for _, p := range out.Targets {
+		out.Results += p.Results
+		out.Snapshots += p.Snapshots
+	}
+
+	if jsonOutput {
+		printJSON(out)
+		return
+	}
+	if len(out.Targets) == 0 {
+		fmt.Println("No retention configured; nothing to prune. Set storage.retention_days or use --retention on a target.")
+		return
+	}
+	verb := "Deleted"
+	if dryRun {
+		verb = "Would delete"
+	}
+	for _, p := range out.Targets {
+		fmt.Printf("%s: keep %s — %d result(s), %d snapshot(s)\n",
+			p.Target, formatRetention(p.RetentionDays), p.Results, p.Snapshots)
+	}
+	fmt.Printf("\n%s %d result(s) and %d snapshot(s).\n", verb, out.Results, out.Snapshots)
+}
+
+// pruneHistory deletes each target's history older than its effective
+// retention, skipping targets that keep everything.
+func pruneHistory(s db.Store, targets []db.Target, now time.Time) ([]pruneTarget, error) {
+	global := config.Get().Storage.RetentionDays
+	var pruned []pruneTarget
+	for _, t := range targets {
+		days := retentionDays(&t, global)
+		if days <= 0 {
+			continue
+		}
+		before := now.AddDate(0, 0, -days)
+		st, err := s.PruneHistory(t.ID, before)
+		if err != nil {
+			return pruned, fmt.Errorf("pruning %s: %w", t.Name, err)
+		}
+		pruned = append(pruned, pruneTarget{Target: t.Name, RetentionDays: days, Before: before, PruneStats: st})
+	}
+	return pruned, nil
+}
+
+// pruneDaemonHistory is the daemon's periodic prune. Failures are logged;
+// the next run tries again.
+func pruneDaemonHistory(targets []db.Target, now time.Time) {
+	err := db.WithTx(func(tx db.Store) error {
+		pruned, err := pruneHistory(tx, targets, now)
+		for _, p := range pruned {
+			if p.Results > 0 || p.Snapshots > 0 {
+				slog.Info("pruned history", "target", p.Target, "retention_days", p.RetentionDays,
+					"results", p.Results, "snapshots", p.Snapshots)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		slog.Error("pruning history failed", "err", err)
+	}
+}
+
+// retentionDays is the number of days of history kept for t: its own
+// retention_days, or global. Zero or less keeps everything.
+func retentionDays(t *db.Target, global int) int {
+	if t.RetentionDays != 0 {
+		return t.RetentionDays
+	}
+	return global
+}
+
+// parseRetention parses a --retention value: days as a bare number or a
+// duration of whole days (30d, 720h), or "forever" to keep everything.
+// Zero falls back to storage.retention_days.
+func parseRetention(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "forever") {
+		return -1, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 0 {
+			return 0, fmt.Errorf("retention must not be negative, got %q", s)
+		}
+		return n, nil
+	}
+	secs, err := parseSeconds(s)
+	if err != nil {
+		return 0, err
+	}
+	if secs%86400 != 0 {
+		return 0, fmt.Errorf("retention %q is not a whole number of days", s)
+	}
+	return secs / 86400, nil
+}
+
+// formatRetention describes a retention_days value.
+func formatRetention(days int) string {
+	switch {
+	case days < 0:
+		return "forever"
+	case days == 0:
+		return "default"
+	case days == 1:
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/naru-bot/upp/internal/checker"
+	"github.com/naru-bot/upp/internal/config"
+	"github.com/naru-bot/upp/internal/db"
+	"github.com/naru-bot/upp/internal/trigger"
+)
+
+// applyTriggerIf sets what a --trigger-if value asks for on t. A content
+// rule replaces its trigger rule; response_time_regression[:N] turns on
+// its regression alert instead, leaving any content rule in place.
+func applyTriggerIf(t *db.Target, s string) error {
+	rule, err := trigger.ParseShorthand(s)
+	if err != nil {
+		return err
+	}
+	if multiplier, ok := trigger.RegressionMultiplier(rule); ok {
+		t.AlertOnRegression, t.RegressionMultiplier = true, multiplier
+		return nil
+	}
+	t.TriggerRule = rule
+	return nil
+}
+
+// validateRegressionMultiplier rejects a --regression-multiplier that
+// wouldn't make a slower check stand out; 0 uses the configured default.
+func validateRegressionMultiplier(m float64) error {
+	if m != 0 && m <= 1 {
+		return fmt.Errorf("--regression-multiplier must be above 1, got %g", m)
+	}
+	return nil
+}
+
+// describeRegression describes t's regression alert, "" when it has none.
+func describeRegression(t *db.Target) string {
+	if !t.AlertOnRegression {
+		return ""
+	}
+	if t.RegressionMultiplier == 0 {
+		return "slower than the configured multiple of the recent median"
+	}
+	return fmt.Sprintf("slower than %g× the recent median", t.RegressionMultiplier)
+}
+
+// regressionSettings returns the multiplier and window of a target's
+// regression alert: its own multiplier, else the config's, else 3 times
+// the median of the last 20 checks.
+func regressionSettings(ruleMultiplier float64) (multiplier float64, window int) {
+	r := config.Get().ResponseTimeRegression
+	multiplier, window = ruleMultiplier, r.Window
+	if multiplier <= 1 {
+		multiplier = r.Multiplier
+	}
+	if multiplier <= 1 {
+		multiplier = 3
+	}
+	if window <= 0 {
+		window = 20
+	}
+	return multiplier, window
+}
+
+// regressionState reports whether the target's latest result, and the one
+// before it, were slower than multiplier times the median of the window
+// of checks before each. Failed checks are left out of the baseline, and
+// a result without a full window of history before it never regresses.
+func regressionState(targetID int64, multiplier float64, window int) (slow, wasSlow bool, median time.Duration) {
+	results, err := db.GetCheckHistory(targetID, window+2)
+	if err != nil || len(results) < window+1 {
+		return false, false, 0
+	}
+	regressed := func(i int) (bool, time.Duration) {
+		if i+window >= len(results) || isFailure(results[i].Status) {
+			return false, 0
+		}
+		var baseline []time.Duration
+		for _, r := range results[i+1 : i+1+window] {
+			if !isFailure(r.Status) {
+				baseline = append(baseline, time.Duration(r.ResponseTime)*time.Millisecond)
+			}
+		}
+		return trigger.Regressed(time.Duration(results[i].ResponseTime)*time.Millisecond, baseline, multiplier)
+	}
+	slow, median = regressed(0)
+	wasSlow, _ = regressed(1)
+	return slow, wasSlow, median
+}
+
+// notifyRegression sends a "slow" notification when the result just saved
+// on an alert_on_regression target starts a run of checks slower than
+// the recent median allows. Further slow checks don't alert again until
+// one is back within it.
+func notifyRegression(t *db.Target, r *checker.Result) {
+	if !t.AlertOnRegression {
+		return
+	}
+	multiplier, window := regressionSettings(t.RegressionMultiplier)
+	slow, wasSlow, median := regressionState(t.ID, multiplier, window)
+	if !slow || wasSlow {
+		return
+	}
+	current := r.ResponseTime.Milliseconds()
+	slog.Warn("response time regressed", "target", t.Name, "response_ms", current, "median_ms", median.Milliseconds())
+	ev := resultEvent(t, r)
+	ev.Status = "slow"
+	ev.Error = fmt.Sprintf("response time %dms is %.1f× the median of %dms over the last %d checks (alerts above %g×)",
+		current, float64(r.ResponseTime)/float64(median), median.Milliseconds(), window, multiplier)
+	notifyTarget(t, ev)
+}
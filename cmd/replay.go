@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+
+	"github.com/naru-bot/upp/internal/db"
+	"github.com/naru-bot/upp/internal/diff"
+	"github.com/naru-bot/upp/internal/trigger"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "replay <name|url|id>",
+		Short: "Show which past content changes a trigger rule would have notified on",
+		Long: `Evaluate a trigger rule against the content snapshots stored for a target
+and list, oldest first, the changes it would have notified on, to tune a
+rule against real data instead of waiting for new checks.
+
+A snapshot is stored each time the content changes, so each one stands
+for a "changed" check. The first snapshot is the baseline and snapshots
+identical to the one before (snapshot_mode always) are unchanged; neither
+notifies, whatever the rule says. Nothing is checked or saved.
+
+--trigger takes a rule in the form --trigger-if does on add and edit;
+without it the target's own rule is replayed. --trigger-scope diff
+evaluates it against only the lines each snapshot added to the one
+before, as a target with that scope is checked. When both exist, the
+summary says how the target's current rule compares. Down and error
+checks notify regardless of content and aren't replayed.
+
+Examples:
+  upp replay "My Site" --trigger "regex:(?i)out of stock"
+  upp replay shop --trigger "not_contains:Add to cart" --since 30d
+  upp replay 1 --json`,
+		Args: requireArgs(1),
+		Run:  runReplay,
+	}
+	cmd.Flags().String("trigger", "", "Trigger rule to replay (e.g. 'contains:text', 'regex:pattern'; default: the target's own)")
+	cmd.Flags().String("trigger-scope", "", "What the replayed rule looks at: content (whole snapshot) or diff (added lines); default: the rule's own")
+	cmd.Flags().String("since", "", "Only replay snapshots since a duration ago (e.g. 7d) or date (2006-01-02)")
+	rootCmd.AddCommand(cmd)
+}
+
+type replayCheck struct {
+	SnapshotID int64  `json:"snapshot_id"`
+	Time       string `json:"time"`
+	Status     string `json:"status"` // changed, baseline or unchanged
+	Fires      bool   `json:"fires"`
+	Notifies   bool   `json:"notifies"`
+}
+
+type replayOutput struct {
+	Target          string        `json:"target"`
+	URL             string        `json:"url"`
+	Rule            string        `json:"rule"`
+	Changes         int           `json:"changes"`
+	WouldNotify     int           `json:"would_notify"`
+	CurrentRule     string        `json:"current_rule,omitempty"`
+	CurrentNotifies *int          `json:"current_would_notify,omitempty"`
+	Checks          []replayCheck `json:"checks"`
+}
+
+func runReplay(cmd *cobra.Command, args []string) {
+	t, err := db.GetTarget(args[0])
+	if err != nil {
+		exitError(err.Error())
+	}
+	rule := t.TriggerRule
+	if s, _ := cmd.Flags().GetString("trigger"); s != "" {
+		if rule, err = trigger.ParseShorthand(s); err != nil {
+			exitError("--trigger: " + err.Error())
+		}
+	}
+	if rule == "" {
+		exitError(fmt.Sprintf("%s has no trigger rule; give one with --trigger", t.Name))
+	}
+	if scope, _ := cmd.Flags().GetString("trigger-scope"); scope != "" {
+		if rule, err = trigger.WithScope(rule, scope); err != nil {
+			exitError("--trigger-scope: " + err.Error())
+		}
+	}
+	if _, ok := trigger.RegressionMultiplier(rule); ok {
+		exitError("response_time_regression isn't a content rule, so there is nothing to replay")
+	}
+	// The current rule is compared only when it is a different one
+	current := ""
+	if t.TriggerRule != rule {
+		current = t.TriggerRule
+	}
+	var since time.Time
+	if s, _ := cmd.Flags().GetString("since"); s != "" {
+		if since, err = parseSince(s); err != nil {
+			exitError("--since: " + err.Error())
+		}
+	}
+
+	snaps, err := db.GetSnapshotsSince(t.ID, since)
+	if err != nil {
+		exitError(err.Error())
+	}
+	// The snapshot before the range tells whether the first in it is the
+	// baseline or a change
+	var prior *db.Snapshot
+	if !since.IsZero() {
+		if prior, err = db.GetSnapshotBefore(t.ID, since); err != nil {
+			exitError(err.Error())
+		}
+	}
+
+	out := replayOutput{
+		Target:      t.Name,
+		URL:         t.URL,
+		Rule:        trigger.Describe(rule),
+		CurrentRule: trigger.Describe(current),
+		Checks:      []replayCheck{},
+	}
+	pattern, _ := trigger.Pattern(rule)
+	currentNotifies := 0
+	var excerpts []string
+	for i, snap := range snaps {
+		if i > 0 {
+			prior = &snaps[i-1]
+		}
+		status := "changed"
+		previous := ""
+		switch {
+		case prior == nil:
+			status = "baseline"
+		case snap.Hash == prior.Hash:
+			status = "unchanged"
+		default:
+			previous = prior.Content
+		}
+		fires, err := trigger.EvaluateChange(rule, snap.Content, previous)
+		if err != nil {
+			exitError(err.Error())
+		}
+		c := replayCheck{
+			SnapshotID: snap.ID,
+			Time:       snap.CreatedAt.Format(time.RFC3339),
+			Status:     status,
+			Fires:      fires,
+			Notifies:   fires && status == "changed",
+		}
+		out.Checks = append(out.Checks, c)
+		if status == "changed" {
+			out.Changes++
+		}
+		if c.Notifies {
+			out.WouldNotify++
+		}
+		if current != "" && status == "changed" {
+			if ok, _ := trigger.EvaluateChange(current, snap.Content, previous); ok {
+				currentNotifies++
+			}
+		}
+		// A diff-scoped rule's match is shown from the lines it looked at
+		excerpt, searched := "", snap.Content
+		if previous != "" && trigger.Scope(rule) == trigger.ScopeDiff {
+			searched = strings.Join(diff.AddedLines(previous, snap.Content), "\n")
+		}
+		if pattern != nil {
+			_, _, excerpt = searchSnapshot(pattern, searched)
+		}
+		excerpts = append(excerpts, excerpt)
+	}
+	if current != "" {
+		out.CurrentNotifies = &currentNotifies
+	}
+
+	if jsonOutput {
+		printJSON(out)
+		return
+	}
+	defer pageOutput()()
+	if len(out.Checks) == 0 {
+		fmt.Printf("No snapshots stored for %s", t.Name)
+		if !since.IsZero() {
+			fmt.Printf(" since %s", formatTime(since, "2006-01-02 15:04"))
+		}
+		fmt.Println(".")
+		return
+	}
+
+	fmt.Printf("Replaying %s against %s (%s)\n\n", out.Rule, t.Name, t.URL)
+	rows := [][]string{{"TIME", "STATUS", "NOTIFY", "FIRST MATCH"}}
+	for i, c := range out.Checks {
+		ts, _ := time.Parse(time.RFC3339, c.Time)
+		notify := "-"
+		switch {
+		case c.Notifies:
+			notify = colorGreen("yes")
+		case c.Status == "changed":
+			notify = "no"
+		}
+		rows = append(rows, []string{formatTime(ts, time.DateTime), c.Status, notify, excerpts[i]})
+	}
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for j, cell := range row {
+			widths[j] = max(widths[j], runewidth.StringWidth(ansiEscape.ReplaceAllString(cell, "")))
+		}
+	}
+	sep := make([]string, len(widths))
+	for i, w := range widths {
+		sep[i] = strings.Repeat("─", w)
+	}
+	printPaddedRow(os.Stdout, rows[0], widths, ansiEscape)
+	printPaddedRow(os.Stdout, sep, widths, ansiEscape)
+	for _, row := range rows[1:] {
+		printPaddedRow(os.Stdout, row, widths, ansiEscape)
+	}
+
+	fmt.Printf("\n%d of %d change%s would have notified.\n",
+		out.WouldNotify, out.Changes, pluralize(out.Changes))
+	if out.CurrentNotifies != nil {
+		fmt.Printf("The current rule (%s) would have notified on %d.\n", out.CurrentRule, *out.CurrentNotifies)
+	}
+}
@@ -3,9 +3,13 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"runtime"
+	"strings"
+	"time"
 
+	"github.com/naru-bot/upp/internal/checker"
 	"github.com/naru-bot/upp/internal/config"
 	"github.com/naru-bot/upp/internal/db"
 	"github.com/spf13/cobra"
@@ -16,6 +20,7 @@ var (
 	noColor    bool
 	verbose    bool
 	quiet      bool
+	dbFlag     string
 )
 
 var rootCmd = &cobra.Command{
@@ -35,34 +40,124 @@ Getting started:
 
 Documentation: https://github.com/naru-bot/upp`,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		// Skip DB init for commands that don't need it
-		switch cmd.Name() {
-		case "version", "completion", "init":
+		// Skip DB init for top-level commands that don't need it
+		if p := cmd.Parent(); p != nil && !p.HasParent() {
+			switch cmd.Name() {
+			case "version", "completion", "init":
+				return nil
+			}
+		}
+		cfg := config.Load()
+		if err := setupLogging(cfg.Log); err != nil {
+			return err
+		}
+		checker.SetTransportOptions(checker.TransportOptions{
+			MaxIdleConns:        cfg.HTTP.MaxIdleConns,
+			MaxIdleConnsPerHost: cfg.HTTP.MaxIdleConnsPerHost,
+			IdleConnTimeout:     time.Duration(cfg.HTTP.IdleConnTimeout) * time.Second,
+			DisableKeepAlives:   cfg.HTTP.DisableKeepAlives,
+			DNSCacheTTL:         time.Duration(cfg.HTTP.DNSCacheTTL) * time.Second,
+		})
+		checker.SetSoftDownKeywords(cfg.SoftDownKeywords)
+		checker.SetMaxBodyBytes(cfg.HTTP.MaxBodyBytes)
+		checker.SetDefaultMaxTotalTime(time.Duration(cfg.Defaults.MaxTotalTime) * time.Second)
+		checker.SetDefaultRedirectStatus(cfg.Defaults.RedirectStatus)
+		checker.SetDefaultSnapshotMode(cfg.Defaults.SnapshotMode)
+		checker.SetDefaultFailOnEmpty(cfg.Defaults.FailOnEmpty)
+		checker.SetDefaultFirstCheckStatus(cfg.Defaults.FirstCheckStatus)
+		checker.SetDefaultCAFile(cfg.Defaults.CAFile)
+		checker.SetDefaultClientCert(cfg.Defaults.ClientCert, cfg.Defaults.ClientKey)
+		checker.SetDefaultHeaders(cfg.Headers)
+		checker.SetConfirm(cfg.ConfirmURL, cfg.ConfirmToken)
+		db.SetSnapshotCompression(cfg.Storage.CompressSnapshots)
+		path, _ := databasePath(cfg)
+		db.SetDBPath(path)
+		if cmd.Name() == "probe" {
+			// Opened by the probe itself, and only for a stored target
 			return nil
 		}
-		config.Load()
-		return db.Init()
+		return openDatabase(cfg)
 	},
 	SilenceUsage:  true,
 	SilenceErrors: true,
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		if jsonOutput {
-			printJSON(map[string]string{"error": err.Error()})
-		} else {
-			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+	if c, err := rootCmd.ExecuteC(); err != nil {
+		code := 1
+		if c.Name() == "check" {
+			code = exitUsage // flag, argument and config errors; see checkExitCode
+		}
+		exitErrorCode(err.Error(), code)
+	}
+}
+
+// setupLogging installs the default slog logger. Logs go to stderr so they
+// never mix with command output on stdout; --verbose forces debug level.
+func setupLogging(lc config.Log) error {
+	var level slog.Level
+	switch strings.ToLower(lc.Level) {
+	case "", "info":
+		level = slog.LevelInfo
+	case "debug":
+		level = slog.LevelDebug
+	case "warn", "warning":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	default:
+		return fmt.Errorf("invalid log.level %q (want debug, info, warn or error)", lc.Level)
+	}
+	if verbose {
+		level = slog.LevelDebug
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(lc.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid log.format %q (want text or json)", lc.Format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// databasePath picks the sqlite database file: --db, then the UPP_DB
+// environment variable (or WATCHDOG_DB, its older name), then storage.path
+// from config. explicit reports that the flag or environment chose it; an
+// empty path means the default location.
+func databasePath(cfg *config.Config) (path string, explicit bool) {
+	if dbFlag != "" {
+		return dbFlag, true
+	}
+	for _, env := range []string{"UPP_DB", "WATCHDOG_DB"} {
+		if v := os.Getenv(env); v != "" {
+			return v, true
 		}
-		os.Exit(1)
 	}
+	return cfg.Storage.Path, false
+}
+
+// openDatabase opens the database databasePath picks.
+func openDatabase(cfg *config.Config) error {
+	if _, explicit := databasePath(cfg); explicit {
+		// A file picked for this run also overrides storage.dsn
+		return db.InitWithPath(db.GetDBPath())
+	}
+	return db.InitWithDSN(cfg.Storage.DSN)
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&dbFlag, "db", "", "SQLite database file (overrides UPP_DB and storage.path)")
 	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Output in JSON format (AI-friendly)")
 	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colored output")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
 	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Suppress non-essential output")
+	rootCmd.PersistentFlags().BoolVar(&noPager, "no-pager", false, "Don't page long output through display.pager or $PAGER")
 }
 
 func printJSON(v interface{}) {
@@ -72,12 +167,17 @@ func printJSON(v interface{}) {
 }
 
 func exitError(msg string) {
+	exitErrorCode(msg, 1)
+}
+
+// exitErrorCode is exitError for commands with documented exit codes.
+func exitErrorCode(msg string, code int) {
 	if jsonOutput {
 		printJSON(map[string]string{"error": msg})
 	} else {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", msg)
 	}
-	os.Exit(1)
+	os.Exit(code)
 }
 
 // Color helpers
@@ -116,6 +216,69 @@ func colorBold(s string) string {
 	return "\033[1m" + s + "\033[0m"
 }
 
+// stdoutIsTerminal reports whether stdout is an interactive terminal, as
+// opposed to a pipe or file.
+func stdoutIsTerminal() bool {
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// formatTime renders a timestamp for display in the configured zone and
+// format (display.timezone, display.time_format). layout is the command's
+// own format, used when no format is configured. JSON keeps RFC3339.
+func formatTime(t time.Time, layout string) string {
+	cfg := config.Get()
+	return t.In(cfg.Location()).Format(cfg.TimeLayout(layout))
+}
+
+// displayTime is formatTime plus, with display.relative_time on, how long
+// ago (or from now) t is: "2026-01-02T15:04:05Z (3m ago)".
+func displayTime(t time.Time, layout string) string {
+	s := formatTime(t, layout)
+	if config.Get().Display.RelativeTime {
+		s += " (" + relativeTime(t) + ")"
+	}
+	return s
+}
+
+// relativeTime describes t relative to now: "3m ago", "in 14d", "just now".
+func relativeTime(t time.Time) string {
+	d := time.Until(t)
+	if d < 0 {
+		d = -d
+	}
+	if d < time.Second {
+		return "just now"
+	}
+	if t.After(time.Now()) {
+		return "in " + humanizeDuration(d)
+	}
+	return humanizeDuration(d) + " ago"
+}
+
+// humanizeDuration rounds d to its largest whole unit: 45s, 12m, 3h, 14d.
+func humanizeDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d/time.Second))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	}
+}
+
+// colorHighlight marks a span inside already colored text. It only toggles
+// reverse video, so the surrounding color carries on after the span.
+func colorHighlight(s string) string {
+	if noColor || jsonOutput {
+		return s
+	}
+	return "\033[7m" + s + "\033[27m"
+}
+
 var Version = "dev"
 
 func init() {
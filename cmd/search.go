@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+
+	"github.com/naru-bot/upp/internal/db"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "search <name|url|id> <pattern>",
+		Short: "Search a target's stored snapshots for text",
+		Long: `Search the content snapshots stored for a target and list the ones that
+match, oldest first, to answer questions like "when did the page first
+contain X?".
+
+A snapshot is stored each time the content changes, so a matching snapshot
+means the page contained the pattern from that time until the next one.
+The pattern is plain text unless --regex is given.
+
+Examples:
+  upp search "My Site" "Out of stock"
+  upp search 1 'v[0-9]+\.[0-9]+' --regex
+  upp search shop "sale" -i --since 30d
+  upp search shop "sale" --json`,
+		Args: requireArgs(2),
+		Run:  runSearch,
+	}
+	cmd.Flags().BoolP("regex", "e", false, "Treat the pattern as a regular expression")
+	cmd.Flags().BoolP("ignore-case", "i", false, "Match case-insensitively")
+	cmd.Flags().String("since", "", "Only search snapshots since a duration ago (e.g. 7d) or date (2006-01-02)")
+	rootCmd.AddCommand(cmd)
+}
+
+type searchMatch struct {
+	SnapshotID int64    `json:"snapshot_id"`
+	Time       string   `json:"time"`
+	Count      int      `json:"count"`
+	Lines      []string `json:"lines"`
+}
+
+type searchOutput struct {
+	Target            string        `json:"target"`
+	URL               string        `json:"url"`
+	Pattern           string        `json:"pattern"`
+	SnapshotsSearched int           `json:"snapshots_searched"`
+	Matches           []searchMatch `json:"matches"`
+	FirstMatch        string        `json:"first_match,omitempty"`
+	LastMatch         string        `json:"last_match,omitempty"`
+	MatchesLatest     bool          `json:"matches_latest"`
+}
+
+func runSearch(cmd *cobra.Command, args []string) {
+	t, err := db.GetTarget(args[0])
+	if err != nil {
+		exitError(err.Error())
+	}
+	pattern := args[1]
+	isRegex, _ := cmd.Flags().GetBool("regex")
+	ignoreCase, _ := cmd.Flags().GetBool("ignore-case")
+	expr := pattern
+	if !isRegex {
+		expr = regexp.QuoteMeta(pattern)
+	}
+	if ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		exitError(fmt.Sprintf("invalid pattern: %v", err))
+	}
+	var since time.Time
+	if s, _ := cmd.Flags().GetString("since"); s != "" {
+		if since, err = parseSince(s); err != nil {
+			exitError("--since: " + err.Error())
+		}
+	}
+
+	snaps, err := db.GetSnapshotsSince(t.ID, since)
+	if err != nil {
+		exitError(err.Error())
+	}
+
+	out := searchOutput{
+		Target:            t.Name,
+		URL:               t.URL,
+		Pattern:           pattern,
+		SnapshotsSearched: len(snaps),
+		Matches:           []searchMatch{},
+	}
+	var excerpts []string
+	for i, snap := range snaps {
+		count, lines, excerpt := searchSnapshot(re, snap.Content)
+		if count == 0 {
+			continue
+		}
+		out.Matches = append(out.Matches, searchMatch{
+			SnapshotID: snap.ID,
+			Time:       snap.CreatedAt.Format(time.RFC3339),
+			Count:      count,
+			Lines:      lines,
+		})
+		excerpts = append(excerpts, excerpt)
+		if i == len(snaps)-1 {
+			out.MatchesLatest = true
+		}
+	}
+	if n := len(out.Matches); n > 0 {
+		out.FirstMatch = out.Matches[0].Time
+		out.LastMatch = out.Matches[n-1].Time
+	}
+
+	if jsonOutput {
+		printJSON(out)
+		return
+	}
+	defer pageOutput()()
+	if len(snaps) == 0 {
+		fmt.Printf("No snapshots stored for %s", t.Name)
+		if !since.IsZero() {
+			fmt.Printf(" since %s", formatTime(since, "2006-01-02 15:04"))
+		}
+		fmt.Println(".")
+		return
+	}
+	if len(out.Matches) == 0 {
+		fmt.Printf("No match for %q in %d snapshot(s) of %s.\n", pattern, len(snaps), t.Name)
+		return
+	}
+
+	rows := [][]string{{"TIME", "MATCHES", "FIRST MATCHING LINE"}}
+	for i, m := range out.Matches {
+		ts, _ := time.Parse(time.RFC3339, m.Time)
+		rows = append(rows, []string{
+			formatTime(ts, "2006-01-02 15:04:05"),
+			fmt.Sprintf("%d", m.Count),
+			excerpts[i],
+		})
+	}
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for j, cell := range row {
+			widths[j] = max(widths[j], runewidth.StringWidth(ansiEscape.ReplaceAllString(cell, "")))
+		}
+	}
+	sep := make([]string, len(widths))
+	for i, w := range widths {
+		sep[i] = strings.Repeat("─", w)
+	}
+	printPaddedRow(os.Stdout, rows[0], widths, ansiEscape)
+	printPaddedRow(os.Stdout, sep, widths, ansiEscape)
+	for _, row := range rows[1:] {
+		printPaddedRow(os.Stdout, row, widths, ansiEscape)
+	}
+
+	first, _ := time.Parse(time.RFC3339, out.FirstMatch)
+	fmt.Printf("\n%d of %d snapshot(s) match; first matched %s.\n",
+		len(out.Matches), len(snaps), formatTime(first, "2006-01-02 15:04:05"))
+	if !out.MatchesLatest {
+		fmt.Println(colorYellow("The latest snapshot no longer matches."))
+	}
+}
+
+// searchSnapshot counts the pattern's matches in content and returns the
+// trimmed matching lines plus a highlighted excerpt of the first one.
+func searchSnapshot(re *regexp.Regexp, content string) (int, []string, string) {
+	count := 0
+	var lines []string
+	excerpt := ""
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		locs := re.FindAllStringIndex(line, -1)
+		if len(locs) == 0 {
+			continue
+		}
+		count += len(locs)
+		lines = append(lines, line)
+		if excerpt == "" {
+			excerpt = searchExcerpt(line, locs[0])
+		}
+	}
+	return count, lines, excerpt
+}
+
+// searchExcerpt cuts line down to the text around the match at loc, with
+// the match highlighted.
+func searchExcerpt(line string, loc []int) string {
+	const context = 30
+	start, end := loc[0], loc[1]
+	before, match, after := line[:start], line[start:end], line[end:]
+	if r := []rune(before); len(r) > context {
+		before = "…" + string(r[len(r)-context:])
+	}
+	if r := []rune(after); len(r) > context {
+		after = string(r[:context]) + "…"
+	}
+	if r := []rune(match); len(r) > 2*context {
+		match = string(r[:2*context]) + "…"
+	}
+	return before + colorHighlight(match) + after
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/naru-bot/upp/internal/config"
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// isStale reports whether t has gone unchecked for longer than
+// thresholds.stale_factor times its interval as of now, given when it was
+// last checked. That points at the scheduler rather than the target: the
+// daemon stopped, fell behind or skipped it. Paused targets and targets
+// never checked aren't stale.
+func isStale(t *db.Target, lastChecked, now time.Time) bool {
+	factor := config.Get().StaleFactor()
+	if factor == 0 || t.Paused || lastChecked.IsZero() || t.Interval <= 0 {
+		return false
+	}
+	maxAge := time.Duration(float64(t.Interval) * factor * float64(time.Second))
+	return now.Sub(lastChecked) > maxAge
+}
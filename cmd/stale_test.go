@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/naru-bot/upp/internal/config"
+	"github.com/naru-bot/upp/internal/db"
+)
+
+func TestIsStale(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // keep a real config file out of the test
+	cfg := config.Get()
+	defer func(f float64) { cfg.Thresholds.StaleFactor = f }(cfg.Thresholds.StaleFactor)
+
+	now := time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		factor      float64
+		target      db.Target
+		lastChecked time.Time
+		want        bool
+	}{
+		{name: "within the default factor", target: db.Target{Interval: 60}, lastChecked: now.Add(-3 * time.Minute), want: false},
+		{name: "past the default factor", target: db.Target{Interval: 60}, lastChecked: now.Add(-3*time.Minute - time.Second), want: true},
+		{name: "custom factor", factor: 1.5, target: db.Target{Interval: 60}, lastChecked: now.Add(-2 * time.Minute), want: true},
+		{name: "negative factor disables", factor: -1, target: db.Target{Interval: 60}, lastChecked: now.Add(-time.Hour), want: false},
+		{name: "paused", target: db.Target{Interval: 60, Paused: true}, lastChecked: now.Add(-time.Hour), want: false},
+		{name: "never checked", target: db.Target{Interval: 60}, want: false},
+		{name: "no interval", target: db.Target{}, lastChecked: now.Add(-time.Hour), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Thresholds.StaleFactor = tt.factor
+			if got := isStale(&tt.target, tt.lastChecked, now); got != tt.want {
+				t.Errorf("isStale(interval %ds, factor %v, last checked %s ago) = %v, want %v",
+					tt.target.Interval, tt.factor, now.Sub(tt.lastChecked), got, tt.want)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/mattn/go-runewidth"
 
+	"github.com/naru-bot/upp/internal/config"
 	"github.com/naru-bot/upp/internal/db"
 	"github.com/spf13/cobra"
 )
@@ -17,6 +18,7 @@ import (
 var availableColumns = []string{
 	"name", "url", "type", "tags", "uptime", "avg", "min", "max",
 	"checks", "changes", "trend", "status", "last_checked", "interval",
+	"bandwidth",
 }
 
 var defaultColumns = []string{
@@ -29,11 +31,17 @@ func init() {
 		Short: "Show uptime statistics and status summary",
 		Long: `Display uptime percentage, average response time, and recent status.
 
-Without arguments, shows summary for all targets.
+Without arguments, shows summary for all targets. A target whose last
+check is older than thresholds.stale_factor times its interval (3 by
+default) is marked [stale]: the daemon stopped, fell behind or skipped it.
 
 Customize columns with --columns (comma-separated):
   name, url, type, tags, uptime, avg, min, max,
-  checks, changes, trend, status, last_checked, interval
+  checks, changes, trend, status, last_checked, interval, bandwidth
+
+--since sets the stats window to a duration (90m, 12h, 7d) or a date
+(2026-01-31) instead of one of the --period presets, and adds a line with
+the bandwidth the checks used over that window.
 
 Examples:
   upp status
@@ -42,10 +50,12 @@ Examples:
   upp status --tag my-sites
   upp status --columns name,uptime,avg,status
   upp status --columns name,url,tags,uptime,trend,status
-  upp status --columns all`,
+  upp status --columns all
+  upp status --since 30d           # includes bandwidth totals`,
 		Run: runStatus,
 	}
 	cmd.Flags().StringP("period", "p", "24h", "Stats period: 1h, 24h, 7d, 30d")
+	cmd.Flags().String("since", "", "Stats window as a duration (e.g. 12h, 7d) or date (2006-01-02); overrides --period and reports bandwidth")
 	cmd.Flags().String("tag", "", "Filter targets by tag")
 	cmd.Flags().String("columns", "", "Columns to display (comma-separated, or 'all')")
 	rootCmd.AddCommand(cmd)
@@ -64,9 +74,12 @@ type statusOutput struct {
 	LastStatus    string  `json:"last_status"`
 	LastError     string  `json:"last_error,omitempty"`
 	LastChecked   string  `json:"last_checked,omitempty"`
+	Stale         bool    `json:"stale,omitempty"` // unchecked for longer than thresholds.stale_factor intervals
 	Changes       int     `json:"content_changes"`
 	Sparkline     string  `json:"sparkline,omitempty"`
 	Interval      int     `json:"interval_seconds"`
+	RequestBytes  int64   `json:"request_bytes"`
+	ResponseBytes int64   `json:"response_bytes"`
 }
 
 func parseColumns(input string) []string {
@@ -133,6 +146,8 @@ func columnHeader(col string) string {
 		return "LAST CHECKED"
 	case "interval":
 		return "INTERVAL"
+	case "bandwidth":
+		return "BANDWIDTH"
 	default:
 		return strings.ToUpper(col)
 	}
@@ -154,17 +169,7 @@ func columnValue(col string, o *statusOutput) string {
 	case "tags":
 		return truncate(o.Tags, 20)
 	case "uptime":
-		s := fmt.Sprintf("%.1f%%", o.UptimePercent)
-		if !noColor && !jsonOutput {
-			if o.UptimePercent >= 99.9 {
-				s = colorGreen(s)
-			} else if o.UptimePercent >= 95 {
-				s = colorYellow(s)
-			} else if o.TotalChecks > 0 {
-				s = colorRed(s)
-			}
-		}
-		return s
+		return uptimeCell(o.UptimePercent, o.TotalChecks)
 	case "avg":
 		return fmt.Sprintf("%.0fms", o.AvgResponseMs)
 	case "min":
@@ -181,32 +186,33 @@ func columnValue(col string, o *statusOutput) string {
 		s := o.LastStatus
 		if !noColor && !jsonOutput {
 			switch o.LastStatus {
-			case "up", "unchanged":
-				s = colorGreen("● " + o.LastStatus)
-			case "changed":
-				s = colorYellow("△ " + o.LastStatus)
-			case "down", "error":
-				s = colorRed("✗ " + o.LastStatus)
+			case "up", "baseline", "unchanged", "changed", "redirect", "down-local-only", "down", "error":
+				s = colorStatus(o.LastStatus, statusIcon(o.LastStatus)+" "+o.LastStatus)
 			}
 		}
 		if o.LastError != "" && (o.LastStatus == "down" || o.LastStatus == "error") {
 			shortErr := shortenError(o.LastError)
 			if !noColor && !jsonOutput {
-				shortErr = colorRed(shortErr)
+				shortErr = colorStatus("down", shortErr)
 			}
 			s += " " + shortErr
 		}
+		if o.Stale {
+			s += " " + colorYellow("[stale]")
+		}
 		return s
 	case "last_checked":
 		if o.LastChecked == "" {
 			return "—"
 		}
 		if t, err := time.Parse(time.RFC3339, o.LastChecked); err == nil {
-			return time.Since(t).Round(time.Second).String() + " ago"
+			return relativeTime(t)
 		}
 		return o.LastChecked
 	case "interval":
-		return fmt.Sprintf("%ds", o.Interval)
+		return formatSeconds(o.Interval)
+	case "bandwidth":
+		return formatBytes(o.RequestBytes + o.ResponseBytes)
 	default:
 		return ""
 	}
@@ -215,6 +221,13 @@ func columnValue(col string, o *statusOutput) string {
 func runStatus(cmd *cobra.Command, args []string) {
 	period, _ := cmd.Flags().GetString("period")
 	since := parsePeriod(period)
+	sinceFlag, _ := cmd.Flags().GetString("since")
+	if sinceFlag != "" {
+		var err error
+		if since, err = parseSince(sinceFlag); err != nil {
+			exitError("--since: " + err.Error())
+		}
+	}
 	tag, _ := cmd.Flags().GetString("tag")
 	colStr, _ := cmd.Flags().GetString("columns")
 	cols := parseColumns(colStr)
@@ -278,8 +291,10 @@ func runStatus(cmd *cobra.Command, args []string) {
 		lastChecked := ""
 		var minMs, maxMs int64
 		var responseTimes []int64
+		stale := false
 		for i, r := range results {
 			if i == 0 {
+				stale = isStale(&t, r.CheckedAt, time.Now())
 				lastStatus = r.Status
 				lastError = r.Error
 				lastChecked = r.CheckedAt.Format(time.RFC3339)
@@ -299,6 +314,7 @@ func runStatus(cmd *cobra.Command, args []string) {
 		}
 
 		spark := buildSparkline(responseTimes, 20)
+		reqBytes, respBytes, _ := db.GetBandwidth(t.ID, since)
 
 		tags := ""
 		if tagMap != nil {
@@ -320,9 +336,12 @@ func runStatus(cmd *cobra.Command, args []string) {
 			LastStatus:    lastStatus,
 			LastError:     lastError,
 			LastChecked:   lastChecked,
+			Stale:         stale,
 			Changes:       changes,
 			Sparkline:     spark,
 			Interval:      t.Interval,
+			RequestBytes:  reqBytes,
+			ResponseBytes: respBytes,
 		}
 		outputs = append(outputs, out)
 	}
@@ -380,6 +399,63 @@ func runStatus(cmd *cobra.Command, args []string) {
 	for _, row := range allRows[1:] {
 		printPaddedRow(os.Stdout, row, colWidths, ansiRe)
 	}
+
+	if sinceFlag != "" {
+		var sent, received int64
+		checks := 0
+		for _, o := range outputs {
+			sent += o.RequestBytes
+			received += o.ResponseBytes
+			checks += o.TotalChecks
+		}
+		fmt.Printf("\nBandwidth since %s: %s received, %s sent over %d checks\n",
+			formatTime(since, "2006-01-02 15:04"), formatBytes(received), formatBytes(sent), checks)
+	}
+}
+
+// parseSince reads --since: a duration back from now (anything
+// parseSeconds accepts, e.g. 90m or 7d) or a date / RFC3339 timestamp.
+func parseSince(s string) (time.Time, error) {
+	if secs, err := parseSeconds(s); err == nil {
+		return time.Now().Add(-time.Duration(secs) * time.Second), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid value %q (want a duration like 7d or a date like 2006-01-02)", s)
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// uptimeCell formats an uptime percentage, colored green from 99.9%, amber
+// from 95% and red below.
+func uptimeCell(pct float64, checks int) string {
+	s := fmt.Sprintf("%.1f%%", pct)
+	if !noColor && !jsonOutput {
+		if pct >= 99.9 {
+			s = colorStatus("up", s)
+		} else if pct >= 95 {
+			s = colorStatus("changed", s)
+		} else if checks > 0 {
+			s = colorStatus("down", s)
+		}
+	}
+	return s
 }
 
 func printPaddedRow(w *os.File, row []string, widths []int, ansiRe *regexp.Regexp) {
@@ -401,13 +477,34 @@ func printPaddedRow(w *os.File, row []string, widths []int, ansiRe *regexp.Regex
 }
 
 func shortenError(err string) string {
-	if idx := strings.LastIndex(err, ": "); idx != -1 {
+	return "(" + briefError(err) + ")"
+}
+
+// briefError keeps the most specific part of a wrapped error (after the last
+// ": ") and fits it to the configured inline width.
+func briefError(err string) string {
+	// Of a retried check's "attempt 1: ...; attempt 2: ..." the first
+	// failure is shown; the later ones often just follow from it
+	if rest, ok := strings.CutPrefix(err, "attempt 1: "); ok {
+		first, _, _ := strings.Cut(rest, "; attempt ")
+		err = fmt.Sprintf("%d attempts, first: %s", strings.Count(rest, "; attempt ")+1, first)
+	} else if idx := strings.LastIndex(err, ": "); idx != -1 {
 		err = err[idx+2:]
 	}
-	if len(err) > 40 {
-		err = err[:37] + "..."
+	return errorSnippet(err, config.Get().ErrorWidth())
+}
+
+// errorSnippet flattens an error onto one line and truncates it to width
+// characters for inline display.
+func errorSnippet(err string, width int) string {
+	err = strings.Join(strings.Fields(err), " ")
+	if r := []rune(err); len(r) > width {
+		if width <= 3 {
+			return string(r[:width])
+		}
+		err = string(r[:width-3]) + "..."
 	}
-	return "(" + err + ")"
+	return err
 }
 
 func buildSparkline(values []int64, maxLen int) string {
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// stdinFlagNames maps the keys of a target read by 'upp add -' to the add
+// flags that set them, where the flag isn't just the key with dashes. The
+// keys are those of a target in JSON output, so a 'upp list --json' item
+// or 'upp view --json' output can be fed back in.
+var stdinFlagNames = map[string]string{
+	"interval_seconds":   "interval",
+	"trigger_rule":       "trigger-if",
+	"jq_filter":          "jq",
+	"hash_headers":       "hash-header",
+	"soft_down_keywords": "soft-down-keyword",
+	"score_rules":        "score",
+	"cert_pin":           "pin-cert",
+	"head_only":          "head",
+	"retention_days":     "retention",
+	"tags":               "tag",
+}
+
+// stdinStateKeys describe an existing target rather than how to check one;
+// they are accepted so JSON output round-trips, and ignored.
+var stdinStateKeys = map[string]bool{"id": true, "created_at": true, "paused": true, "muted": true}
+
+// readTargetStdin reads a target as a JSON object from r and applies its
+// fields as add flags, except flags given on the command line, which win.
+// 'upp view --json' output is read from its "target" object. It returns
+// the target's URL.
+func readTargetStdin(cmd *cobra.Command, r io.Reader) (string, error) {
+	var fields map[string]interface{}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		if err == io.EOF {
+			return "", fmt.Errorf("no target on stdin (want a JSON object such as {\"url\": \"https://example.com\"})")
+		}
+		return "", fmt.Errorf("invalid JSON: %v", err)
+	}
+	if target, ok := fields["target"].(map[string]interface{}); ok && fields["url"] == nil {
+		fields = target
+	}
+	url, _ := fields["url"].(string)
+	if url == "" {
+		return "", fmt.Errorf(`the JSON object needs a "url"`)
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	flags := cmd.Flags()
+	for _, key := range keys {
+		v := fields[key]
+		if key == "url" || stdinStateKeys[key] || v == nil {
+			continue
+		}
+		switch key {
+		case "retries":
+			// -1 is stored for --no-retry
+			if flags.Changed("retries") || flags.Changed("no-retry") {
+				continue
+			}
+			if n, ok := v.(json.Number); ok && n.String() == "-1" {
+				flags.Set("no-retry", "true")
+				continue
+			}
+		case "retention_days":
+			if n, ok := v.(json.Number); ok && n.String() == "-1" {
+				v = "forever"
+			}
+		case "headers":
+			// Accepted as an object as well as the stored JSON string
+			if m, ok := v.(map[string]interface{}); ok {
+				b, err := json.Marshal(m)
+				if err != nil {
+					return "", fmt.Errorf(`"headers": %v`, err)
+				}
+				v = string(b)
+			}
+		case "meta":
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return "", fmt.Errorf(`"meta" must be an object of strings`)
+			}
+			if flags.Changed("meta") {
+				continue
+			}
+			metaKeys := make([]string, 0, len(m))
+			for k := range m {
+				metaKeys = append(metaKeys, k)
+			}
+			sort.Strings(metaKeys)
+			for _, k := range metaKeys {
+				s, ok := m[k].(string)
+				if !ok {
+					return "", fmt.Errorf(`"meta.%s" must be a string`, k)
+				}
+				if err := flags.Set("meta", k+"="+s); err != nil {
+					return "", fmt.Errorf(`"meta.%s": %v`, k, err)
+				}
+			}
+			continue
+		}
+
+		name, ok := stdinFlagNames[key]
+		if !ok {
+			name = strings.ReplaceAll(key, "_", "-")
+		}
+		if flags.Lookup(name) == nil {
+			return "", fmt.Errorf("unknown field %q", key)
+		}
+		if flags.Changed(name) {
+			continue
+		}
+		values, err := stdinFlagValues(v)
+		if err != nil {
+			return "", fmt.Errorf("%q: %v", key, err)
+		}
+		for _, s := range values {
+			if err := flags.Set(name, s); err != nil {
+				return "", fmt.Errorf("%q: %v", key, err)
+			}
+		}
+	}
+	return url, nil
+}
+
+// stdinFlagValues renders a JSON value as flag arguments: one per scalar,
+// one per element of an array.
+func stdinFlagValues(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, nil
+	case json.Number:
+		return []string{v.String()}, nil
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case []interface{}:
+		var out []string
+		for _, elem := range v {
+			s, err := stdinFlagValues(elem)
+			if err != nil {
+				return nil, err
+			}
+			if _, nested := elem.([]interface{}); nested {
+				return nil, fmt.Errorf("nested arrays aren't supported")
+			}
+			out = append(out, s...)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unsupported value %v", v)
+}
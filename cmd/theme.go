@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/naru-bot/upp/internal/config"
+)
+
+// theme is how statuses are drawn: an ANSI color for each kind of status
+// and a symbol for each status. An empty color leaves text uncolored.
+type theme struct {
+	ok, changed, bad string // SGR parameters, e.g. "32" or "1;92"
+	symbols          map[string]string
+}
+
+// defaultSymbols are the status symbols every theme starts from; "unknown"
+// covers statuses without their own.
+var defaultSymbols = map[string]string{
+	"up":              "✓",
+	"baseline":        "○", // first content check, stored to compare against
+	"unchanged":       "✓",
+	"changed":         "△",
+	"redirect":        "↪",
+	"down-local-only": "◐", // down here, up from confirm_url
+	"down":            "✗",
+	"unknown":         "?",
+}
+
+// themes are the display.theme choices. colorblind-safe uses blue and
+// orange, which stay apart for red-green color blindness; monochrome drops
+// color and bolds failures, so the symbols carry the meaning.
+var themes = map[string]theme{
+	"default":         {ok: "32", changed: "33", bad: "31"},
+	"high-contrast":   {ok: "1;92", changed: "1;93", bad: "1;91", symbols: map[string]string{"up": "✔", "unchanged": "✔", "changed": "▲", "down": "✖"}},
+	"colorblind-safe": {ok: "34", changed: "38;5;220", bad: "38;5;208", symbols: map[string]string{"changed": "◆", "down": "✖"}},
+	"monochrome":      {bad: "1"},
+}
+
+var activeTheme *theme
+
+// currentTheme returns display.theme with the display.symbols overrides
+// applied. An unknown theme falls back to the default.
+func currentTheme() *theme {
+	if activeTheme != nil {
+		return activeTheme
+	}
+	display := config.Get().Display
+	t, ok := themes[strings.ToLower(display.Theme)]
+	if !ok {
+		t = themes["default"]
+	}
+	symbols := make(map[string]string, len(defaultSymbols))
+	for _, set := range []map[string]string{defaultSymbols, t.symbols, display.Symbols} {
+		for status, sym := range set {
+			if sym != "" {
+				symbols[strings.ToLower(status)] = sym
+			}
+		}
+	}
+	t.symbols = symbols
+	activeTheme = &t
+	return activeTheme
+}
+
+func statusIcon(status string) string {
+	symbols := currentTheme().symbols
+	if sym, ok := symbols[status]; ok {
+		return sym
+	}
+	return symbols["unknown"]
+}
+
+// colorStatus colors s the way the theme shows status: up, baseline and
+// unchanged as ok, changed, redirect and down-local-only as a change, down and error
+// as a failure.
+func colorStatus(status, s string) string {
+	if noColor || jsonOutput {
+		return s
+	}
+	t := currentTheme()
+	var sgr string
+	switch status {
+	case "up", "baseline", "unchanged":
+		sgr = t.ok
+	case "changed", "redirect", "down-local-only":
+		sgr = t.changed
+	case "down", "error":
+		sgr = t.bad
+	}
+	if sgr == "" {
+		return s
+	}
+	return "\033[" + sgr + "m" + s + "\033[0m"
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/naru-bot/upp/internal/checker"
+	"github.com/naru-bot/upp/internal/db"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "tls <name|url|id>",
+		Short: "Inspect the TLS connection of an https target",
+		Long: `Connect to an https target and report the negotiated TLS version and
+cipher suite, whether the presented certificate chain validates, and every
+certificate in the chain (leaf first).
+
+The chain is validated against the system roots even for --insecure targets,
+so a self-signed or misconfigured chain is reported rather than hidden.
+A URL that isn't a saved target is inspected directly.
+
+Examples:
+  upp tls "My Site"
+  upp tls https://example.com
+  upp tls 1 --json`,
+		Args: requireArgs(1),
+		Run:  runTLS,
+	}
+	rootCmd.AddCommand(cmd)
+}
+
+type tlsOutput struct {
+	Target       string `json:"target"`
+	URL          string `json:"url"`
+	ExpectMinTLS string `json:"expect_min_tls,omitempty"`
+	MeetsMinTLS  *bool  `json:"meets_min_tls,omitempty"`
+	*checker.TLSInfo
+}
+
+func runTLS(cmd *cobra.Command, args []string) {
+	t, err := db.GetTarget(args[0])
+	if err != nil {
+		if !strings.HasPrefix(args[0], "https://") {
+			exitError(err.Error())
+		}
+		t = &db.Target{Name: args[0], URL: args[0], Type: "http"}
+	}
+	if t.Type != "" && t.Type != "http" && t.Type != "https" && t.Type != "statuspage" {
+		exitError(fmt.Sprintf("%s is a %s target; tls only inspects http targets", t.Name, t.Type))
+	}
+
+	info, err := checker.InspectTLS(cmd.Context(), t)
+	if err != nil {
+		exitError(err.Error())
+	}
+
+	out := tlsOutput{Target: t.Name, URL: t.URL, ExpectMinTLS: t.ExpectMinTLS, TLSInfo: info}
+	if t.ExpectMinTLS != "" {
+		min, _, _ := checker.ParseTLSVersion(t.ExpectMinTLS)
+		v, _, _ := checker.ParseTLSVersion(info.Version)
+		ok := v >= min
+		out.MeetsMinTLS = &ok
+	}
+
+	if jsonOutput {
+		printJSON(out)
+		return
+	}
+
+	fmt.Printf("Target: %s (%s)\n", t.Name, t.URL)
+	version := info.Version
+	if out.MeetsMinTLS != nil {
+		if *out.MeetsMinTLS {
+			version += fmt.Sprintf(" (meets minimum %s)", t.ExpectMinTLS)
+		} else {
+			version += fmt.Sprintf(" (below minimum %s)", t.ExpectMinTLS)
+			if !noColor {
+				version = colorRed(version)
+			}
+		}
+	}
+	fmt.Printf("Version: %s\n", version)
+	fmt.Printf("Cipher: %s\n", info.Cipher)
+	chain := chainLabel(info.ChainValid)
+	if !info.ChainValid && info.ChainError != "" {
+		chain += " — " + info.ChainError
+	}
+	if !noColor {
+		if info.ChainValid {
+			chain = colorGreen(chain)
+		} else {
+			chain = colorRed(chain)
+		}
+	}
+	fmt.Printf("Chain: %s\n", chain)
+
+	for i, c := range info.Chain {
+		label := "Intermediate"
+		if i == 0 {
+			label = "Leaf"
+		}
+		fmt.Printf("\n[%d] %s\n", i, label)
+		fmt.Printf("  Subject: %s\n", c.Subject)
+		fmt.Printf("  Issuer:  %s\n", c.Issuer)
+		if len(c.DNSNames) > 0 {
+			fmt.Printf("  Names:   %s\n", strings.Join(c.DNSNames, ", "))
+		}
+		days := int(time.Until(c.NotAfter).Hours() / 24)
+		fmt.Printf("  Valid:   %s to %s (%dd left)\n", c.NotBefore.Format("2006-01-02"), c.NotAfter.Format("2006-01-02"), days)
+		fmt.Printf("  SHA-256: %s\n", c.Fingerprint)
+	}
+}
+
+func chainLabel(valid bool) string {
+	if valid {
+		return "valid"
+	}
+	return "invalid"
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+
+	"github.com/naru-bot/upp/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// topMetrics are the --by values, each ranking worst first.
+var topMetrics = []string{"response-time", "uptime", "incidents"}
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "top",
+		Short: "Rank targets by slowest response, lowest uptime or most incidents",
+		Long: `Rank targets by a metric over recent check history, worst first, to find
+where to look when there are many targets.
+
+--by picks the metric:
+  response-time  highest average response time
+  uptime         lowest uptime
+  incidents      most outages (runs of down or error checks)
+
+Targets without checks in the window are left out.
+
+Examples:
+  upp top
+  upp top --by uptime --since 7d
+  upp top --by incidents --limit 5 --tag production
+  upp top --json`,
+		Args: cobra.NoArgs,
+		Run:  runTop,
+	}
+	cmd.Flags().String("by", "response-time", "Metric to rank by: "+strings.Join(topMetrics, ", "))
+	cmd.Flags().IntP("limit", "l", 10, "Number of targets to show (0 for all)")
+	cmd.Flags().String("since", "24h", "Window as a duration (e.g. 12h, 7d) or date (2006-01-02)")
+	cmd.Flags().String("tag", "", "Only rank targets with this tag")
+	rootCmd.AddCommand(cmd)
+}
+
+type topOutput struct {
+	Rank          int     `json:"rank"`
+	Target        string  `json:"target"`
+	URL           string  `json:"url"`
+	UptimePercent float64 `json:"uptime_percent"`
+	AvgResponseMs float64 `json:"avg_response_ms"`
+	MaxResponseMs int64   `json:"max_response_ms"`
+	Incidents     int     `json:"incidents"`
+	TotalChecks   int     `json:"total_checks"`
+}
+
+func runTop(cmd *cobra.Command, args []string) {
+	by, _ := cmd.Flags().GetString("by")
+	if !slices.Contains(topMetrics, by) {
+		exitError(fmt.Sprintf("--by must be one of %s", strings.Join(topMetrics, ", ")))
+	}
+	limit, _ := cmd.Flags().GetInt("limit")
+	if limit < 0 {
+		exitError("--limit must not be negative")
+	}
+	sinceFlag, _ := cmd.Flags().GetString("since")
+	since, err := parseSince(sinceFlag)
+	if err != nil {
+		exitError("--since: " + err.Error())
+	}
+	tag, _ := cmd.Flags().GetString("tag")
+
+	var targets []db.Target
+	if tag != "" {
+		targets, err = db.ListTargetsByTag(tag)
+	} else {
+		targets, err = db.ListTargets()
+	}
+	if err != nil {
+		exitError(err.Error())
+	}
+	byID := make(map[int64]*db.Target, len(targets))
+	for i := range targets {
+		byID[targets[i].ID] = &targets[i]
+	}
+
+	stats, err := db.GetTargetStats(since)
+	if err != nil {
+		exitError(err.Error())
+	}
+	outputs := []topOutput{}
+	for _, st := range stats {
+		t, ok := byID[st.TargetID]
+		if !ok || st.Checks == 0 {
+			continue
+		}
+		outputs = append(outputs, topOutput{
+			Target:        t.Name,
+			URL:           t.URL,
+			UptimePercent: float64(st.Up) / float64(st.Checks) * 100,
+			AvgResponseMs: st.AvgResponseMs,
+			MaxResponseMs: st.MaxResponseMs,
+			Incidents:     st.Outages,
+			TotalChecks:   st.Checks,
+		})
+	}
+
+	slices.SortStableFunc(outputs, func(a, b topOutput) int {
+		switch by {
+		case "uptime":
+			return cmp.Or(cmp.Compare(a.UptimePercent, b.UptimePercent), cmp.Compare(b.Incidents, a.Incidents), strings.Compare(a.Target, b.Target))
+		case "incidents":
+			return cmp.Or(cmp.Compare(b.Incidents, a.Incidents), cmp.Compare(a.UptimePercent, b.UptimePercent), strings.Compare(a.Target, b.Target))
+		}
+		return cmp.Or(cmp.Compare(b.AvgResponseMs, a.AvgResponseMs), strings.Compare(a.Target, b.Target))
+	})
+	if limit > 0 && len(outputs) > limit {
+		outputs = outputs[:limit]
+	}
+	for i := range outputs {
+		outputs[i].Rank = i + 1
+	}
+
+	if jsonOutput {
+		printJSON(outputs)
+		return
+	}
+	if len(outputs) == 0 {
+		fmt.Printf("No checks since %s.\n", formatTime(since, "2006-01-02 15:04"))
+		return
+	}
+
+	ansiRe := regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	rows := [][]string{{"#", "TARGET", "UPTIME", "AVG RESP", "MAX RESP", "INCIDENTS", "CHECKS"}}
+	for _, o := range outputs {
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", o.Rank),
+			truncate(o.Target, 25),
+			uptimeCell(o.UptimePercent, o.TotalChecks),
+			fmt.Sprintf("%.0fms", o.AvgResponseMs),
+			fmt.Sprintf("%dms", o.MaxResponseMs),
+			fmt.Sprintf("%d", o.Incidents),
+			fmt.Sprintf("%d", o.TotalChecks),
+		})
+	}
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for j, cell := range row {
+			widths[j] = max(widths[j], runewidth.StringWidth(ansiRe.ReplaceAllString(cell, "")))
+		}
+	}
+	sep := make([]string, len(widths))
+	for i, w := range widths {
+		sep[i] = strings.Repeat("─", w)
+	}
+
+	fmt.Printf("Top %d by %s since %s\n\n", len(outputs), by, formatTime(since, "2006-01-02 15:04"))
+	printPaddedRow(os.Stdout, rows[0], widths, ansiRe)
+	printPaddedRow(os.Stdout, sep, widths, ansiRe)
+	for _, row := range rows[1:] {
+		printPaddedRow(os.Stdout, row, widths, ansiRe)
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -114,10 +115,10 @@ const (
 )
 
 var editFieldLabels = [editFieldCount]string{
-	"Name", "URL", "Type", "Interval (s)", "Timeout (s)", "Retries", "Selector", "Expect", "Threshold (%)", "Trigger If", "jq Filter", "Tags",
+	"Name", "URL", "Type", "Interval", "Timeout", "Retries", "Selector", "Expect", "Threshold (%)", "Trigger If", "jq Filter", "Tags",
 }
 
-var typeOptions = []string{"http", "tcp", "ping", "dns", "visual", "whois"}
+var typeOptions = []string{"http", "tcp", "ping", "tcp-ping", "dns", "visual", "whois", "statuspage", "composite"}
 
 func nextType(current string) string {
 	for i, t := range typeOptions {
@@ -180,8 +181,8 @@ func (k keyMap) FullHelp() [][]key.Binding {
 
 type tickMsg time.Time
 type checkDoneMsg struct {
-	targetID int64
-	result   *checker.Result
+	target *db.Target
+	result *checker.Result
 }
 
 type tuiModel struct {
@@ -528,27 +529,14 @@ func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, m.tick()
 
 	case checkDoneMsg:
-		delete(m.checkingIDs, msg.targetID)
-		m.results[msg.targetID] = msg.result
+		delete(m.checkingIDs, msg.target.ID)
+		m.results[msg.target.ID] = msg.result
 		// Save result to DB
-		cr := &db.CheckResult{
-			TargetID:     msg.targetID,
-			Status:       msg.result.Status,
-			StatusCode:   msg.result.StatusCode,
-			ResponseTime: msg.result.ResponseTime.Milliseconds(),
-			ContentHash:  msg.result.ContentHash,
-			Error:        msg.result.Error,
-		}
-		db.SaveCheckResult(cr)
-		if msg.result.Content != "" && msg.result.ContentHash != "" {
-			snaps, _ := db.GetLatestSnapshots(msg.targetID, 1)
-			if len(snaps) == 0 || snaps[0].Hash != msg.result.ContentHash {
-				db.SaveSnapshot(msg.targetID, msg.result.Content, msg.result.ContentHash)
-			}
-		}
+		db.SaveCheckResult(msg.result.Record(msg.target.ID))
+		saveSnapshot(msg.target, msg.result)
 		m.refreshData()
 		m.status = fmt.Sprintf("Checked | %d targets | %s", len(m.filtered), time.Now().Format("15:04:05"))
-		if m.view == viewDetail && m.selected != nil && m.selected.ID == msg.targetID {
+		if m.view == viewDetail && m.selected != nil && m.selected.ID == msg.target.ID {
 			m.updateDetail()
 		}
 		return m, nil
@@ -671,24 +659,34 @@ func (m *tuiModel) updateDetail() {
 	sb.WriteString(fmt.Sprintf("Name:     %s\n", t.Name))
 	sb.WriteString(fmt.Sprintf("URL:      %s\n", t.URL))
 	sb.WriteString(fmt.Sprintf("Type:     %s\n", t.Type))
-	sb.WriteString(fmt.Sprintf("Interval: %ds\n", t.Interval))
+	sb.WriteString(fmt.Sprintf("Interval: %s\n", formatSeconds(t.Interval)))
 	if t.Selector != "" {
 		sb.WriteString(fmt.Sprintf("Selector: %s\n", t.Selector))
 	}
 	if t.Expect != "" {
 		sb.WriteString(fmt.Sprintf("Expect:   %s\n", t.Expect))
 	}
+	if t.ExpectAbsent != "" {
+		sb.WriteString(fmt.Sprintf("Absent:   %s\n", t.ExpectAbsent))
+	}
 	if t.JQFilter != "" {
 		sb.WriteString(fmt.Sprintf("jq:       %s\n", t.JQFilter))
 	}
+	if t.JSONPath != "" {
+		sb.WriteString(fmt.Sprintf("JSON path: %s\n", t.JSONPath))
+	}
 	if t.TriggerRule != "" {
 		sb.WriteString(fmt.Sprintf("Trigger:  %s\n", trigger.Describe(t.TriggerRule)))
 	}
+	if t.AlertOnRegression {
+		sb.WriteString(fmt.Sprintf("Alert if: %s\n", describeRegression(t)))
+	}
 	if tags, ok := m.tagMap[t.ID]; ok && len(tags) > 0 {
 		sb.WriteString(fmt.Sprintf("Tags:     %s\n", strings.Join(tags, ", ")))
 	}
-	sb.WriteString(fmt.Sprintf("Timeout:  %ds | Retries: %d\n", t.Timeout, t.Retries))
+	sb.WriteString(fmt.Sprintf("Timeout:  %s | Retries: %s\n", formatSeconds(t.Timeout), formatRetries(t.Retries)))
 	sb.WriteString(fmt.Sprintf("Paused:   %v\n", t.Paused))
+	sb.WriteString(fmt.Sprintf("Muted:    %v\n", t.Muted))
 	sb.WriteString("\n")
 
 	// Last error
@@ -718,12 +716,8 @@ func (m *tuiModel) updateDetail() {
 		for _, r := range results {
 			icon := "●"
 			switch r.Status {
-			case "up", "unchanged":
-				icon = "✓"
-			case "changed":
-				icon = "△"
-			case "down", "error":
-				icon = "✗"
+			case "up", "baseline", "unchanged", "changed", "redirect", "down-local-only", "down", "error":
+				icon = statusIcon(r.Status)
 			}
 			line := fmt.Sprintf("  %s  %s  %dms  %s", r.CheckedAt.Format("15:04:05"), icon, r.ResponseTime, r.Status)
 			if r.Error != "" {
@@ -758,9 +752,9 @@ func (m *tuiModel) initAddInputs() {
 	m.editInputs[editName].Placeholder = "My Site"
 	m.editInputs[editURL].Placeholder = "https://example.com"
 	m.editInputs[editType].SetValue("http")
-	m.editInputs[editInterval].SetValue("300")
-	m.editInputs[editTimeout].SetValue("30")
-	m.editInputs[editRetries].SetValue("1")
+	m.editInputs[editInterval].SetValue("5m")
+	m.editInputs[editTimeout].SetValue("30s")
+	m.editInputs[editRetries].SetValue("0")
 	m.editInputs[editSelector].Placeholder = "CSS selector (optional)"
 	m.editInputs[editExpected].Placeholder = "Expected keyword (optional)"
 	m.editInputs[editThreshold].SetValue("5.0")
@@ -783,14 +777,22 @@ func (m *tuiModel) saveAdd() error {
 	expect := m.editInputs[editExpected].Value()
 
 	interval := 300
-	if v, err := strconv.Atoi(m.editInputs[editInterval].Value()); err == nil && v > 0 {
-		interval = v
+	if v := m.editInputs[editInterval].Value(); v != "" {
+		secs, err := parseSeconds(v)
+		if err != nil {
+			return fmt.Errorf("interval: %w", err)
+		}
+		interval = secs
 	}
 	timeout := 30
-	if v, err := strconv.Atoi(m.editInputs[editTimeout].Value()); err == nil && v > 0 {
-		timeout = v
+	if v := m.editInputs[editTimeout].Value(); v != "" {
+		secs, err := parseSeconds(v)
+		if err != nil {
+			return fmt.Errorf("timeout: %w", err)
+		}
+		timeout = secs
 	}
-	retries := 1
+	retries := 0
 	if v, err := strconv.Atoi(m.editInputs[editRetries].Value()); err == nil && v > 0 {
 		retries = v
 	}
@@ -799,17 +801,16 @@ func (m *tuiModel) saveAdd() error {
 		threshold = v
 	}
 
-	triggerRule := ""
+	var rules db.Target
 	if v := m.editInputs[editTriggerIf].Value(); v != "" {
-		parsed, err := trigger.ParseShorthand(v)
-		if err != nil {
+		if err := applyTriggerIf(&rules, v); err != nil {
 			return err
 		}
-		triggerRule = parsed
 	}
 	jqFilter := m.editInputs[editJQ].Value()
 
-	target, err := db.AddTarget(name, url, typ, interval, selector, "", expect, timeout, retries, threshold, db.AddTargetOpts{TriggerRule: triggerRule, JQFilter: jqFilter})
+	target, err := db.AddTarget(name, url, typ, interval, selector, "", expect, timeout, retries, threshold, db.AddTargetOpts{
+		TriggerRule: rules.TriggerRule, AlertOnRegression: rules.AlertOnRegression, RegressionMultiplier: rules.RegressionMultiplier, JQFilter: jqFilter})
 	if err != nil {
 		return err
 	}
@@ -836,8 +837,8 @@ func (m *tuiModel) initEditInputs() {
 	m.editInputs[editURL].SetValue(t.URL)
 	m.editInputs[editType].SetValue(t.Type)
 	m.editInputs[editType].Placeholder = "http, tcp, ping, dns, visual"
-	m.editInputs[editInterval].SetValue(fmt.Sprintf("%d", t.Interval))
-	m.editInputs[editTimeout].SetValue(fmt.Sprintf("%d", t.Timeout))
+	m.editInputs[editInterval].SetValue(formatSeconds(t.Interval))
+	m.editInputs[editTimeout].SetValue(formatSeconds(t.Timeout))
 	m.editInputs[editRetries].SetValue(fmt.Sprintf("%d", t.Retries))
 	m.editInputs[editSelector].SetValue(t.Selector)
 	m.editInputs[editSelector].Placeholder = "CSS selector (optional)"
@@ -878,33 +879,46 @@ func (m *tuiModel) focusEditField() tea.Cmd {
 
 func (m *tuiModel) saveEdit() error {
 	t := m.selected
+	oldURL, oldType := t.URL, t.Type
 	t.Name = m.editInputs[editName].Value()
 	t.URL = m.editInputs[editURL].Value()
 	t.Type = m.editInputs[editType].Value()
+	if t.URL != oldURL || t.Type != oldType {
+		if err := db.ValidateTarget(t); err != nil {
+			return err
+		}
+	}
 	t.Selector = m.editInputs[editSelector].Value()
 	t.Expect = m.editInputs[editExpected].Value()
 
-	if v, err := strconv.Atoi(m.editInputs[editInterval].Value()); err == nil && v > 0 {
-		t.Interval = v
+	if v := m.editInputs[editInterval].Value(); v != "" {
+		secs, err := parseSeconds(v)
+		if err != nil {
+			return fmt.Errorf("interval: %w", err)
+		}
+		t.Interval = secs
 	}
-	if v, err := strconv.Atoi(m.editInputs[editTimeout].Value()); err == nil && v > 0 {
-		t.Timeout = v
+	if v := m.editInputs[editTimeout].Value(); v != "" {
+		secs, err := parseSeconds(v)
+		if err != nil {
+			return fmt.Errorf("timeout: %w", err)
+		}
+		t.Timeout = secs
 	}
-	if v, err := strconv.Atoi(m.editInputs[editRetries].Value()); err == nil && v > 0 {
+	if v, err := strconv.Atoi(m.editInputs[editRetries].Value()); err == nil && v >= 0 {
 		t.Retries = v
 	}
 	if v, err := strconv.ParseFloat(m.editInputs[editThreshold].Value(), 64); err == nil && v > 0 {
 		t.Threshold = v
 	}
 
+	// The field holds the content rule; the regression alert is kept unless
+	// the field turns it on
+	t.TriggerRule = ""
 	if v := m.editInputs[editTriggerIf].Value(); v != "" {
-		parsed, err := trigger.ParseShorthand(v)
-		if err != nil {
+		if err := applyTriggerIf(t, v); err != nil {
 			return err
 		}
-		t.TriggerRule = parsed
-	} else {
-		t.TriggerRule = ""
 	}
 	t.JQFilter = m.editInputs[editJQ].Value()
 
@@ -923,8 +937,8 @@ func (m *tuiModel) runCheck(t *db.Target) tea.Cmd {
 	m.checkingIDs[target.ID] = true
 	m.refreshData()
 	return func() tea.Msg {
-		result := checker.Check(&target)
-		return checkDoneMsg{targetID: target.ID, result: result}
+		result := checker.Check(context.Background(), &target)
+		return checkDoneMsg{target: &target, result: result}
 	}
 }
 
@@ -2,9 +2,18 @@ package cmd
 
 import (
 	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/naru-bot/upp/internal/checker"
+	"github.com/naru-bot/upp/internal/config"
 	"github.com/naru-bot/upp/internal/db"
+	"github.com/naru-bot/upp/internal/notify"
+	"github.com/naru-bot/upp/internal/trigger"
 	"github.com/spf13/cobra"
 )
 
@@ -12,23 +21,186 @@ func init() {
 	cmd := &cobra.Command{
 		Use:   "view <name|url|id>",
 		Short: "Show full configuration for a target",
-		Long: `Show the full configuration and latest check for a target.
+		Long: `Show the full configuration and latest check for a target, with a
+summary of how it has been doing: uptime over the last 24h, 7d and 30d,
+incidents (runs of down or error checks) in the last 30d, how long it has
+been down when it is, and when its TLS certificate expires.
 
 Examples:
   upp view "My Site"
   upp view https://example.com
-  upp view 1`,
+  upp view 1
+  upp view "My Site" --timing
+  upp view "Price Watch" --content # the selector-extracted content being watched
+  upp view "My Site" --content --full
+  upp view "My Site" --data        # full content; highlights expect/trigger matches`,
 		Args: requireArgs(1),
 		Run:  runView,
 	}
-	cmd.Flags().Bool("data", false, "Include latest snapshot content in output")
+	cmd.Flags().Bool("content", false, "Show the latest snapshot content, shortened to 40 lines and display.preview_bytes")
+	cmd.Flags().Bool("full", false, "With --content, show the whole snapshot instead of a preview")
+	cmd.Flags().Bool("data", false, "Include latest snapshot content in output (same as --content --full)")
+	cmd.Flags().Bool("no-bar", false, "Hide the recent check history bar")
+	cmd.Flags().Bool("timing", false, "Break the last response time down into DNS, connect, TLS and first byte")
 	rootCmd.AddCommand(cmd)
 }
 
 type viewOutput struct {
 	Target    db.Target       `json:"target"`
 	LastCheck *db.CheckResult `json:"last_check,omitempty"`
+	Summary   *viewSummary    `json:"summary,omitempty"`
 	Snapshot  *db.Snapshot    `json:"snapshot,omitempty"`
+	Score     *viewScore      `json:"score,omitempty"`
+}
+
+// viewScore is a target's content score against its latest snapshot, with
+// what each score rule contributed.
+type viewScore struct {
+	Score      int            `json:"score"`
+	SnapshotAt time.Time      `json:"snapshot_at"`
+	Breakdown  []viewScoreHit `json:"breakdown"`
+}
+
+type viewScoreHit struct {
+	Kind    string `json:"kind"`
+	Weight  int    `json:"weight"`
+	Keyword string `json:"keyword"`
+	Found   bool   `json:"found"`
+	Points  int    `json:"points"`
+}
+
+// newViewScore scores a snapshot against t's score rules, or returns nil
+// when there is nothing to score.
+func newViewScore(t *db.Target, snapshot *db.Snapshot) *viewScore {
+	if len(t.ScoreRules) == 0 || snapshot == nil {
+		return nil
+	}
+	score, hits := checker.ScoreContent(t.ScoreRules, snapshot.Content)
+	out := &viewScore{Score: score, SnapshotAt: snapshot.CreatedAt, Breakdown: []viewScoreHit{}}
+	for _, h := range hits {
+		out.Breakdown = append(out.Breakdown, viewScoreHit{
+			Kind:    h.Rule.Kind,
+			Weight:  h.Rule.Weight,
+			Keyword: h.Rule.Keyword,
+			Found:   h.Found,
+			Points:  h.Points,
+		})
+	}
+	return out
+}
+
+// printViewScore prints a score and its breakdown, one rule per line,
+// colored against the target's thresholds.
+func printViewScore(t *db.Target, s *viewScore) {
+	score := fmt.Sprintf("%d", s.Score)
+	switch {
+	case s.Score < t.ScoreMin:
+		score = colorRed(score)
+	case s.Score < t.ScoreWarn:
+		score = colorYellow(score)
+	default:
+		score = colorGreen(score)
+	}
+	fmt.Printf("Content score: %s (latest snapshot, %s)\n", score, displayTime(s.SnapshotAt, time.RFC3339))
+	for _, h := range s.Breakdown {
+		found := "missing"
+		if h.Found {
+			found = "found"
+		}
+		fmt.Printf("  %+3d  %-9s %-7s %q\n", h.Points, h.Kind, found, h.Keyword)
+	}
+}
+
+// viewSummary is how a target has been doing, for view. Uptimes are nil
+// for windows without checks.
+type viewSummary struct {
+	Uptime24h      *float64   `json:"uptime_24h,omitempty"`
+	Uptime7d       *float64   `json:"uptime_7d,omitempty"`
+	Uptime30d      *float64   `json:"uptime_30d,omitempty"`
+	Incidents30d   int        `json:"incidents_30d"`
+	DownSince      *time.Time `json:"down_since,omitempty"`
+	DownForSeconds int64      `json:"down_for_seconds,omitempty"`
+	CertExpiresAt  *time.Time `json:"cert_expires_at,omitempty"`
+	CertDaysLeft   *int       `json:"cert_days_left,omitempty"`
+}
+
+// newViewSummary gathers a target's summary from its check history.
+// checks are its most recent results, newest first.
+func newViewSummary(t *db.Target, checks []db.CheckResult) (*viewSummary, error) {
+	s := &viewSummary{}
+	now := time.Now()
+	for _, w := range []struct {
+		dst **float64
+		d   time.Duration
+	}{
+		{&s.Uptime24h, 24 * time.Hour},
+		{&s.Uptime7d, 7 * 24 * time.Hour},
+		{&s.Uptime30d, 30 * 24 * time.Hour},
+	} {
+		total, up, _, err := db.GetUptimeStats(t.ID, now.Add(-w.d))
+		if err != nil {
+			return nil, err
+		}
+		if total > 0 {
+			pct := float64(up) / float64(total) * 100
+			*w.dst = &pct
+		}
+	}
+
+	stats, err := db.GetTargetStatsFor(t.ID, now.Add(-30*24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	s.Incidents30d = stats.Outages
+
+	if len(checks) > 0 && (checks[0].Status == "down" || checks[0].Status == "error") {
+		_, downFor := recentOutage(t.ID)
+		since := checks[0].CheckedAt.Add(-downFor)
+		s.DownSince = &since
+		s.DownForSeconds = int64(now.Sub(since).Seconds())
+	}
+
+	// The latest check that saw a certificate; a failed one may not have
+	for _, c := range checks {
+		if c.CertExpiresAt != nil {
+			days := int(time.Until(*c.CertExpiresAt).Hours() / 24)
+			s.CertExpiresAt = c.CertExpiresAt
+			s.CertDaysLeft = &days
+			break
+		}
+	}
+	return s, nil
+}
+
+// printViewSummary prints the summary block of view.
+func printViewSummary(s *viewSummary) {
+	uptime := func(pct *float64, window string) string {
+		if pct == nil {
+			return "— (" + window + ")"
+		}
+		return uptimeCell(*pct, 1) + " (" + window + ")"
+	}
+	fmt.Printf("Uptime: %s · %s · %s\n", uptime(s.Uptime24h, "24h"), uptime(s.Uptime7d, "7d"), uptime(s.Uptime30d, "30d"))
+	fmt.Printf("Incidents: %d in the last 30d\n", s.Incidents30d)
+	if s.DownSince != nil {
+		fmt.Printf("Down for: %s (since %s)\n",
+			colorRed(humanizeDuration(time.Duration(s.DownForSeconds)*time.Second)), displayTime(*s.DownSince, time.RFC3339))
+	}
+	if s.CertExpiresAt != nil {
+		days := *s.CertDaysLeft
+		left := fmt.Sprintf("in %dd", days)
+		if days < 0 {
+			left = fmt.Sprintf("expired %dd ago", -days)
+		}
+		warnDays := config.Get().SSLWarnDays()
+		switch {
+		case days < warnDays/2:
+			left = colorRed(left)
+		case days < warnDays:
+			left = colorYellow(left)
+		}
+		fmt.Printf("Cert expires: %s (%s)\n", formatTime(*s.CertExpiresAt, "2006-01-02"), left)
+	}
 }
 
 func runView(cmd *cobra.Command, args []string) {
@@ -38,34 +210,70 @@ func runView(cmd *cobra.Command, args []string) {
 	}
 
 	var lastCheck *db.CheckResult
-	if checks, err := db.GetCheckHistory(t.ID, 1); err == nil && len(checks) > 0 {
+	checks, err := db.GetCheckHistory(t.ID, uptimeBarWidth)
+	if err == nil && len(checks) > 0 {
 		lastCheck = &checks[0]
 	}
 
-	includeData, _ := cmd.Flags().GetBool("data")
+	showData, _ := cmd.Flags().GetBool("data")
+	showContent, _ := cmd.Flags().GetBool("content")
+	full, _ := cmd.Flags().GetBool("full")
+	includeData := showData || showContent
 	var snapshot *db.Snapshot
-	if includeData {
-		if snaps, err := db.GetLatestSnapshots(t.ID, 1); err == nil && len(snaps) > 0 {
-			snapshot = &snaps[0]
+	if includeData || len(t.ScoreRules) > 0 {
+		if snapshot, err = db.GetLatestSnapshot(t.ID); err != nil {
+			exitError(err.Error())
+		}
+	}
+	score := newViewScore(t, snapshot)
+	if !includeData {
+		snapshot = nil
+	}
+
+	var summary *viewSummary
+	if lastCheck != nil {
+		if summary, err = newViewSummary(t, checks); err != nil {
+			exitError(err.Error())
 		}
 	}
 
 	if jsonOutput {
-		printJSON(viewOutput{Target: *t, LastCheck: lastCheck, Snapshot: snapshot})
+		printJSON(viewOutput{Target: redactTarget(*t), LastCheck: lastCheck, Summary: summary, Snapshot: snapshot, Score: score})
 		return
 	}
 
 	fmt.Printf("Target: %s (id %d)\n", t.Name, t.ID)
 	fmt.Printf("URL: %s\n", t.URL)
 	fmt.Printf("Type: %s\n", t.Type)
-	fmt.Printf("Interval: %ds\n", t.Interval)
-	fmt.Printf("Timeout: %ds\n", t.Timeout)
-	fmt.Printf("Retries: %d\n", t.Retries)
+	if t.Type == "tcp-ping" {
+		fmt.Printf("Port: %d\n", checker.TCPPingPort(t))
+	}
+	if t.Type == "composite" {
+		printCompositeMembers(t)
+	}
+	fmt.Printf("Interval: %s\n", formatSeconds(t.Interval))
+	if t.Priority != 0 {
+		fmt.Printf("Priority: %d\n", t.Priority)
+	}
+	fmt.Printf("Timeout: %s\n", formatSeconds(t.Timeout))
+	if t.ConnectTimeout > 0 {
+		fmt.Printf("Connect timeout: %s\n", formatSeconds(t.ConnectTimeout))
+	}
+	fmt.Printf("Retries: %s\n", formatRetries(t.Retries))
+	if t.MaxTotalTime > 0 {
+		fmt.Printf("Max total time: %s\n", formatSeconds(t.MaxTotalTime))
+	}
+	if t.RetentionDays != 0 {
+		fmt.Printf("Retention: %s\n", formatRetention(t.RetentionDays))
+	} else if days := config.Get().Storage.RetentionDays; days > 0 {
+		fmt.Printf("Retention: %s (storage.retention_days)\n", formatRetention(days))
+	}
 	fmt.Printf("Paused: %v\n", t.Paused)
-	fmt.Printf("Created: %s\n", t.CreatedAt.Format(time.RFC3339))
+	fmt.Printf("Muted: %v\n", t.Muted)
+	fmt.Printf("Created: %s\n", displayTime(t.CreatedAt, time.RFC3339))
 
 	if t.Selector != "" {
-		fmt.Printf("Selector: %s\n", t.Selector)
+		fmt.Printf("Selector: %s\n", describeSelector(t))
 	}
 	if t.Headers != "" {
 		fmt.Printf("Headers: %s\n", t.Headers)
@@ -73,9 +281,107 @@ func runView(cmd *cobra.Command, args []string) {
 	if t.Expect != "" {
 		fmt.Printf("Expect: %s\n", t.Expect)
 	}
+	if t.ExpectAbsent != "" {
+		fmt.Printf("Expect absent: %s\n", t.ExpectAbsent)
+	}
+	if t.JSONPath != "" {
+		fmt.Printf("JSON path: %s\n", t.JSONPath)
+	}
+	if t.TriggerRule != "" {
+		fmt.Printf("Trigger: %s\n", trigger.Describe(t.TriggerRule))
+	}
 	if t.Threshold > 0 {
 		fmt.Printf("Threshold: %.1f%%\n", t.Threshold)
 	}
+	if t.ChangeThreshold > 0 {
+		fmt.Printf("Change threshold: %.1f%%\n", t.ChangeThreshold)
+	}
+	if t.RedirectStatus != "" {
+		fmt.Printf("Redirects: reported as %s\n", t.RedirectStatus)
+	}
+	if t.SnapshotMode != "" {
+		fmt.Printf("Snapshots: %s\n", t.SnapshotMode)
+	}
+	if len(t.HashHeaders) > 0 {
+		fmt.Printf("Hash headers: %s\n", strings.Join(t.HashHeaders, ", "))
+	}
+	if t.ExpectContentType != "" {
+		fmt.Printf("Expect content type: %s\n", t.ExpectContentType)
+	}
+	if t.FailOnEmpty {
+		fmt.Printf("Fail on empty: true\n")
+	}
+	if t.HeadOnly {
+		fmt.Printf("HEAD only: true (no change detection)\n")
+	}
+	if t.StreamMode {
+		readBytes := t.ReadBytes
+		if readBytes <= 0 {
+			readBytes = checker.DefaultStreamReadBytes
+		}
+		fmt.Printf("Stream mode: reads up to %s\n", formatBytes(int64(readBytes)))
+	}
+	if len(t.SoftDownKeywords) > 0 {
+		fmt.Printf("Soft-down keywords: %s\n", strings.Join(t.SoftDownKeywords, ", "))
+	}
+	if len(t.ScoreRules) > 0 {
+		fmt.Printf("Score rules: %s\n", describeScore(t))
+		for _, r := range t.ScoreRules {
+			fmt.Printf("  %s\n", r)
+		}
+	}
+	if t.CAFile != "" {
+		fmt.Printf("CA file: %s\n", t.CAFile)
+	}
+	if t.Proxy != "" {
+		fmt.Printf("Proxy: %s\n", checker.RedactProxy(t.Proxy))
+	}
+	if t.ClientCert != "" {
+		desc := ""
+		if pair, err := checker.LoadClientCert(t.ClientCert, t.ClientKey); err != nil {
+			desc = colorRed(err.Error())
+		} else {
+			desc = fmt.Sprintf("%s, expires %s", pair.Leaf.Subject, formatTime(pair.Leaf.NotAfter, "2006-01-02"))
+		}
+		fmt.Printf("Client cert: %s (%s)\n", redactPath(t.ClientCert), desc)
+		if t.ClientKey != "" {
+			fmt.Printf("Client key: %s\n", redactPath(t.ClientKey))
+		}
+	}
+	if t.CertPin != "" {
+		fmt.Printf("Pinned cert (SHA-256): %s\n", t.CertPin)
+	}
+	if t.ExpectHash != "" {
+		fmt.Printf("Expected content hash (SHA-256): %s\n", t.ExpectHash)
+	}
+	if t.AlertCertChange {
+		fmt.Printf("Alert on cert change: true\n")
+	}
+	if t.AlertOnIPChange {
+		fmt.Printf("Alert on IP change: true\n")
+	}
+	if t.AlertOnRegression {
+		fmt.Printf("Alert on regression: %s\n", describeRegression(t))
+	}
+	if t.NotifyOnRecovery {
+		fmt.Printf("Notify on recovery: true\n")
+	}
+	if t.ExpectMinTLS != "" {
+		fmt.Printf("Expect min TLS: %s\n", t.ExpectMinTLS)
+	}
+	if t.Escalation != "" {
+		fmt.Printf("Escalation: %s\n", t.Escalation)
+	}
+	if len(t.Channels) > 0 {
+		fmt.Printf("Channels: %s\n", strings.Join(t.Channels, ", "))
+	}
+	fmt.Printf("Severity: %s\n", notify.EffectiveSeverity(t.Severity))
+	if len(t.Meta) > 0 {
+		fmt.Println("Meta:")
+		for _, k := range slices.Sorted(maps.Keys(t.Meta)) {
+			fmt.Printf("  %s: %s\n", k, t.Meta[k])
+		}
+	}
 
 	if lastCheck == nil {
 		fmt.Println("Last check: none (run 'upp check')")
@@ -85,27 +391,246 @@ func runView(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	fmt.Printf("Last check: %s\n", lastCheck.CheckedAt.Format(time.RFC3339))
+	fmt.Printf("Last check: %s\n", displayTime(lastCheck.CheckedAt, time.RFC3339))
 	fmt.Printf("Status: %s\n", lastCheck.Status)
+	if flapping, _, changes := flapState(t.ID); flapping {
+		window, _ := flapSettings()
+		fmt.Printf("Flapping: %d status changes in the last %d checks\n", changes, window)
+	}
+	if noBar, _ := cmd.Flags().GetBool("no-bar"); !noBar && uptimeBarEnabled() {
+		fmt.Printf("History: %s\n", uptimeBar(checks))
+	}
+	printViewSummary(summary)
 	if lastCheck.StatusCode != 0 {
 		fmt.Printf("Status code: %d\n", lastCheck.StatusCode)
 	}
 	if lastCheck.ResponseTime != 0 {
 		fmt.Printf("Response time: %dms\n", lastCheck.ResponseTime)
 	}
-	if lastCheck.Error != "" {
+	if lastCheck.RetryAfterMs != 0 {
+		fmt.Printf("Retry-After: %s\n", humanizeDuration(time.Duration(lastCheck.RetryAfterMs)*time.Millisecond))
+	}
+	if showTiming, _ := cmd.Flags().GetBool("timing"); showTiming {
+		printTiming(lastCheck)
+	}
+	if lastCheck.ContentType != "" {
+		fmt.Printf("Content type: %s\n", lastCheck.ContentType)
+	}
+	if p := lastCheck.Protocol(); p != "" {
+		fmt.Printf("Protocol: %s\n", p)
+	}
+	if lastCheck.RemoteIP != "" {
+		fmt.Printf("Remote IP: %s\n", lastCheck.RemoteIP)
+	}
+	if lastCheck.CertFingerprint != "" {
+		fmt.Printf("Cert fingerprint (SHA-256): %s\n", lastCheck.CertFingerprint)
+	}
+	if lastCheck.TLSVersion != "" {
+		fmt.Printf("TLS: %s, %s (chain %s)\n", lastCheck.TLSVersion, lastCheck.TLSCipher, chainLabel(lastCheck.TLSChainValid))
+	}
+	if len(lastCheck.AttemptErrors) > 0 {
+		fmt.Println("Attempts:")
+		for i, e := range lastCheck.AttemptErrors {
+			fmt.Printf("  %d. %s\n", i+1, e)
+		}
+	} else if lastCheck.Error != "" {
 		fmt.Printf("Error: %s\n", lastCheck.Error)
 	}
+	if score != nil {
+		printViewScore(t, score)
+	}
 
 	if includeData {
 		if snapshot == nil {
 			fmt.Println("Snapshot: none (run 'upp check')")
 			return
 		}
-		fmt.Printf("\nSnapshot: %s\n\n", snapshot.CreatedAt.Format(time.RFC3339))
-		fmt.Print(snapshot.Content)
-		if len(snapshot.Content) > 0 && snapshot.Content[len(snapshot.Content)-1] != '\n' {
+		fmt.Printf("\nSnapshot: %s\n", displayTime(snapshot.CreatedAt, time.RFC3339))
+		patterns := targetPatterns(t)
+		printMatchSummary(snapshot.Content, "", patterns)
+		fmt.Println()
+		content, note := previewLines(snapshot.Content, viewPreviewLines, showData || full)
+		fmt.Print(highlightMatches(content, patterns))
+		if len(content) > 0 && content[len(content)-1] != '\n' {
 			fmt.Print("\n")
 		}
+		if note != "" {
+			fmt.Println(note)
+		}
+	}
+}
+
+// viewPreviewLines bounds the snapshot preview of view --content, along
+// with display.preview_bytes.
+const viewPreviewLines = 40
+
+// describeSelector shows a target's selector, naming its type unless it
+// is CSS, and whether it is strict.
+func describeSelector(t *db.Target) string {
+	var notes []string
+	if t.SelectorType != "" && t.SelectorType != checker.SelectorCSS {
+		notes = append(notes, t.SelectorType)
+	}
+	if t.StrictSelector {
+		notes = append(notes, "strict")
+	}
+	if len(notes) == 0 {
+		return t.Selector
+	}
+	return fmt.Sprintf("%s (%s)", t.Selector, strings.Join(notes, ", "))
+}
+
+// printTiming prints the phase breakdown of a check. Phases that did not
+// happen (a reused connection, plain http, a non-http check) show as "—".
+func printTiming(r *db.CheckResult) {
+	phase := func(ms int64) string {
+		if ms == 0 {
+			return "—"
+		}
+		return fmt.Sprintf("%dms", ms)
+	}
+	fmt.Println("Timing:")
+	dns := phase(r.DNSMs)
+	if r.DNSCached() {
+		dns = "cached"
+	}
+	fmt.Printf("  DNS lookup:  %s\n", dns)
+	fmt.Printf("  TCP connect: %s\n", phase(r.ConnectMs))
+	fmt.Printf("  TLS:         %s\n", phase(r.TLSMs))
+	fmt.Printf("  First byte:  %s\n", phase(r.FirstByteMs))
+}
+
+// matchPattern is something the checker looks for in content: the target's
+// expect keywords or its trigger rule.
+type matchPattern struct {
+	label  string
+	re     *regexp.Regexp
+	rule   string // trigger rule JSON, empty for expect
+	absent bool   // the expect-absent keyword, which must not be found
+}
+
+// targetPatterns returns the patterns a target's checks evaluate. An
+// unparsable trigger rule is left out rather than failing the display.
+func targetPatterns(t *db.Target) []matchPattern {
+	var pats []matchPattern
+	if t.Expect != "" {
+		pats = append(pats, matchPattern{
+			label: fmt.Sprintf("Expect %q", t.Expect),
+			re:    regexp.MustCompile(regexp.QuoteMeta(t.Expect)),
+		})
+	}
+	if t.ExpectAbsent != "" {
+		pats = append(pats, matchPattern{
+			label:  fmt.Sprintf("Expect absent %q", t.ExpectAbsent),
+			re:     regexp.MustCompile(regexp.QuoteMeta(t.ExpectAbsent)),
+			absent: true,
+		})
+	}
+	if t.TriggerRule != "" {
+		if re, err := trigger.Pattern(t.TriggerRule); err == nil {
+			pats = append(pats, matchPattern{
+				label: "Trigger (" + trigger.Describe(t.TriggerRule) + ")",
+				re:    re,
+				rule:  t.TriggerRule,
+			})
+		}
+	}
+	return pats
+}
+
+// printMatchSummary says, per pattern, whether and where it matched content
+// and what that meant for the check, so a failed expect or a quiet trigger
+// can be traced to the text the checker saw. previous is the content before
+// the change, which a diff-scoped trigger is judged against; "" when there
+// is no change to judge.
+func printMatchSummary(content, previous string, pats []matchPattern) {
+	for _, p := range pats {
+		locs := p.re.FindAllStringIndex(content, -1)
+		// Finding is good news, except for a keyword that must be absent
+		good, bad := colorGreen, colorRed
+		if p.absent {
+			good, bad = colorRed, colorGreen
+		}
+		var found string
+		if len(locs) == 0 {
+			found = bad("pattern not found")
+		} else {
+			found = good(fmt.Sprintf("found %d×", len(locs))) + fmt.Sprintf(" (first on line %d: %s)",
+				strings.Count(content[:locs[0][0]], "\n")+1, truncate(matchContext(content, locs[0]), 80))
+		}
+		outcome := ""
+		switch {
+		case p.absent:
+			if len(locs) > 0 {
+				outcome = " → check is down"
+			}
+		case p.rule != "" && previous == "" && trigger.Scope(p.rule) == trigger.ScopeDiff:
+			outcome = " → decided by the lines a change adds (see 'upp diff')"
+		case p.rule != "":
+			if fires, err := trigger.EvaluateChange(p.rule, content, previous); err == nil {
+				outcome = " → would not notify"
+				if fires {
+					outcome = " → notifies"
+				}
+			}
+		case len(locs) == 0:
+			outcome = " → check is down"
+		}
+		fmt.Printf("%s: %s%s\n", p.label, found, outcome)
+	}
+}
+
+// matchContext returns a match with up to 25 bytes of its line on either
+// side, trimmed of surrounding whitespace.
+func matchContext(content string, loc []int) string {
+	const pad = 25
+	start := strings.LastIndexByte(content[:loc[0]], '\n') + 1
+	end := len(content)
+	if i := strings.IndexByte(content[loc[1]:], '\n'); i >= 0 {
+		end = loc[1] + i
+	}
+	prefix, suffix := "", ""
+	if loc[0]-start > pad {
+		start, prefix = loc[0]-pad, "..."
+	}
+	if end-loc[1] > pad {
+		end, suffix = loc[1]+pad, "..."
+	}
+	return prefix + strings.TrimSpace(content[start:end]) + suffix
+}
+
+// highlightMatches marks every match of pats in s. Overlapping matches from
+// different patterns are merged into one highlighted span.
+func highlightMatches(s string, pats []matchPattern) string {
+	if noColor || len(pats) == 0 {
+		return s
+	}
+	var spans [][]int
+	for _, p := range pats {
+		for _, loc := range p.re.FindAllStringIndex(s, -1) {
+			if loc[1] > loc[0] {
+				spans = append(spans, loc)
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return s
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+
+	var sb strings.Builder
+	pos := 0
+	for i := 0; i < len(spans); {
+		start, end := spans[i][0], spans[i][1]
+		for i++; i < len(spans) && spans[i][0] <= end; i++ {
+			if spans[i][1] > end {
+				end = spans[i][1]
+			}
+		}
+		sb.WriteString(s[pos:start])
+		sb.WriteString(colorHighlight(s[start:end]))
+		pos = end
 	}
+	sb.WriteString(s[pos:])
+	return sb.String()
 }
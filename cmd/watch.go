@@ -157,10 +157,14 @@ func renderDashboard() {
 		// Status string
 		statusStr := status
 		switch status {
-		case "up", "unchanged":
+		case "up", "baseline", "unchanged":
 			statusStr = colorGreen("● " + status)
 		case "changed":
 			statusStr = colorYellow("△ changed")
+		case "redirect":
+			statusStr = colorYellow("↪ redirect")
+		case "down-local-only":
+			statusStr = colorYellow("◐ " + status)
 		case "down", "error":
 			statusStr = colorRed("✗ " + status)
 			if len(lastResults) > 0 && lastResults[0].Error != "" {
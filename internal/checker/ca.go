@@ -0,0 +1,62 @@
+package checker
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// defaultCAFile is the configured defaults.ca_file.
+var defaultCAFile string
+
+// SetDefaultCAFile sets the PEM CA bundle trusted by targets without their
+// own ca_file. Empty trusts the system roots alone.
+func SetDefaultCAFile(path string) {
+	defaultCAFile = path
+}
+
+// caFile returns the CA bundle a target's TLS connections trust on top of
+// the system roots: its own ca_file, else the configured default. Only
+// https targets take the default, so a default bundle that can't be read
+// doesn't fail plain http checks that never use it.
+func caFile(ctx context.Context, target *db.Target) string {
+	if target.CAFile != "" {
+		return target.CAFile
+	}
+	if !strings.HasPrefix(strings.ToLower(target.URL), "https://") {
+		return ""
+	}
+	return envFrom(ctx).settings.CAFile
+}
+
+// ValidateCAFile checks that path holds at least one PEM certificate.
+func ValidateCAFile(path string) error {
+	_, err := loadCAPool(path)
+	return err
+}
+
+// loadCAPool returns the system roots plus the certificates of the PEM
+// bundle at path, or nil (the system roots) for an empty path. The system
+// roots are kept so a bundle set as the default doesn't break targets with
+// public certificates.
+func loadCAPool(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA file: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates in CA file %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,39 @@
+package checker
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// certFingerprint returns the SHA-256 of a certificate's DER encoding as
+// lowercase hex, the same value `openssl x509 -fingerprint -sha256` prints
+// (minus the colons).
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeFingerprint accepts a SHA-256 fingerprint as plain hex, with
+// colons (as openssl and browsers show it) or with a "sha256:" prefix, and
+// returns it in the lowercase hex form stored with check results.
+func NormalizeFingerprint(s string) (string, error) {
+	fp := strings.ToLower(strings.TrimSpace(s))
+	fp = strings.TrimPrefix(fp, "sha256:")
+	fp = strings.TrimPrefix(fp, "sha256 fingerprint=")
+	fp = strings.ReplaceAll(fp, ":", "")
+	if b, err := hex.DecodeString(fp); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid SHA-256 fingerprint %q (want 64 hex digits, colons optional)", s)
+	}
+	return fp, nil
+}
+
+// ShortFingerprint abbreviates a fingerprint for one-line output.
+func ShortFingerprint(fp string) string {
+	if len(fp) <= 16 {
+		return fp
+	}
+	return fp[:16] + "…"
+}
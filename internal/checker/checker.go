@@ -1,13 +1,16 @@
 package checker
 
 import (
+	"context"
 	"crypto/sha256"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image/color"
 	"image/png"
 	"io"
+	"log/slog"
+	"mime"
 	"net"
 	"net/http"
 	"net/url"
@@ -16,6 +19,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -25,6 +29,8 @@ import (
 	"github.com/likexian/whois"
 	whoisparser "github.com/likexian/whois-parser"
 	"github.com/naru-bot/upp/internal/db"
+	"github.com/naru-bot/upp/internal/unixurl"
+	"github.com/naru-bot/upp/internal/urltemplate"
 )
 
 // isAcceptedStatus checks if a status code is in the accept-status spec.
@@ -103,52 +109,316 @@ type Result struct {
 	StatusCode   int
 	ResponseTime time.Duration
 	ContentHash  string
+	ContentType  string
 	Content      string
 	Error        string
 	SSLExpiry    *time.Time
 	BodyMatch    *bool   // nil if no expect keyword, true/false otherwise
-	DiffPercent  float64 // Visual diff percentage (for visual checks)
+	DiffPercent  float64 // Visual diff percentage, or estimated content change for change_threshold targets
+	Score        *int    // content score, for targets with score rules (http only)
+	Timing       Timing  // Phase breakdown (http and tcp-ping checks only)
+
+	CertFingerprint     string        // SHA-256 of the leaf certificate (https only)
+	CertChanged         bool          // leaf certificate differs from the last one seen (alert_cert_change targets)
+	PrevCertFingerprint string        // the last one seen, when CertChanged
+	TLSVersion          string        // negotiated TLS version, e.g. "TLS 1.3"
+	TLSCipher           string        // negotiated cipher suite
+	TLSChainValid       bool          // presented chain verifies, even if the target skips verification
+	AttemptErrors       []string      // error of each failed attempt when the target retries
+	RequestBytes        int64         // approximate bytes sent for the final request (http only)
+	ResponseBytes       int64         // response headers plus decoded body (http only)
+	RetryAfter          time.Duration // Retry-After of a 429 or 503 response (http only)
+	RemoteIP            string        // IP the check connected to (http and tcp); empty through a unix socket or proxy
+	Protocol            string        // negotiated HTTP protocol, e.g. "HTTP/2.0" (http only)
+	IPChanged           bool          // RemoteIP differs from the last one seen (alert_on_ip_change targets)
+	PrevRemoteIP        string        // the last one seen, when IPChanged
+	PrevContent         string        // content of the latest snapshot, when changed from it (diff-scoped triggers)
 }
 
-func Check(target *db.Target) *Result {
-	retries := target.Retries
-	if retries <= 0 {
-		retries = 1
+// softDownKeywords is the global soft-down list from config.
+var softDownKeywords []string
+
+// SetSoftDownKeywords installs the global list of phrases that mark a 2xx
+// response as a soft error page.
+func SetSoftDownKeywords(keywords []string) {
+	softDownKeywords = keywords
+}
+
+// defaultMaxBodyBytes caps how much of an http response body is read.
+const defaultMaxBodyBytes = 10 << 20
+
+// maxBodyBytes is the configured body read limit; zero uses the default.
+var maxBodyBytes int64
+
+// SetMaxBodyBytes sets how many bytes of an http response body a check
+// reads at most. Zero or less restores the 10 MiB default.
+func SetMaxBodyBytes(n int64) {
+	maxBodyBytes = n
+}
+
+// defaultMaxTotalTime is the configured ceiling on one check across all of
+// its retries; zero means none.
+var defaultMaxTotalTime time.Duration
+
+// SetDefaultMaxTotalTime sets the total time budget for targets that don't
+// set their own max_total_time.
+func SetDefaultMaxTotalTime(d time.Duration) {
+	defaultMaxTotalTime = d
+}
+
+// defaultFirstCheckStatus is the configured defaults.first_check_status.
+var defaultFirstCheckStatus string
+
+// SetDefaultFirstCheckStatus sets the status of a content check with
+// nothing stored to compare against: "baseline" (the default) or "up".
+func SetDefaultFirstCheckStatus(s string) {
+	defaultFirstCheckStatus = s
+}
+
+// firstCheckStatus is the status of a content check that has nothing to be
+// compared against, so its content becomes the baseline. Reporting "up"
+// instead is kept for tooling that predates the baseline status.
+func firstCheckStatus(ctx context.Context) string {
+	if envFrom(ctx).settings.FirstCheckStatus == "up" {
+		return "up"
+	}
+	return "baseline"
+}
+
+// defaultFailOnEmpty is the configured fail_on_empty for all targets.
+var defaultFailOnEmpty bool
+
+// SetDefaultFailOnEmpty makes every http check down on an empty response,
+// as if each target set fail_on_empty.
+func SetDefaultFailOnEmpty(b bool) {
+	defaultFailOnEmpty = b
+}
+
+// errTotalTimeExceeded is the cause attached to a check's context when its
+// total time budget runs out.
+var errTotalTimeExceeded = errors.New("exceeded total time budget")
+
+// Check runs a target's check, retrying on failure. Retries counts the extra
+// attempts after the first, so 0 checks exactly once. Cancelling ctx aborts
+// an in-flight check and any pending retries. When every attempt fails, the
+// result keeps each attempt's error and Error summarizes them all, so an
+// intermittent failure reads differently from a consistent one.
+//
+// A total time budget (the target's max_total_time, else the configured
+// default) bounds all attempts and the waits between them together. Once it
+// runs out the check stops, whatever retries remain, and reports that the
+// budget was exceeded rather than a plain timeout.
+//
+// With a confirm URL set, a failed check is re-checked by that instance
+// before it stands; see confirmDown.
+//
+// A target with Retries set to db.NoRetry records exactly one attempt: it is
+// neither retried nor re-checked from the confirm URL, so a transient blip
+// shows up in its history as it happened.
+func Check(ctx context.Context, target *db.Target) *Result {
+	// A composite reads stored member results; a retry would read the same
+	// results again.
+	if target.Type == "composite" {
+		return checkComposite(ctx, target)
+	}
+
+	attempts := max(target.Retries, 0) + 1
+
+	// A failure is confirmed outside the time budget, which it may have used up
+	parent := ctx
+	budget := time.Duration(target.MaxTotalTime) * time.Second
+	if budget <= 0 {
+		budget = envFrom(ctx).settings.MaxTotalTime
+	}
+	if budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, budget, errTotalTimeExceeded)
+		defer cancel()
 	}
 
 	var result *Result
-	for i := 0; i < retries; i++ {
-		result = checkOnce(target)
-		if result.Status == "up" || result.Status == "unchanged" || result.Status == "changed" {
+	var attemptErrs []string
+attempts:
+	for i := 0; i < attempts; i++ {
+		result = checkOnce(ctx, target)
+		slog.Debug("check finished",
+			"target", target.Name, "type", target.Type, "attempt", i+1,
+			"status", result.Status, "status_code", result.StatusCode,
+			"duration_ms", result.ResponseTime.Milliseconds(), "error", result.Error)
+		if result.Status == "up" || result.Status == "baseline" || result.Status == "unchanged" || result.Status == "changed" || result.Status == "redirect" {
 			return result
 		}
-		if i < retries-1 {
-			time.Sleep(2 * time.Second) // wait between retries
+		if context.Cause(ctx) == errTotalTimeExceeded {
+			attemptErrs = append(attemptErrs, errTotalTimeExceeded.Error())
+			break
+		}
+		msg := result.Error
+		if msg == "" {
+			msg = result.Status
 		}
+		attemptErrs = append(attemptErrs, msg)
+		if i < attempts-1 {
+			wait := 2 * time.Second // between retries
+			if result.RetryAfter > 0 {
+				// The server said when to come back; retrying sooner only
+				// adds to its load. A wait past the time budget or
+				// maxRetryAfter would just end in a timeout, so stop here.
+				wait = result.RetryAfter
+				deadline, ok := ctx.Deadline()
+				if wait > maxRetryAfter || ok && time.Now().Add(wait).After(deadline) {
+					slog.Debug("Retry-After too long to wait for, not retrying",
+						"target", target.Name, "retry_after", wait)
+					break
+				}
+			}
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				break attempts
+			}
+		}
+	}
+	if context.Cause(ctx) == errTotalTimeExceeded {
+		if len(attemptErrs) > 1 {
+			result.AttemptErrors = attemptErrs
+		}
+		result.Error = fmt.Sprintf("%s (%s) after %s", errTotalTimeExceeded, budget, pluralAttempts(len(attemptErrs)))
+		for i := len(attemptErrs) - 1; i >= 0; i-- {
+			if attemptErrs[i] != errTotalTimeExceeded.Error() {
+				result.Error += "; last error: " + attemptErrs[i]
+				break
+			}
+		}
+		slog.Debug("check exceeded total time budget", "target", target.Name, "budget", budget, "attempts", len(attemptErrs))
+		if target.Retries != db.NoRetry {
+			confirmDown(parent, target, result)
+		}
+		return result
+	}
+	if len(attemptErrs) > 1 {
+		result.AttemptErrors = attemptErrs
+		result.Error = summarizeAttempts(attemptErrs)
+	}
+	if target.Retries != db.NoRetry {
+		confirmDown(parent, target, result)
 	}
 	return result
 }
 
-func checkOnce(target *db.Target) *Result {
+// maxRetryAfter is the longest Retry-After a check waits out before
+// retrying, so a target asking for an hour can't stall checks without a
+// time budget.
+const maxRetryAfter = 5 * time.Minute
+
+// Record converts the result into the row stored in check history.
+func (r *Result) Record(targetID int64) *db.CheckResult {
+	rec := &db.CheckResult{
+		TargetID:        targetID,
+		Status:          r.Status,
+		StatusCode:      r.StatusCode,
+		ResponseTime:    r.ResponseTime.Milliseconds(),
+		ContentHash:     r.ContentHash,
+		ContentType:     r.ContentType,
+		Error:           r.Error,
+		DNSMs:           r.Timing.DNS.Milliseconds(),
+		ConnectMs:       r.Timing.Connect.Milliseconds(),
+		TLSMs:           r.Timing.TLS.Milliseconds(),
+		FirstByteMs:     r.Timing.FirstByte.Milliseconds(),
+		CertFingerprint: r.CertFingerprint,
+		TLSVersion:      r.TLSVersion,
+		TLSCipher:       r.TLSCipher,
+		TLSChainValid:   r.TLSChainValid,
+		AttemptErrors:   r.AttemptErrors,
+		RequestBytes:    r.RequestBytes,
+		ResponseBytes:   r.ResponseBytes,
+		RetryAfterMs:    r.RetryAfter.Milliseconds(),
+		RemoteIP:        r.RemoteIP,
+		CertExpiresAt:   r.SSLExpiry,
+	}
+	rec.SetProtocol(r.Protocol)
+	if r.Timing.DNSCached && r.Timing.DNS == 0 {
+		rec.SetDNSCached()
+	}
+	if r.Score != nil {
+		rec.SetScore(*r.Score)
+	}
+	return rec
+}
+
+// parseRetryAfter reads a Retry-After header, given either as seconds or
+// as an HTTP date. It returns 0 when the header is absent, malformed or in
+// the past.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+func pluralAttempts(n int) string {
+	if n == 1 {
+		return "1 attempt"
+	}
+	return fmt.Sprintf("%d attempts", n)
+}
+
+// summarizeAttempts condenses per-attempt errors into one line, keeping the
+// most specific part of each wrapped error: "connection refused (all 3
+// attempts)" or "attempt 1: i/o timeout; attempt 2: HTTP 503".
+func summarizeAttempts(errs []string) string {
+	brief := make([]string, len(errs))
+	same := true
+	for i, e := range errs {
+		if idx := strings.LastIndex(e, ": "); idx != -1 {
+			e = e[idx+2:]
+		}
+		brief[i] = e
+		if brief[i] != brief[0] {
+			same = false
+		}
+	}
+	if same {
+		return fmt.Sprintf("%s (all %d attempts)", brief[0], len(brief))
+	}
+	parts := make([]string, len(brief))
+	for i, e := range brief {
+		parts[i] = fmt.Sprintf("attempt %d: %s", i+1, e)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func checkOnce(ctx context.Context, target *db.Target) *Result {
 	switch target.Type {
 	case "http", "https":
-		return checkHTTP(target)
+		return checkHTTP(ctx, target)
 	case "tcp":
-		return checkTCP(target)
+		return checkTCP(ctx, target)
 	case "ping":
-		return checkPing(target)
+		return checkPing(ctx, target)
+	case "tcp-ping":
+		return checkTCPPing(ctx, target)
 	case "dns":
-		return checkDNS(target)
+		return checkDNS(ctx, target)
 	case "visual":
-		return checkVisual(target)
+		return checkVisual(ctx, target)
 	case "whois":
-		return checkWhois(target)
+		// The whois client has no context support; it is bounded by its own timeout.
+		return checkWhois(ctx, target)
+	case "statuspage":
+		return checkStatuspage(ctx, target)
 	default:
-		return checkHTTP(target)
+		return checkHTTP(ctx, target)
 	}
 }
 
-func checkHTTP(target *db.Target) *Result {
+func checkHTTP(ctx context.Context, target *db.Target) *Result {
 	start := time.Now()
 	result := &Result{}
 
@@ -157,17 +427,57 @@ func checkHTTP(target *db.Target) *Result {
 		timeout = 30 * time.Second
 	}
 
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: target.Insecure},
+	// The transport is shared across checks for connection reuse, so the
+	// per-target timeout is applied through the request context instead of
+	// http.Client.Timeout. It covers reading the body as well; the connect
+	// timeout only bounds establishing each connection.
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ctx = withConnectTimeout(ctx, time.Duration(target.ConnectTimeout)*time.Second)
+
+	// http+unix targets are requested as plain http over their socket, never
+	// through a proxy
+	key := transportKey{insecure: target.Insecure, caFile: caFile(ctx, target)}
+	key.clientCert, key.clientKey = clientCert(ctx, target)
+	reqURL := target.URL
+	if unixurl.Is(reqURL) {
+		socket, path, err := unixurl.Split(reqURL)
+		if err != nil {
+			result.Status = "error"
+			result.Error = err.Error()
+			return result
+		}
+		key.socket = socket
+		reqURL = unixurl.RequestURL(path)
+	} else {
+		key.proxy = target.Proxy
+	}
+
+	transport, err := sharedTransport(key)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
 	}
 	client := &http.Client{
-		Timeout:   timeout,
 		Transport: transport,
 	}
+	// The first redirect is kept so redirect_status can report it
+	var hop *redirectHop
 	if target.NoFollow {
 		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		}
+	} else {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if hop == nil {
+				hop = newRedirectHop(req.Response)
+			}
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		}
 	}
 
 	method := strings.ToUpper(target.Method)
@@ -178,28 +488,50 @@ func checkHTTP(target *db.Target) *Result {
 	if target.Body != "" {
 		bodyReader = strings.NewReader(target.Body)
 	}
-	req, err := http.NewRequest(method, target.URL, bodyReader)
+	if target.HeadOnly {
+		method = http.MethodHead
+		bodyReader = nil
+	}
+	if urltemplate.Has(reqURL) {
+		expanded, err := urltemplate.Expand(reqURL, urltemplate.Vars{Name: target.Name, ID: target.ID, Now: start})
+		if err != nil {
+			result.Status = "error"
+			result.Error = "URL template: " + err.Error()
+			return result
+		}
+		reqURL = expanded
+		slog.Debug("expanded URL template", "target", target.Name, "url", reqURL)
+	}
+	trace := newTimingTrace(start)
+	req, err := http.NewRequestWithContext(trace.withTrace(ctx), method, reqURL, bodyReader)
 	if err != nil {
 		result.Status = "error"
 		result.Error = err.Error()
 		result.ResponseTime = time.Since(start)
 		return result
 	}
-	req.Header.Set("User-Agent", "upp/1.0")
-	if target.Body != "" {
-		req.Header.Set("Content-Type", "application/json")
-	}
-	if target.Headers != "" {
-		var customHeaders map[string]string
-		if err := json.Unmarshal([]byte(target.Headers), &customHeaders); err == nil {
-			for k, v := range customHeaders {
-				req.Header.Set(k, v)
-			}
-		}
-	}
+	req.Header = requestHeaders(target, envFrom(ctx).settings.Headers)
 
 	resp, err := client.Do(req)
+	// Servers that don't support HEAD are asked again with a GET, whose
+	// body is left unread
+	if target.HeadOnly && err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		slog.Debug("HEAD not supported, retrying with GET", "url", target.URL, "status_code", resp.StatusCode)
+		method = http.MethodGet
+		hop = nil
+		req = req.Clone(req.Context())
+		req.Method = method
+		resp, err = client.Do(req)
+	}
 	result.ResponseTime = time.Since(start)
+	result.Timing = trace.timing()
+	// Through a socket or a proxy, the connection isn't to the target's IP
+	if key.socket == "" && key.proxy == "" {
+		result.RemoteIP = trace.remoteIP()
+	}
+	result.RequestBytes = requestSize(req)
+	checkIPChange(ctx, target, result)
 
 	if err != nil {
 		result.Status = "down"
@@ -209,19 +541,117 @@ func checkHTTP(target *db.Target) *Result {
 	defer resp.Body.Close()
 
 	result.StatusCode = resp.StatusCode
+	result.Protocol = resp.Proto
+	result.ContentType = resp.Header.Get("Content-Type")
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		result.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	slog.Debug("http response", "url", target.URL, "method", method,
+		"status_code", resp.StatusCode, "content_type", result.ContentType, "proto", resp.Proto)
 
 	// Check SSL
 	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
 		expiry := resp.TLS.PeerCertificates[0].NotAfter
 		result.SSLExpiry = &expiry
+		result.CertFingerprint = certFingerprint(resp.TLS.PeerCertificates[0])
+	}
+	if resp.TLS != nil {
+		info := tlsInfo(resp.TLS, resp.Request.URL.Hostname(), transport.TLSClientConfig.RootCAs)
+		result.TLSVersion = info.Version
+		result.TLSCipher = info.Cipher
+		result.TLSChainValid = info.ChainValid
+	}
+	if err := checkMinTLS(target, resp.TLS); err != nil {
+		result.Status = "down"
+		result.Error = err.Error()
+		return result
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	if target.CertPin != "" {
+		if result.CertFingerprint == "" {
+			result.Status = "down"
+			result.Error = "certificate pinned but no TLS certificate was presented"
+			return result
+		}
+		if result.CertFingerprint != target.CertPin {
+			result.Status = "down"
+			result.Error = fmt.Sprintf("certificate fingerprint mismatch: got %s, pinned %s",
+				ShortFingerprint(result.CertFingerprint), ShortFingerprint(target.CertPin))
+			return result
+		}
+	}
+	if target.AlertCertChange && result.CertFingerprint != "" {
+		if prev, err := envFrom(ctx).history.LastCertFingerprint(target.ID); err == nil && prev != "" && prev != result.CertFingerprint {
+			result.CertChanged = true
+			result.PrevCertFingerprint = prev
+		}
+	}
+
+	// A head_only check ends at the status line and headers: no body is
+	// read, so there is nothing to hash and change detection is off.
+	if target.HeadOnly {
+		result.ResponseBytes = headerSize(resp.Header) + int64(len(resp.Proto)+len(resp.Status)+3)
+		if target.ExpectContentType != "" && !sameMediaType(result.ContentType, target.ExpectContentType) {
+			result.Status = "down"
+			result.Error = fmt.Sprintf("expected content type %q, got %q", target.ExpectContentType, result.ContentType)
+			return result
+		}
+		if !isAcceptedStatus(resp.StatusCode, target.AcceptStatus) {
+			result.Status = "down"
+			result.Error = statusError(target, resp.StatusCode)
+			return result
+		}
+		result.Status = "up"
+		if target.NoFollow {
+			hop = newRedirectHop(resp)
+		}
+		applyRedirectStatus(result, hop, redirectMode(ctx, target))
+		return result
+	}
+
+	// Read one byte past the limit to tell a body that fits exactly from
+	// one that was cut off, so a target pointed at a huge download can't
+	// exhaust memory.
+	limit := envFrom(ctx).settings.MaxBodyBytes
+	if limit <= 0 {
+		limit = defaultMaxBodyBytes
+	}
+	var body []byte
+	truncated := false
+	if target.StreamMode {
+		// Streaming endpoints never finish the body; read only enough of
+		// it to evaluate the target, and count reaching the cap as healthy.
+		streamLimit := int64(target.ReadBytes)
+		if streamLimit <= 0 {
+			streamLimit = DefaultStreamReadBytes
+		}
+		body, err = readStream(ctx, resp.Body, min(streamLimit, limit), target.Expect, streamReadWindow)
+	} else {
+		body, err = io.ReadAll(io.LimitReader(resp.Body, limit+1))
+		truncated = int64(len(body)) > limit
+		if truncated {
+			body = body[:limit]
+		}
+	}
+	result.ResponseBytes = headerSize(resp.Header) + int64(len(resp.Proto)+len(resp.Status)+3+len(body))
 	if err != nil {
 		result.Status = "error"
 		result.Error = "failed to read body: " + err.Error()
 		return result
 	}
+	if truncated {
+		result.Status = "error"
+		result.Error = fmt.Sprintf("response body exceeds max_body_bytes (%d bytes); read truncated", limit)
+		return result
+	}
+
+	// Check expected content type before any parsing, so an HTML error page
+	// served in place of JSON is reported as such rather than as a jq failure
+	if target.ExpectContentType != "" && !sameMediaType(result.ContentType, target.ExpectContentType) {
+		result.Status = "down"
+		result.Error = fmt.Sprintf("expected content type %q, got %q", target.ExpectContentType, result.ContentType)
+		return result
+	}
 
 	// Apply jq filter if set (for JSON API monitoring)
 	content := string(body)
@@ -250,45 +680,101 @@ func checkHTTP(target *db.Target) *Result {
 				result.Error = "jq filter error: " + err.Error()
 				return result
 			}
-			switch val := v.(type) {
-			case string:
-				filtered = append(filtered, val)
-			default:
-				b, _ := json.MarshalIndent(val, "", "  ")
-				filtered = append(filtered, string(b))
-			}
+			filtered = append(filtered, jsonText(v))
 		}
 		content = strings.Join(filtered, "\n")
 	}
 
-	// Extract content based on selector (for HTML pages)
-	if target.JQFilter == "" && target.Selector != "" {
-		doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
-		if err == nil {
-			var selected []string
-			doc.Find(target.Selector).Each(func(i int, s *goquery.Selection) {
-				// Strip style/script so CSS/JS doesn't pollute extracted text.
-				s.Find("style,script").Remove()
-				selected = append(selected, strings.TrimSpace(s.Text()))
-			})
-			if len(selected) > 0 {
-				content = strings.Join(selected, "\n")
+	// Pluck values with a JSON path, the simpler alternative to jq. Only
+	// JSON responses are looked at, so an HTML error page is reported as
+	// such instead of as a missing field.
+	if target.JSONPath != "" {
+		if !isJSONType(result.ContentType) {
+			result.Status = "error"
+			result.Error = fmt.Sprintf("json path needs a JSON response, got %s", result.ContentType)
+			return result
+		}
+		var jsonData interface{}
+		if err := json.Unmarshal(body, &jsonData); err != nil {
+			result.Status = "error"
+			result.Error = "response is not valid JSON: " + err.Error()
+			return result
+		}
+		path, err := ParseJSONPath(target.JSONPath)
+		if err != nil {
+			result.Status = "error"
+			result.Error = err.Error()
+			return result
+		}
+		values, err := path.Eval(jsonData)
+		if err != nil {
+			result.Status = "error"
+			result.Error = "json path " + target.JSONPath + ": " + err.Error()
+			return result
+		}
+		var picked []string
+		for _, v := range values {
+			picked = append(picked, jsonText(v))
+		}
+		content = strings.Join(picked, "\n")
+	}
+
+	// Extract content based on selector (for HTML pages). A selector that
+	// matches nothing on an accepted response falls back to the whole page
+	// with a warning, or is down for a strict_selector target. Of a list of
+	// selectors, the ones that match are watched, with a warning naming
+	// the first that didn't.
+	selectorMissed := false
+	var missedSelector string
+	if target.JQFilter == "" && target.JSONPath == "" && target.Selector != "" {
+		selected, missed, err := SelectText(target.SelectorType, target.Selector, content)
+		if err == nil && len(selected) > 0 {
+			content = strings.Join(selected, "\n")
+		}
+		if (err != nil || len(missed) > 0) && isAcceptedStatus(resp.StatusCode, target.AcceptStatus) {
+			missedSelector = target.Selector
+			if len(missed) > 0 {
+				missedSelector = missed[0]
+			}
+			if target.StrictSelector {
+				result.Status = "down"
+				result.Error = fmt.Sprintf("selector %q matched nothing", missedSelector)
+				return result
 			}
+			selectorMissed = err != nil || len(selected) == 0
 		}
 	}
 
-	result.Content = content
-	// Strip dynamic tokens (CSRF, nonces, etc.) before hashing
-	// so that only meaningful content changes are detected
-	normalized := stripDynamicContent(content)
-	hash := sha256.Sum256([]byte(normalized))
-	result.ContentHash = fmt.Sprintf("%x", hash)
+	empty := strings.TrimSpace(content) == ""
 
 	// Check expected keyword
 	if target.Expect != "" {
 		matched := strings.Contains(content, target.Expect)
 		result.BodyMatch = &matched
 	}
+	// A keyword that must never appear, such as an error banner
+	absentFound := target.ExpectAbsent != "" && strings.Contains(content, target.ExpectAbsent)
+
+	// Score the content against the target's weighted keywords
+	var scoreHits []ScoreHit
+	if len(target.ScoreRules) > 0 {
+		score, hits := ScoreContent(target.ScoreRules, content)
+		result.Score, scoreHits = &score, hits
+	}
+
+	// A stream reads differently every time, so stream mode checks health
+	// only and leaves change detection out.
+	if !target.StreamMode {
+		result.Content = content
+		// Strip dynamic tokens (CSRF, nonces, etc.) before hashing
+		// so that only meaningful content changes are detected. Selected
+		// response headers (ETag, Last-Modified, ...) are hashed too, so
+		// backend changes that don't alter the body are detected, but
+		// stay out of the content that is stored and diffed.
+		normalized := stripDynamicContent(content) + hashHeaderBlock(target.HashHeaders, resp.Header)
+		hash := sha256.Sum256([]byte(normalized))
+		result.ContentHash = fmt.Sprintf("%x", hash)
+	}
 
 	// Determine status
 	if isAcceptedStatus(resp.StatusCode, target.AcceptStatus) {
@@ -298,26 +784,172 @@ func checkHTTP(target *db.Target) *Result {
 			result.Error = fmt.Sprintf("expected keyword %q not found", target.Expect)
 			return result
 		}
+		if absentFound {
+			result.Status = "down"
+			result.Error = fmt.Sprintf("unwanted keyword %q found", target.ExpectAbsent)
+			return result
+		}
 
-		snaps, err := db.GetLatestSnapshots(target.ID, 1)
-		if err == nil && len(snaps) > 0 {
-			if snaps[0].Hash != result.ContentHash {
-				result.Status = "changed"
+		if empty && (target.FailOnEmpty || envFrom(ctx).settings.FailOnEmpty) {
+			result.Status = "down"
+			if target.Selector != "" || target.JQFilter != "" || target.JSONPath != "" {
+				result.Error = "empty response: selected content is empty or whitespace only"
 			} else {
-				result.Status = "unchanged"
+				result.Error = "empty response: body is empty or whitespace only"
 			}
-		} else {
+			return result
+		}
+
+		// Content pinned to a known hash is down on any change from it
+		if target.ExpectHash != "" && !target.StreamMode && result.ContentHash != target.ExpectHash {
+			result.Status = "down"
+			result.Error = fmt.Sprintf("content hash mismatch: got %s, expected %s",
+				ShortFingerprint(result.ContentHash), ShortFingerprint(target.ExpectHash))
+			return result
+		}
+
+		// Catch error pages served with a success status. JSON APIs filtered
+		// with jq are skipped; their payloads aren't error pages.
+		if target.JQFilter == "" {
+			if kw := softDownMatch(visibleText(body, result.ContentType), target.SoftDownKeywords, envFrom(ctx).settings.SoftDownKeywords); kw != "" {
+				result.Status = "down"
+				result.Error = fmt.Sprintf("soft error page: response contains %q", kw)
+				return result
+			}
+		}
+
+		if result.Score != nil && *result.Score < target.ScoreMin {
+			result.Status = "down"
+			result.Error = fmt.Sprintf("content score %d below %d: %s", *result.Score, target.ScoreMin, scorePenalties(scoreHits))
+			return result
+		}
+
+		if target.StreamMode {
 			result.Status = "up"
+		} else {
+			result.Status = snapshotStatus(ctx, target, result)
+		}
+		if selectorMissed {
+			addWarning(result, fmt.Sprintf("selector %q matched nothing; watching the whole page", target.Selector))
+		} else if missedSelector != "" {
+			addWarning(result, fmt.Sprintf("selector %q matched nothing; watching the rest", missedSelector))
 		}
+		if result.Score != nil && *result.Score < target.ScoreWarn {
+			addWarning(result, fmt.Sprintf("content score %d below %d: %s", *result.Score, target.ScoreWarn, scorePenalties(scoreHits)))
+		}
+
+		// Warn when the content type drifts from the previous check,
+		// even if no explicit expectation is configured
+		if prev, err := envFrom(ctx).history.LastResult(target.ID); err == nil && prev != nil {
+			if prev.ContentType != "" && !sameMediaType(prev.ContentType, result.ContentType) {
+				addWarning(result, fmt.Sprintf("content type changed: %s → %s", prev.ContentType, result.ContentType))
+			}
+		}
+
+		if target.NoFollow {
+			hop = newRedirectHop(resp)
+		}
+		applyRedirectStatus(result, hop, redirectMode(ctx, target))
 	} else {
 		result.Status = "down"
-		result.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		result.Error = statusError(target, resp.StatusCode)
 	}
 
 	return result
 }
 
-func checkTCP(target *db.Target) *Result {
+// addWarning adds a warning to a successful result, after any it already
+// carries, so one warning doesn't hide another.
+func addWarning(result *Result, msg string) {
+	if result.Error == "" {
+		result.Error = "⚠ " + msg
+		return
+	}
+	result.Error += "; " + msg
+}
+
+// softDownMatch returns the first soft-down keyword found in body, or "".
+// A target's own list replaces the global one; a list of just "none"
+// disables the check for that target.
+func softDownMatch(body string, targetKeywords, globalKeywords []string) string {
+	keywords := targetKeywords
+	if len(keywords) == 0 {
+		keywords = globalKeywords
+	}
+	if len(keywords) == 1 && strings.EqualFold(keywords[0], "none") {
+		return ""
+	}
+	lower := strings.ToLower(body)
+	for _, kw := range keywords {
+		if kw != "" && strings.Contains(lower, strings.ToLower(kw)) {
+			return kw
+		}
+	}
+	return ""
+}
+
+// visibleText returns the human-visible text of an HTML body (scripts and
+// styles dropped) so markup and asset URLs don't match soft-down keywords.
+// Non-HTML bodies are returned as-is.
+func visibleText(body []byte, contentType string) string {
+	if mediaType(contentType) != "text/html" {
+		return string(body)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
+	if err != nil {
+		return string(body)
+	}
+	doc.Find("script,style,noscript").Remove()
+	return doc.Text()
+}
+
+// sameMediaType reports whether two Content-Type values share the same media type,
+// ignoring parameters such as charset.
+func sameMediaType(a, b string) bool {
+	return mediaType(a) == mediaType(b)
+}
+
+func mediaType(contentType string) string {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mt
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// requestSize estimates the bytes of an HTTP/1.1 request on the wire: the
+// request line, headers and body. Transport-added headers are not counted.
+func requestSize(req *http.Request) int64 {
+	n := int64(len(req.Method)+len(req.URL.RequestURI())+len(req.Proto)+4) + headerSize(req.Header)
+	n += int64(len("Host: ")+len(req.Host)+2) + 2
+	if req.ContentLength > 0 {
+		n += req.ContentLength
+	}
+	return n
+}
+
+// headerSize counts "Name: value\r\n" for every header value.
+func headerSize(h http.Header) int64 {
+	var n int64
+	for k, vs := range h {
+		for _, v := range vs {
+			n += int64(len(k) + len(v) + 4)
+		}
+	}
+	return n
+}
+
+// hashHeaderBlock returns "\nName: value" lines for the named response
+// headers, to be hashed along with the content. Headers that are absent
+// contribute an empty value rather than an error.
+func hashHeaderBlock(names []string, header http.Header) string {
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("\n%s: %s", http.CanonicalHeaderKey(name), header.Get(name)))
+	}
+	return sb.String()
+}
+
+func checkTCP(ctx context.Context, target *db.Target) *Result {
 	start := time.Now()
 	result := &Result{}
 
@@ -326,7 +958,9 @@ func checkTCP(target *db.Target) *Result {
 		timeout = 10 * time.Second
 	}
 
-	conn, err := net.DialTimeout("tcp", target.URL, timeout)
+	dialer := net.Dialer{Timeout: timeout}
+	dial := dialWithConnectTimeout(dialer.DialContext)
+	conn, err := dial(withConnectTimeout(ctx, time.Duration(target.ConnectTimeout)*time.Second), "tcp", target.URL)
 	result.ResponseTime = time.Since(start)
 
 	if err != nil {
@@ -334,16 +968,42 @@ func checkTCP(target *db.Target) *Result {
 		result.Error = err.Error()
 		return result
 	}
-	conn.Close()
-	result.Status = "up"
+	defer conn.Close()
+	result.RemoteIP = addrIP(conn.RemoteAddr())
+	checkIPChange(ctx, target, result)
+
+	// Services that greet first (SSH, SMTP, FTP, ...) give comparable
+	// content; silent ones just report up.
+	banner := readBanner(conn, min(bannerWait, timeout))
+	if banner == "" {
+		result.Status = "up"
+		return result
+	}
+	result.Content = banner
+	hash := sha256.Sum256([]byte(banner))
+	result.ContentHash = fmt.Sprintf("%x", hash)
+	result.Status = snapshotStatus(ctx, target, result)
 	return result
 }
 
-func checkPing(target *db.Target) *Result {
+// bannerWait is how long a tcp check waits for the server to speak first.
+const bannerWait = time.Second
+
+// readBanner returns the first line a server sends after connecting, or ""
+// if it sends nothing within wait.
+func readBanner(conn net.Conn, wait time.Duration) string {
+	conn.SetReadDeadline(time.Now().Add(wait))
+	buf := make([]byte, 512)
+	n, _ := conn.Read(buf)
+	line, _, _ := strings.Cut(string(buf[:n]), "\n")
+	return strings.TrimSpace(line)
+}
+
+func checkPing(ctx context.Context, target *db.Target) *Result {
 	start := time.Now()
 	result := &Result{}
 
-	cmd := exec.Command("ping", "-c", "1", "-W", "5", target.URL)
+	cmd := exec.CommandContext(ctx, "ping", "-c", "1", "-W", "5", target.URL)
 	err := cmd.Run()
 	result.ResponseTime = time.Since(start)
 
@@ -356,7 +1016,7 @@ func checkPing(target *db.Target) *Result {
 	return result
 }
 
-func checkDNS(target *db.Target) *Result {
+func checkDNS(ctx context.Context, target *db.Target) *Result {
 	start := time.Now()
 	result := &Result{}
 
@@ -368,7 +1028,14 @@ func checkDNS(target *db.Target) *Result {
 		}
 	}
 
-	addrs, err := net.LookupHost(host)
+	timeout := time.Duration(target.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
 	result.ResponseTime = time.Since(start)
 
 	if err != nil {
@@ -381,40 +1048,104 @@ func checkDNS(target *db.Target) *Result {
 		return result
 	}
 
-	// Build content with resolved addresses
+	// Build content with resolved addresses. Records are sorted so a
+	// resolver rotating their order doesn't count as a change.
+	sort.Strings(addrs)
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("Domain: %s\n", host))
 	sb.WriteString(fmt.Sprintf("Resolved: %s\n", strings.Join(addrs, ", ")))
 
 	// Also try MX, NS, TXT records
-	if mx, err := net.LookupMX(host); err == nil && len(mx) > 0 {
+	if mx, err := net.DefaultResolver.LookupMX(ctx, host); err == nil && len(mx) > 0 {
 		var mxHosts []string
 		for _, m := range mx {
 			mxHosts = append(mxHosts, fmt.Sprintf("%s (pri %d)", m.Host, m.Pref))
 		}
+		sort.Strings(mxHosts)
 		sb.WriteString(fmt.Sprintf("MX: %s\n", strings.Join(mxHosts, ", ")))
 	}
-	if ns, err := net.LookupNS(host); err == nil && len(ns) > 0 {
+	if ns, err := net.DefaultResolver.LookupNS(ctx, host); err == nil && len(ns) > 0 {
 		var nsHosts []string
 		for _, n := range ns {
 			nsHosts = append(nsHosts, n.Host)
 		}
+		sort.Strings(nsHosts)
 		sb.WriteString(fmt.Sprintf("NS: %s\n", strings.Join(nsHosts, ", ")))
 	}
-	if txt, err := net.LookupTXT(host); err == nil && len(txt) > 0 {
+	if txt, err := net.DefaultResolver.LookupTXT(ctx, host); err == nil && len(txt) > 0 {
+		sort.Strings(txt)
 		sb.WriteString(fmt.Sprintf("TXT: %s\n", strings.Join(txt, "; ")))
 	}
+	// Records cut short by the timeout or a shutdown would look like a change
+	if ctx.Err() != nil {
+		result.Status = "error"
+		result.Error = fmt.Sprintf("looking up records: %v", ctx.Err())
+		return result
+	}
 
 	result.Content = sb.String()
 	hash := sha256.Sum256([]byte(result.Content))
 	result.ContentHash = fmt.Sprintf("%x", hash)
-	result.Status = "up"
+	result.Status = snapshotStatus(ctx, target, result)
 	return result
 }
 
-// getScreenshotDir returns the directory where screenshots are stored
-func getScreenshotDir() (string, error) {
-	dataDir := filepath.Dir(db.GetDBPath())
+// snapshotStatus compares a result's content with the target's latest
+// snapshot: "changed" or "unchanged" when there is one, "baseline" on the
+// first check, whose content the later ones are compared against. A target
+// with a change_threshold only counts as changed once the estimated share of
+// differing content exceeds it. Without a snapshot (a snapshot_mode never
+// target), the last result's content hash is compared instead, and any
+// difference counts as changed.
+func snapshotStatus(ctx context.Context, target *db.Target, result *Result) string {
+	history := envFrom(ctx).history
+	snap, err := history.LatestSnapshot(target.ID)
+	if err != nil {
+		return "up"
+	}
+	if snap == nil {
+		last, err := history.LastResult(target.ID)
+		switch {
+		case err != nil:
+			return "up"
+		case last == nil || last.ContentHash == "":
+			return firstCheckStatus(ctx)
+		case last.ContentHash == result.ContentHash:
+			return "unchanged"
+		}
+		return "changed"
+	}
+	if snap.Hash == result.ContentHash {
+		return "unchanged"
+	}
+	result.PrevContent = snap.Content
+	if target.ChangeThreshold <= 0 {
+		return "changed"
+	}
+	prev := snap.Signature
+	if prev == "" {
+		// Snapshot saved before signatures were stored
+		prev = ContentSignature(snap.Content)
+	}
+	change, ok := signatureChange(prev, ContentSignature(result.Content))
+	if !ok {
+		return "changed"
+	}
+	result.DiffPercent = change
+	if change <= target.ChangeThreshold {
+		return "unchanged"
+	}
+	result.Error = fmt.Sprintf("content diff: %.1f%% (threshold: %.1f%%)", change, target.ChangeThreshold)
+	return "changed"
+}
+
+// getScreenshotDir returns the directory where screenshots are stored:
+// next to the database, or in the temp dir when no data dir is set.
+func getScreenshotDir(ctx context.Context) (string, error) {
+	dataDir := envFrom(ctx).settings.DataDir
+	if dataDir == "" {
+		dataDir = filepath.Join(os.TempDir(), "upp")
+	}
 	screenshotDir := filepath.Join(dataDir, "screenshots")
 	return screenshotDir, os.MkdirAll(screenshotDir, 0755)
 }
@@ -460,7 +1191,7 @@ func snapWritableDir() string {
 }
 
 // takeScreenshot captures a screenshot of the URL using headless browser
-func takeScreenshot(url, outputPath string, timeout time.Duration) error {
+func takeScreenshot(ctx context.Context, url, outputPath string, timeout time.Duration) error {
 	binary, args := findHeadlessBrowser()
 	if binary == "" {
 		return fmt.Errorf("no headless browser found (run 'upp doctor' for install instructions)")
@@ -500,9 +1231,17 @@ func takeScreenshot(url, outputPath string, timeout time.Duration) error {
 		case <-time.After(timeout):
 			cmd.Process.Kill()
 			return fmt.Errorf("screenshot timed out after %v", timeout)
+		case <-ctx.Done():
+			cmd.Process.Kill()
+			return ctx.Err()
 		}
 	} else {
-		err = <-done
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			cmd.Process.Kill()
+			return ctx.Err()
+		}
 	}
 
 	if err != nil {
@@ -584,7 +1323,7 @@ func compareImages(img1Path, img2Path string) (float64, error) {
 	return float64(diffPixels) / float64(totalPixels) * 100.0, nil
 }
 
-func checkVisual(target *db.Target) *Result {
+func checkVisual(ctx context.Context, target *db.Target) *Result {
 	start := time.Now()
 	result := &Result{}
 
@@ -599,7 +1338,7 @@ func checkVisual(target *db.Target) *Result {
 	}
 
 	// Get screenshot directory
-	screenshotDir, err := getScreenshotDir()
+	screenshotDir, err := getScreenshotDir(ctx)
 	if err != nil {
 		result.Status = "error"
 		result.Error = fmt.Sprintf("failed to create screenshot directory: %v", err)
@@ -616,7 +1355,7 @@ func checkVisual(target *db.Target) *Result {
 	}
 
 	// Take new screenshot
-	if err := takeScreenshot(target.URL, currentPath, timeout); err != nil {
+	if err := takeScreenshot(ctx, target.URL, currentPath, timeout); err != nil {
 		result.Status = "error"
 		result.Error = fmt.Sprintf("failed to take screenshot: %v", err)
 		result.ResponseTime = time.Since(start)
@@ -662,14 +1401,14 @@ func checkVisual(target *db.Target) *Result {
 			result.Status = "unchanged"
 		}
 	} else {
-		result.Status = "up" // First run
+		result.Status = firstCheckStatus(ctx) // First run
 		result.DiffPercent = 0.0
 	}
 
 	return result
 }
 
-func checkWhois(target *db.Target) *Result {
+func checkWhois(ctx context.Context, target *db.Target) *Result {
 	start := time.Now()
 	result := &Result{}
 
@@ -720,18 +1459,7 @@ func checkWhois(target *db.Target) *Result {
 		}
 	}
 
-	// Compare with previous snapshot
-	snaps, err := db.GetLatestSnapshots(target.ID, 1)
-	if err == nil && len(snaps) > 0 {
-		if snaps[0].Hash != result.ContentHash {
-			result.Status = "changed"
-		} else {
-			result.Status = "unchanged"
-		}
-	} else {
-		result.Status = "up"
-	}
-
+	result.Status = snapshotStatus(ctx, target, result)
 	return result
 }
 
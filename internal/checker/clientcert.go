@@ -0,0 +1,69 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+var (
+	defaultClientCert string // configured defaults.client_cert
+	defaultClientKey  string // configured defaults.client_key
+)
+
+// SetDefaultClientCert sets the client certificate and key presented for
+// mutual TLS by targets without their own. An empty key means the
+// certificate file holds the key as well.
+func SetDefaultClientCert(cert, key string) {
+	defaultClientCert, defaultClientKey = cert, key
+}
+
+// clientCert returns the client certificate and key files a target
+// presents: its own client_cert, else the configured default. Empty cert
+// means none.
+func clientCert(ctx context.Context, target *db.Target) (cert, key string) {
+	cert, key = target.ClientCert, target.ClientKey
+	if cert == "" {
+		s := envFrom(ctx).settings
+		cert, key = s.ClientCert, s.ClientKey
+	}
+	if key == "" {
+		key = cert
+	}
+	return cert, key
+}
+
+// LoadClientCert loads a client certificate and its private key, which may
+// be in the certificate file. It returns the parsed leaf so callers can
+// describe it.
+func LoadClientCert(cert, key string) (*tls.Certificate, error) {
+	if key == "" {
+		key = cert
+	}
+	pair, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return nil, fmt.Errorf("loading client certificate: %w", err)
+	}
+	if pair.Leaf == nil {
+		if pair.Leaf, err = x509.ParseCertificate(pair.Certificate[0]); err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+	}
+	return &pair, nil
+}
+
+// clientCertificates loads the pair a transport presents, or none for an
+// empty cert.
+func clientCertificates(cert, key string) ([]tls.Certificate, error) {
+	if cert == "" {
+		return nil, nil
+	}
+	pair, err := LoadClientCert(cert, key)
+	if err != nil {
+		return nil, err
+	}
+	return []tls.Certificate{*pair}, nil
+}
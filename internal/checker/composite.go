@@ -0,0 +1,99 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// checkComposite derives a rollup status from the latest results of the
+// member targets instead of making a request. The target is up when at
+// least Quorum members are up (all of them when Quorum is 0). Paused
+// members, members that were removed and members not checked yet are left
+// out of the count, and a quorum above the remaining members is lowered to
+// match, so pausing one piece of a service doesn't mark the service down.
+func checkComposite(ctx context.Context, target *db.Target) *Result {
+	start := time.Now()
+	result := &Result{}
+
+	ids, err := db.CompositeMembers(target.URL)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	history := envFrom(ctx).history
+	all, err := history.Targets()
+	if err != nil {
+		result.Status = "error"
+		result.Error = "listing members: " + err.Error()
+		return result
+	}
+	byID := make(map[int64]db.Target, len(all))
+	for _, t := range all {
+		byID[t.ID] = t
+	}
+
+	var up, counted int
+	var failing, skipped []string
+	for _, id := range ids {
+		m, ok := byID[id]
+		if !ok {
+			skipped = append(skipped, fmt.Sprintf("#%d removed", id))
+			continue
+		}
+		if m.Paused {
+			skipped = append(skipped, m.Name+" paused")
+			continue
+		}
+		last, err := history.LastResult(m.ID)
+		if err != nil || last == nil {
+			skipped = append(skipped, m.Name+" not checked yet")
+			continue
+		}
+		counted++
+		if db.IsUp(last.Status) {
+			up++
+		} else {
+			failing = append(failing, m.Name+" "+last.Status)
+		}
+	}
+	result.ResponseTime = time.Since(start)
+
+	need := counted
+	if target.Quorum > 0 && target.Quorum < counted {
+		need = target.Quorum
+	}
+	var detail []string
+	if len(failing) > 0 {
+		detail = append(detail, strings.Join(failing, ", "))
+	}
+	if len(skipped) > 0 {
+		detail = append(detail, "skipped: "+strings.Join(skipped, ", "))
+	}
+	summary := fmt.Sprintf("%d/%d members up", up, counted)
+	if len(detail) > 0 {
+		summary += " (" + strings.Join(detail, "; ") + ")"
+	}
+
+	switch {
+	case counted == 0:
+		result.Status = "error"
+		result.Error = "no members to roll up"
+		if len(skipped) > 0 {
+			result.Error += " (" + strings.Join(skipped, ", ") + ")"
+		}
+	case up >= need:
+		result.Status = "up"
+		if up < counted || len(skipped) > 0 {
+			result.Error = "⚠ " + summary
+		}
+	default:
+		result.Status = "down"
+		result.Error = fmt.Sprintf("%s, need %d", summary, need)
+	}
+	return result
+}
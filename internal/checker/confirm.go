@@ -0,0 +1,132 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/naru-bot/upp/internal/db"
+	"github.com/naru-bot/upp/internal/unixurl"
+)
+
+// DownLocalOnly is the status of a check that failed here while the
+// confirm_url instance found the target up: likely a problem on this side,
+// so it doesn't alert.
+const DownLocalOnly = "down-local-only"
+
+// ConfirmResponse is what a confirm_url instance ('upp confirm-serve')
+// answers with: its own check of the target posted to it.
+type ConfirmResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ConfirmRequest is what a check posts to a confirm_url instance: just
+// enough to check the target again. Headers, bodies, proxies and
+// certificate paths stay on this side.
+type ConfirmRequest struct {
+	URL          string `json:"url"`
+	Type         string `json:"type"`
+	Timeout      int    `json:"timeout,omitempty"`
+	AcceptStatus string `json:"accept_status,omitempty"`
+}
+
+// Target turns a posted request into the target to check, rejecting what
+// a confirming instance must not fetch on someone else's behalf: unix
+// sockets and composite rollups.
+func (r *ConfirmRequest) Target() (*db.Target, error) {
+	if unixurl.Is(r.URL) {
+		return nil, fmt.Errorf("%s URLs can't be confirmed", unixurl.Scheme)
+	}
+	if r.Type == "composite" {
+		return nil, fmt.Errorf("composite targets can't be confirmed")
+	}
+	t := &db.Target{Name: r.URL, URL: r.URL, Type: r.Type, Timeout: r.Timeout, AcceptStatus: r.AcceptStatus}
+	if err := db.ValidateTarget(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// confirmTimeout bounds one confirmation request, which runs a whole check
+// on the other side.
+const confirmTimeout = 2 * time.Minute
+
+var confirmURL, confirmToken string
+
+// SetConfirm sets the instance consulted before a check reports a target
+// down, and the bearer token sent to it. An empty url turns confirmation
+// off.
+func SetConfirm(url, token string) {
+	confirmURL, confirmToken = url, token
+}
+
+// confirmDown asks the confirm_url instance to check a target that failed
+// here. When it finds the target up, the result becomes DownLocalOnly. If it
+// can't be reached, or agrees, the result stands.
+func confirmDown(ctx context.Context, target *db.Target, result *Result) {
+	s := envFrom(ctx).settings
+	if s.ConfirmURL == "" || (result.Status != "down" && result.Status != "error") {
+		return
+	}
+	remote, err := askConfirm(ctx, s.ConfirmURL, s.ConfirmToken, target)
+	if err != nil {
+		slog.Warn("down confirmation failed, keeping the local result", "target", target.Name, "confirm_url", s.ConfirmURL, "err", err)
+		return
+	}
+	if remote.Status == "down" || remote.Status == "error" || remote.Status == DownLocalOnly {
+		slog.Debug("down confirmed remotely", "target", target.Name, "remote_status", remote.Status, "remote_error", remote.Error)
+		return
+	}
+	host := s.ConfirmURL
+	if u, err := url.Parse(s.ConfirmURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	slog.Info("down here but up remotely", "target", target.Name, "confirm_url", s.ConfirmURL, "remote_status", remote.Status)
+	result.Status = DownLocalOnly
+	result.Error = fmt.Sprintf("%s (up from %s)", result.Error, host)
+}
+
+// askConfirm posts the target's address to a confirm_url instance and
+// returns its check result.
+func askConfirm(ctx context.Context, endpoint, token string, target *db.Target) (*ConfirmResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, confirmTimeout)
+	defer cancel()
+	body, err := json.Marshal(ConfirmRequest{
+		URL:          target.URL,
+		Type:         target.Type,
+		Timeout:      target.Timeout,
+		AcceptStatus: target.AcceptStatus,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	var r ConfirmResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&r); err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	return &r, nil
+}
@@ -0,0 +1,102 @@
+package checker
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// dnsCache keeps resolved host addresses for a fixed TTL so frequent checks
+// of the same host skip the lookup. Go's resolver doesn't expose record
+// TTLs, so every entry lives for the configured TTL; keep it at or below the
+// records' own TTL. Failed lookups are never cached, and an entry is dropped
+// as soon as none of its addresses accept a connection, so a failover is
+// picked up by the next check.
+type dnsCache struct {
+	ttl      time.Duration
+	resolver *net.Resolver
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+func newDNSCache(ttl time.Duration) *dnsCache {
+	return &dnsCache{
+		ttl:      ttl,
+		resolver: net.DefaultResolver,
+		entries:  make(map[string]dnsEntry),
+	}
+}
+
+// lookup returns the cached addresses for host, resolving it on a miss. A
+// hit is reported to the check's timing as a cached lookup and a miss is
+// timed as its DNS phase, since the transport doesn't see either.
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	c.mu.Lock()
+	e, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		if cached, ok := ctx.Value(dnsCachedKey{}).(func()); ok {
+			cached()
+		}
+		return e.addrs, nil
+	}
+
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+	addrs, err := c.resolver.LookupHost(ctx, host)
+	if trace != nil && trace.DNSDone != nil {
+		trace.DNSDone(httptrace.DNSDoneInfo{Err: err})
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[host] = dnsEntry{addrs: addrs, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return addrs, nil
+}
+
+func (c *dnsCache) forget(host string) {
+	c.mu.Lock()
+	delete(c.entries, host)
+	c.mu.Unlock()
+}
+
+// dialContext wraps d so host names are resolved through the cache. The
+// addresses are tried in order, like net.Dialer does.
+func (c *dnsCache) dialContext(d *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return d.DialContext(ctx, network, addr)
+		}
+		addrs, err := c.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range addrs {
+			var conn net.Conn
+			conn, err = d.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			if ctx.Err() != nil {
+				return nil, err
+			}
+		}
+		slog.Debug("cached addresses unreachable, dropping DNS cache entry", "host", host, "err", err)
+		c.forget(host)
+		return nil, err
+	}
+}
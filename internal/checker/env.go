@@ -0,0 +1,124 @@
+package checker
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// History is the stored state a check compares against: the previous
+// snapshot, result, certificate and remote IP of a target, and the targets a composite
+// rolls up. Each method returns nil or "" when there is nothing stored yet.
+type History interface {
+	LatestSnapshot(targetID int64) (*db.Snapshot, error)
+	LastResult(targetID int64) (*db.CheckResult, error)
+	LastCertFingerprint(targetID int64) (string, error)
+	LastRemoteIP(targetID int64) (string, error)
+	Targets() ([]db.Target, error)
+}
+
+// Settings are the check-wide options that the CLI reads from config.
+// Zero fields use the built-in defaults.
+type Settings struct {
+	SoftDownKeywords []string          // phrases marking a 2xx response as a soft error page
+	MaxBodyBytes     int64             // http body read limit (default 10 MiB)
+	MaxTotalTime     time.Duration     // budget for targets without max_total_time
+	Headers          map[string]string // sent with every http check
+	DataDir          string            // where visual checks keep screenshots
+	RedirectStatus   string            // how a 3xx is reported: up (default), warn or redirect
+	FailOnEmpty      bool              // an empty or whitespace-only response is down for every target
+	FirstCheckStatus string            // status of a content check with nothing to compare against: baseline (default) or up
+	SnapshotMode     string            // when content is stored for targets without their own: always, on_change (default) or never
+	CAFile           string            // PEM CA bundle trusted on top of the system roots by targets without their own
+	ClientCert       string            // PEM client certificate for mutual TLS, for targets without their own
+	ClientKey        string            // private key of ClientCert; empty when the certificate file holds it
+	ConfirmURL       string            // instance that re-checks a failed target; empty for none
+	ConfirmToken     string            // bearer token sent to ConfirmURL
+}
+
+// dbHistory reads history from the open database.
+type dbHistory struct{}
+
+func (dbHistory) LatestSnapshot(targetID int64) (*db.Snapshot, error) {
+	return db.GetLatestSnapshot(targetID)
+}
+
+func (dbHistory) LastResult(targetID int64) (*db.CheckResult, error) {
+	results, err := db.GetCheckHistory(targetID, 1)
+	if err != nil || len(results) == 0 {
+		return nil, err
+	}
+	return &results[0], nil
+}
+
+func (dbHistory) LastCertFingerprint(targetID int64) (string, error) {
+	return db.LastCertFingerprint(targetID)
+}
+
+func (dbHistory) LastRemoteIP(targetID int64) (string, error) {
+	return db.LastRemoteIP(targetID)
+}
+
+func (dbHistory) Targets() ([]db.Target, error) {
+	return db.ListTargets()
+}
+
+// env is what a check runs with when the caller supplies it through the
+// context instead of the package-level setters and the database.
+type env struct {
+	history  History
+	settings Settings
+}
+
+type envKey struct{}
+
+// WithEnv returns a context whose checks read history from h and use s in
+// place of the package-level settings. A nil h means no history: every
+// check is treated as the target's first.
+func WithEnv(ctx context.Context, h History, s Settings) context.Context {
+	if h == nil {
+		h = noHistory{}
+	}
+	return context.WithValue(ctx, envKey{}, &env{history: h, settings: s})
+}
+
+// envFrom returns the env carried by ctx, or one backed by the database
+// and the package-level settings.
+func envFrom(ctx context.Context) *env {
+	if e, ok := ctx.Value(envKey{}).(*env); ok {
+		return e
+	}
+	return &env{history: dbHistory{}, settings: DefaultSettings()}
+}
+
+// DefaultSettings returns the settings made with the package-level
+// setters, which checks use unless WithEnv supplies their own.
+func DefaultSettings() Settings {
+	return Settings{
+		SoftDownKeywords: softDownKeywords,
+		MaxBodyBytes:     maxBodyBytes,
+		MaxTotalTime:     defaultMaxTotalTime,
+		Headers:          defaultHeaders,
+		DataDir:          filepath.Dir(db.GetDBPath()),
+		RedirectStatus:   defaultRedirectStatus,
+		FailOnEmpty:      defaultFailOnEmpty,
+		FirstCheckStatus: defaultFirstCheckStatus,
+		SnapshotMode:     defaultSnapshotMode,
+		CAFile:           defaultCAFile,
+		ClientCert:       defaultClientCert,
+		ClientKey:        defaultClientKey,
+		ConfirmURL:       confirmURL,
+		ConfirmToken:     confirmToken,
+	}
+}
+
+// noHistory is a History with nothing stored.
+type noHistory struct{}
+
+func (noHistory) LatestSnapshot(int64) (*db.Snapshot, error) { return nil, nil }
+func (noHistory) LastResult(int64) (*db.CheckResult, error)  { return nil, nil }
+func (noHistory) LastCertFingerprint(int64) (string, error)  { return "", nil }
+func (noHistory) LastRemoteIP(int64) (string, error)         { return "", nil }
+func (noHistory) Targets() ([]db.Target, error)              { return nil, nil }
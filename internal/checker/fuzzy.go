@@ -0,0 +1,77 @@
+package checker
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"hash/fnv"
+	"strings"
+)
+
+// signatureSize is the number of MinHash values in a content signature.
+// With 64 the estimated change is within about 6 points of the real one.
+const signatureSize = 64
+
+// shingleWords is how many consecutive words make up one shingle.
+const shingleWords = 3
+
+// ContentSignature returns a fuzzy signature of content: a MinHash over
+// its lowercased three-word shingles, hex encoded. Two signatures estimate
+// how much of the content differs, so small edits stay small while the
+// exact hash changes completely. It returns "" for empty content.
+func ContentSignature(content string) string {
+	words := strings.Fields(strings.ToLower(content))
+	if len(words) == 0 {
+		return ""
+	}
+	var mins [signatureSize]uint32
+	for i := range mins {
+		mins[i] = ^uint32(0)
+	}
+	n := max(len(words)-shingleWords+1, 1)
+	for i := 0; i < n; i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:min(i+shingleWords, len(words))], " ")))
+		sum := h.Sum64()
+		for j := range mins {
+			if v := uint32(mix64(sum + uint64(j)*0x9e3779b97f4a7c15)); v < mins[j] {
+				mins[j] = v
+			}
+		}
+	}
+	buf := make([]byte, 4*signatureSize)
+	for i, v := range mins {
+		binary.BigEndian.PutUint32(buf[4*i:], v)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// mix64 is the splitmix64 finalizer, deriving independent hash functions
+// from one FNV hash.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// signatureChange estimates the percentage of content that differs between
+// two signatures. ok is false when either is missing or malformed.
+func signatureChange(a, b string) (percent float64, ok bool) {
+	if len(a) != 8*signatureSize || len(b) != 8*signatureSize {
+		return 0, false
+	}
+	x, errA := hex.DecodeString(a)
+	y, errB := hex.DecodeString(b)
+	if errA != nil || errB != nil {
+		return 0, false
+	}
+	differ := 0
+	for i := 0; i < len(x); i += 4 {
+		if binary.BigEndian.Uint32(x[i:]) != binary.BigEndian.Uint32(y[i:]) {
+			differ++
+		}
+	}
+	return 100 * float64(differ) / signatureSize, true
+}
@@ -0,0 +1,61 @@
+package checker
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// defaultHeaders is the global header set from config.
+var defaultHeaders map[string]string
+
+// SetDefaultHeaders installs headers sent with every http check unless the
+// target sets the same header itself.
+func SetDefaultHeaders(headers map[string]string) {
+	defaultHeaders = headers
+}
+
+// requestHeaders builds the headers of an http check from four layers, each
+// overriding the ones before it per header name (compared
+// case-insensitively):
+//
+//  1. built-in defaults (User-Agent, and Content-Type when there is a body)
+//  2. headers from config
+//  3. the target's own headers
+//  4. the target's Authorization header (set by --auth-basic/--auth-bearer)
+func requestHeaders(target *db.Target, defaults map[string]string) http.Header {
+	h := http.Header{}
+	h.Set("User-Agent", "upp/1.0")
+	if target.Body != "" {
+		h.Set("Content-Type", "application/json")
+	}
+	setHeaders(h, defaults, false)
+
+	if target.Headers != "" {
+		var custom map[string]string
+		if err := json.Unmarshal([]byte(target.Headers), &custom); err == nil {
+			setHeaders(h, custom, false)
+			setHeaders(h, custom, true)
+		}
+	}
+	return h
+}
+
+// setHeaders applies one layer. Keys are visited in sorted order so that a
+// layer naming the same header twice in different case resolves the same
+// way on every check. Authorization is applied only when auth is set, which
+// lets it form its own, final layer.
+func setHeaders(h http.Header, layer map[string]string, auth bool) {
+	keys := make([]string, 0, len(layer))
+	for k := range layer {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if (http.CanonicalHeaderKey(k) == "Authorization") == auth {
+			h.Set(k, layer[k])
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package checker
+
+import (
+	"testing"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+func TestRequestHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   db.Target
+		defaults map[string]string
+		want     map[string]string
+	}{
+		{
+			name:     "global header alone",
+			defaults: map[string]string{"Accept": "text/html"},
+			want:     map[string]string{"Accept": "text/html", "User-Agent": "upp/1.0"},
+		},
+		{
+			name:     "target overrides global",
+			target:   db.Target{Headers: `{"Accept":"application/json"}`},
+			defaults: map[string]string{"Accept": "text/html"},
+			want:     map[string]string{"Accept": "application/json"},
+		},
+		{
+			name:     "names differing only in case",
+			target:   db.Target{Headers: `{"accept":"application/json"}`},
+			defaults: map[string]string{"ACCEPT": "text/html"},
+			want:     map[string]string{"Accept": "application/json"},
+		},
+		{
+			name:     "global overrides built-in user agent",
+			defaults: map[string]string{"user-agent": "probe/2"},
+			want:     map[string]string{"User-Agent": "probe/2"},
+		},
+		{
+			name:     "target authorization is applied last",
+			target:   db.Target{Headers: `{"Authorization":"Bearer t","X-Env":"prod"}`},
+			defaults: map[string]string{"authorization": "Basic g", "X-Env": "dev"},
+			want:     map[string]string{"Authorization": "Bearer t", "X-Env": "prod"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := requestHeaders(&tt.target, tt.defaults)
+			for k, v := range tt.want {
+				if got := h.Values(k); len(got) != 1 || got[0] != v {
+					t.Errorf("%s = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package checker
+
+import (
+	"context"
+	"net"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// addrIP returns the IP of a connection's remote address, without the port.
+func addrIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// checkIPChange marks the result when an alert_on_ip_change target
+// connected to a different IP than the last check that connected, which
+// usually means a failover or a DNS change.
+func checkIPChange(ctx context.Context, target *db.Target, result *Result) {
+	if !target.AlertOnIPChange || result.RemoteIP == "" {
+		return
+	}
+	if prev, err := envFrom(ctx).history.LastRemoteIP(target.ID); err == nil && prev != "" && prev != result.RemoteIP {
+		result.IPChanged = true
+		result.PrevRemoteIP = prev
+	}
+}
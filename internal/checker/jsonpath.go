@@ -0,0 +1,240 @@
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A JSONPath picks values out of a JSON document with a path such as
+// $.data.status, $.items[0].name, $['odd key'] or $.items[*].id. It covers
+// plucking fields for those who don't write jq; filters and recursive
+// descent are left to jq.
+type JSONPath struct {
+	expr  string
+	steps []pathStep
+}
+
+// pathStep is one step of a path: a field name, an array index (negative
+// counts from the end) or a wildcard over every element or field.
+type pathStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func (s pathStep) String() string {
+	switch {
+	case s.wildcard:
+		return "[*]"
+	case s.isIndex:
+		return fmt.Sprintf("[%d]", s.index)
+	}
+	if isPlainKey(s.key) {
+		return "." + s.key
+	}
+	return "[" + strconv.Quote(s.key) + "]"
+}
+
+func isPlainKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if r != '_' && r != '-' && !('a' <= r && r <= 'z') && !('A' <= r && r <= 'Z') && !('0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseJSONPath parses a path. The leading $ may be left out.
+func ParseJSONPath(expr string) (*JSONPath, error) {
+	p := &JSONPath{expr: expr}
+	rest := strings.TrimSpace(expr)
+	rest = strings.TrimPrefix(rest, "$")
+	if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			if strings.HasPrefix(rest, ".") {
+				return nil, fmt.Errorf("invalid JSON path %q: recursive descent (..) is not supported; use --jq", expr)
+			}
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			rest = rest[end:]
+			switch key {
+			case "":
+				return nil, fmt.Errorf("invalid JSON path %q: empty field name", expr)
+			case "*":
+				p.steps = append(p.steps, pathStep{wildcard: true})
+			default:
+				p.steps = append(p.steps, pathStep{key: key})
+			}
+		case '[':
+			end := bracketEnd(rest)
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: unclosed [", expr)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			step, err := parseBracket(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSON path %q: %w", expr, err)
+			}
+			p.steps = append(p.steps, step)
+		default:
+			return nil, fmt.Errorf("invalid JSON path %q: unexpected %q", expr, rest[0])
+		}
+	}
+	return p, nil
+}
+
+// bracketEnd returns the index of the ] closing the bracket s starts with,
+// skipping over quoted keys, or -1.
+func bracketEnd(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func parseBracket(inner string) (pathStep, error) {
+	switch {
+	case inner == "*":
+		return pathStep{wildcard: true}, nil
+	case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+		key := inner[1 : len(inner)-1]
+		key = strings.NewReplacer(`\'`, `'`, `\"`, `"`, `\\`, `\`).Replace(key)
+		return pathStep{key: key}, nil
+	}
+	n, err := strconv.Atoi(inner)
+	if err != nil {
+		return pathStep{}, fmt.Errorf("[%s] is neither an index, a quoted key nor *", inner)
+	}
+	return pathStep{index: n, isIndex: true}, nil
+}
+
+// Eval returns the values the path selects from a decoded JSON document.
+// A field or index that isn't there is an error naming where the path
+// stopped; a wildcard over an empty array or object selects nothing.
+func (p *JSONPath) Eval(doc any) ([]any, error) {
+	type match struct {
+		v    any
+		path string
+	}
+	cur := []match{{doc, "$"}}
+	for _, step := range p.steps {
+		var next []match
+		for _, m := range cur {
+			switch {
+			case step.wildcard:
+				switch v := m.v.(type) {
+				case []any:
+					for i, e := range v {
+						next = append(next, match{e, fmt.Sprintf("%s[%d]", m.path, i)})
+					}
+				case map[string]any:
+					// In key order, so the content hashes the same every check
+					keys := make([]string, 0, len(v))
+					for k := range v {
+						keys = append(keys, k)
+					}
+					slices.Sort(keys)
+					for _, k := range keys {
+						next = append(next, match{v[k], m.path + pathStep{key: k}.String()})
+					}
+				default:
+					return nil, fmt.Errorf("%s is %s, not an array or object", m.path, jsonKind(m.v))
+				}
+			case step.isIndex:
+				arr, ok := m.v.([]any)
+				if !ok {
+					return nil, fmt.Errorf("%s is %s, not an array", m.path, jsonKind(m.v))
+				}
+				i := step.index
+				if i < 0 {
+					i += len(arr)
+				}
+				if i < 0 || i >= len(arr) {
+					return nil, fmt.Errorf("no index %d in %s (%d elements)", step.index, m.path, len(arr))
+				}
+				next = append(next, match{arr[i], m.path + step.String()})
+			default:
+				obj, ok := m.v.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("%s is %s, not an object", m.path, jsonKind(m.v))
+				}
+				v, ok := obj[step.key]
+				if !ok {
+					return nil, fmt.Errorf("no field %q in %s", step.key, m.path)
+				}
+				next = append(next, match{v, m.path + step.String()})
+			}
+		}
+		cur = next
+	}
+	values := make([]any, len(cur))
+	for i, m := range cur {
+		values[i] = m.v
+	}
+	return values, nil
+}
+
+// isJSONType reports whether a Content-Type is JSON: application/json,
+// text/json or a +json type such as application/problem+json. A response
+// without one is taken to be JSON, since it is parsed anyway.
+func isJSONType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mt := mediaType(contentType)
+	return mt == "application/json" || mt == "text/json" || strings.HasSuffix(mt, "+json")
+}
+
+// jsonText is how a selected JSON value becomes content: strings as they
+// are, anything else as indented JSON.
+func jsonText(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.MarshalIndent(v, "", "  ")
+	return string(b)
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case float64:
+		return "a number"
+	case string:
+		return "a string"
+	case []any:
+		return "an array"
+	}
+	return "an object"
+}
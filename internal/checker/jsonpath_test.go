@@ -0,0 +1,124 @@
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+func TestJSONPathEval(t *testing.T) {
+	const doc = `{
+		"status": "ok",
+		"data": {"version": 3, "odd key": true},
+		"items": [{"id": "a", "tags": ["x"]}, {"id": "b", "tags": []}],
+		"empty": [],
+		"counts": {"b": 2, "a": 1}
+	}`
+	tests := []struct {
+		path    string
+		want    []string
+		wantErr string // substring of the error, "" for none
+	}{
+		{path: "$.status", want: []string{"ok"}},
+		{path: "status", want: []string{"ok"}},
+		{path: "$.data.version", want: []string{"3"}},
+		{path: "$['data']['odd key']", want: []string{"true"}},
+		{path: `$.data["odd key"]`, want: []string{"true"}},
+		{path: "$.items[0].id", want: []string{"a"}},
+		{path: "$.items[-1].id", want: []string{"b"}},
+		{path: "$.items[*].id", want: []string{"a", "b"}},
+		{path: "$.items.*.id", want: []string{"a", "b"}},
+		{path: "$.items[*].tags[*]", want: []string{"x"}},
+		{path: "$.counts[*]", want: []string{"1", "2"}},
+		{path: "$.empty[*]", want: []string{}},
+		{path: "$.missing", wantErr: `no field "missing" in $`},
+		{path: "$.data.missing", wantErr: `no field "missing" in $.data`},
+		{path: "$.items[2]", wantErr: "no index 2 in $.items (2 elements)"},
+		{path: "$.items[-3]", wantErr: "no index -3 in $.items"},
+		{path: "$.status[0]", wantErr: "$.status is a string, not an array"},
+		{path: "$.status.x", wantErr: "$.status is a string, not an object"},
+		{path: "$.data.version[*]", wantErr: "$.data.version is a number, not an array or object"},
+	}
+	var parsed any
+	if err := json.Unmarshal([]byte(doc), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			p, err := ParseJSONPath(tt.path)
+			if err != nil {
+				t.Fatalf("ParseJSONPath(%q): %v", tt.path, err)
+			}
+			values, err := p.Eval(parsed)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Eval(%q) error = %v, want %q", tt.path, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Eval(%q): %v", tt.path, err)
+			}
+			got := make([]string, len(values))
+			for i, v := range values {
+				got[i] = strings.Join(strings.Fields(jsonText(v)), "")
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Eval(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONPathErrors(t *testing.T) {
+	for _, path := range []string{"$..id", "$.items[0", "$.a.", "$[abc]", "$.a[]"} {
+		if _, err := ParseJSONPath(path); err == nil {
+			t.Errorf("ParseJSONPath(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestJSONPathCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  string
+		wantContent string
+		wantErr     string
+	}{
+		{name: "json", contentType: "application/json; charset=utf-8", body: `{"status":"ok"}`, wantStatus: "baseline", wantContent: "ok"},
+		{name: "json suffix", contentType: "application/health+json", body: `{"status":"ok"}`, wantStatus: "baseline", wantContent: "ok"},
+		{name: "html error page", contentType: "text/html", body: "<html>Bad gateway</html>", wantStatus: "error", wantErr: "json path needs a JSON response, got text/html"},
+		{name: "json content type, invalid body", contentType: "application/json", body: "not json", wantStatus: "error", wantErr: "response is not valid JSON"},
+		{name: "missing field", contentType: "application/json", body: `{"state":"ok"}`, wantStatus: "error", wantErr: `no field "status" in $`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+
+			ctx := WithEnv(context.Background(), nil, Settings{})
+			r := Check(ctx, &db.Target{Name: tt.name, URL: srv.URL, Type: "http", Timeout: 5, JSONPath: "$.status"})
+			if r.Status != tt.wantStatus {
+				t.Errorf("status = %q (%s), want %q", r.Status, r.Error, tt.wantStatus)
+			}
+			if tt.wantContent != "" && r.Content != tt.wantContent {
+				t.Errorf("content = %q, want %q", r.Content, tt.wantContent)
+			}
+			if tt.wantErr != "" && !strings.Contains(r.Error, tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", r.Error, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// How a redirect is reported, set per target (redirect_status) or as the
+// configured default.
+const (
+	RedirectUp       = "up"       // a redirect counts as a plain success
+	RedirectWarn     = "warn"     // up, with a warning naming the redirect
+	RedirectReported = "redirect" // the distinct status "redirect"
+)
+
+// ValidateRedirectStatus checks a redirect_status value. Empty means the
+// configured default.
+func ValidateRedirectStatus(s string) error {
+	switch s {
+	case "", RedirectUp, RedirectWarn, RedirectReported:
+		return nil
+	}
+	return fmt.Errorf("unknown redirect status %q (want up, warn or redirect)", s)
+}
+
+// defaultRedirectStatus is the configured redirect handling.
+var defaultRedirectStatus string
+
+// SetDefaultRedirectStatus sets how redirects are reported for targets
+// that don't choose themselves. An unknown value counts as up.
+func SetDefaultRedirectStatus(s string) {
+	defaultRedirectStatus = s
+}
+
+// redirectMode returns how the target's redirects are reported: its own
+// redirect_status, or the configured one.
+func redirectMode(ctx context.Context, target *db.Target) string {
+	if target.RedirectStatus != "" {
+		return target.RedirectStatus
+	}
+	return envFrom(ctx).settings.RedirectStatus
+}
+
+// redirectHop is the first redirect an http check went through.
+type redirectHop struct {
+	code     int
+	location string
+}
+
+// newRedirectHop records resp as a redirect, or returns nil when it isn't
+// one.
+func newRedirectHop(resp *http.Response) *redirectHop {
+	if resp == nil || resp.StatusCode < 300 || resp.StatusCode > 399 {
+		return nil
+	}
+	hop := &redirectHop{code: resp.StatusCode}
+	if loc, err := resp.Location(); err == nil {
+		hop.location = loc.String()
+	}
+	return hop
+}
+
+func (h *redirectHop) String() string {
+	if h.location == "" {
+		return fmt.Sprintf("redirected: HTTP %d", h.code)
+	}
+	return fmt.Sprintf("redirected: HTTP %d → %s", h.code, h.location)
+}
+
+// applyRedirectStatus reports hop on a successful result as the mode asks:
+// as a warning, or as the status "redirect". Only an up or unchanged
+// result becomes "redirect"; a changed or baseline one keeps its status so
+// the change is still stored and notified, and carries the hop as a
+// warning. Other warnings already on the result are kept.
+func applyRedirectStatus(result *Result, hop *redirectHop, mode string) {
+	if hop == nil {
+		return
+	}
+	switch mode {
+	case RedirectWarn:
+		addWarning(result, hop.String())
+	case RedirectReported:
+		if result.Status != "up" && result.Status != "unchanged" {
+			addWarning(result, hop.String())
+			return
+		}
+		result.Status = "redirect"
+		if result.Error == "" {
+			result.Error = hop.String()
+		} else {
+			result.Error += "; " + hop.String()
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// snapshotHistory is a History holding one stored snapshot.
+type snapshotHistory struct {
+	noHistory
+	snap *db.Snapshot
+}
+
+func (h snapshotHistory) LatestSnapshot(int64) (*db.Snapshot, error) { return h.snap, nil }
+
+func TestRedirectStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "<html><body><p>price: 10</p></body></html>")
+	}))
+	defer srv.Close()
+
+	target := func(mode, selector string) *db.Target {
+		return &db.Target{ID: 1, Name: "redirect", URL: srv.URL + "/old", Type: "http", Timeout: 5, RedirectStatus: mode, Selector: selector}
+	}
+	// stored is a history holding what a first check of selector saw.
+	stored := func(selector string) History {
+		first := Check(WithEnv(context.Background(), nil, Settings{}), target(RedirectReported, selector))
+		if first.ContentHash == "" {
+			t.Fatalf("first check stored no content hash (%s: %s)", first.Status, first.Error)
+		}
+		return snapshotHistory{snap: &db.Snapshot{Content: first.Content, Hash: first.ContentHash}}
+	}
+	same, sameSelected := stored(""), stored("p, #nope")
+	edited := snapshotHistory{snap: &db.Snapshot{Content: "price: 12", Hash: "edited"}}
+
+	tests := []struct {
+		name       string
+		history    History
+		target     *db.Target
+		wantStatus string
+		wantErr    []string // substrings of the result's error
+	}{
+		{name: "first check keeps baseline", target: target(RedirectReported, ""), wantStatus: "baseline", wantErr: []string{"⚠ redirected: HTTP 302"}},
+		{name: "unchanged becomes redirect", history: same, target: target(RedirectReported, ""), wantStatus: "redirect", wantErr: []string{"redirected: HTTP 302"}},
+		{name: "changed page stays changed", history: edited, target: target(RedirectReported, ""), wantStatus: "changed", wantErr: []string{"⚠ redirected: HTTP 302"}},
+		{name: "warn keeps other warnings", history: sameSelected, target: target(RedirectWarn, "p, #nope"), wantStatus: "unchanged", wantErr: []string{`selector "#nope" matched nothing`, "redirected: HTTP 302"}},
+		{name: "redirect keeps other warnings", history: sameSelected, target: target(RedirectReported, "p, #nope"), wantStatus: "redirect", wantErr: []string{`selector "#nope" matched nothing`, "redirected: HTTP 302"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Check(WithEnv(context.Background(), tt.history, Settings{}), tt.target)
+			if r.Status != tt.wantStatus {
+				t.Errorf("status = %q (%s), want %q", r.Status, r.Error, tt.wantStatus)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(r.Error, want) {
+					t.Errorf("error = %q, want it to contain %q", r.Error, want)
+				}
+			}
+		})
+	}
+}
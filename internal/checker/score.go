@@ -0,0 +1,103 @@
+package checker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ScoreRule is one keyword of a target's content score: a required keyword
+// adds its weight when present and takes it away when missing, a
+// forbidden one takes its weight away when present, and a bonus one adds
+// its weight when present.
+type ScoreRule struct {
+	Kind    string // required, forbidden or bonus
+	Weight  int
+	Keyword string
+}
+
+// ScoreHit is what one rule contributed to a score.
+type ScoreHit struct {
+	Rule   ScoreRule
+	Found  bool
+	Points int
+}
+
+// ParseScoreRule parses "kind:keyword" or "kind:weight:keyword", e.g.
+// "required:3:database ok". The weight defaults to 1; a keyword that
+// starts with a number and a colon needs the weight spelled out.
+func ParseScoreRule(s string) (ScoreRule, error) {
+	kind, rest, ok := strings.Cut(s, ":")
+	if !ok {
+		return ScoreRule{}, fmt.Errorf("invalid score rule %q: expected 'kind:keyword' or 'kind:weight:keyword'", s)
+	}
+	switch kind {
+	case "required", "forbidden", "bonus":
+	default:
+		return ScoreRule{}, fmt.Errorf("unknown score rule kind %q (valid: required, forbidden, bonus)", kind)
+	}
+	rule := ScoreRule{Kind: kind, Weight: 1, Keyword: rest}
+	if w, keyword, ok := strings.Cut(rest, ":"); ok {
+		if n, err := strconv.Atoi(w); err == nil {
+			if n <= 0 {
+				return ScoreRule{}, fmt.Errorf("score rule weight must be above 0, got %d", n)
+			}
+			rule.Weight, rule.Keyword = n, keyword
+		}
+	}
+	if strings.TrimSpace(rule.Keyword) == "" {
+		return ScoreRule{}, fmt.Errorf("score rule keyword cannot be empty")
+	}
+	return rule, nil
+}
+
+// String returns the rule in the form ParseScoreRule reads.
+func (r ScoreRule) String() string {
+	return fmt.Sprintf("%s:%d:%s", r.Kind, r.Weight, r.Keyword)
+}
+
+// ScoreContent scores content against a target's score rules, matching
+// keywords case-insensitively. Rules that don't parse are skipped; they
+// are validated when the target is saved.
+func ScoreContent(rules []string, content string) (int, []ScoreHit) {
+	lower := strings.ToLower(content)
+	score := 0
+	var hits []ScoreHit
+	for _, s := range rules {
+		rule, err := ParseScoreRule(s)
+		if err != nil {
+			continue
+		}
+		hit := ScoreHit{Rule: rule, Found: strings.Contains(lower, strings.ToLower(rule.Keyword))}
+		switch {
+		case rule.Kind == "required" && !hit.Found, rule.Kind == "forbidden" && hit.Found:
+			hit.Points = -rule.Weight
+		case rule.Kind != "forbidden" && hit.Found:
+			hit.Points = rule.Weight
+		}
+		score += hit.Points
+		hits = append(hits, hit)
+	}
+	return score, hits
+}
+
+// scorePenalties describes the hits that took points away, for the error
+// of a check whose score is too low. Without any, it names the bonus
+// keywords that weren't found.
+func scorePenalties(hits []ScoreHit) string {
+	var parts, missedBonus []string
+	for _, h := range hits {
+		switch {
+		case h.Points < 0 && h.Found:
+			parts = append(parts, fmt.Sprintf("has %q (%d)", h.Rule.Keyword, h.Points))
+		case h.Points < 0:
+			parts = append(parts, fmt.Sprintf("missing %q (%d)", h.Rule.Keyword, h.Points))
+		case h.Rule.Kind == "bonus" && !h.Found:
+			missedBonus = append(missedBonus, fmt.Sprintf("%q", h.Rule.Keyword))
+		}
+	}
+	if len(parts) == 0 && len(missedBonus) > 0 {
+		return "no bonus " + strings.Join(missedBonus, ", ")
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,66 @@
+package checker
+
+import "testing"
+
+func TestParseScoreRule(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ScoreRule
+		wantErr bool
+	}{
+		{in: "required:database ok", want: ScoreRule{Kind: "required", Weight: 1, Keyword: "database ok"}},
+		{in: "forbidden:5:degraded", want: ScoreRule{Kind: "forbidden", Weight: 5, Keyword: "degraded"}},
+		{in: "bonus:cache warm", want: ScoreRule{Kind: "bonus", Weight: 1, Keyword: "cache warm"}},
+		{in: "required:1:10:30 backup", want: ScoreRule{Kind: "required", Weight: 1, Keyword: "10:30 backup"}},
+		{in: "required:status: ok", want: ScoreRule{Kind: "required", Weight: 1, Keyword: "status: ok"}},
+		{in: "required", wantErr: true},
+		{in: "optional:ok", wantErr: true},
+		{in: "required:0:ok", wantErr: true},
+		{in: "required:-2:ok", wantErr: true},
+		{in: "bonus:3:", wantErr: true},
+		{in: "bonus: ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseScoreRule(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseScoreRule(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseScoreRule(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreContent(t *testing.T) {
+	rules := []string{"required:3:database ok", "forbidden:5:degraded", "bonus:2:cache warm"}
+	tests := []struct {
+		name    string
+		rules   []string
+		content string
+		want    int
+	}{
+		{name: "all good", rules: rules, content: "Database OK, cache warm", want: 5},
+		{name: "required missing", rules: rules, content: "cache warm", want: -1},
+		{name: "forbidden present", rules: rules, content: "database ok but degraded", want: -2},
+		{name: "nothing matches", rules: rules, content: "", want: -3},
+		{name: "invalid rule skipped", rules: []string{"optional:ok", "bonus:ok"}, content: "ok", want: 1},
+		{name: "no rules", content: "anything", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, hits := ScoreContent(tt.rules, tt.content)
+			if got != tt.want {
+				t.Errorf("ScoreContent score = %d, want %d (hits %+v)", got, tt.want, hits)
+			}
+			sum := 0
+			for _, h := range hits {
+				sum += h.Points
+			}
+			if sum != got {
+				t.Errorf("hit points add up to %d, score is %d", sum, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package checker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+)
+
+// Selector types, set per target (selector_type).
+const (
+	SelectorCSS   = "css"   // a goquery CSS selector (the default)
+	SelectorXPath = "xpath" // an XPath 1.0 expression
+)
+
+// ValidateSelector checks a selector and its type before it is saved.
+// Empty typ means CSS. Each selector of a comma-separated list must be
+// valid on its own.
+func ValidateSelector(typ, expr string) error {
+	switch typ {
+	case "", SelectorCSS:
+		return nil
+	case SelectorXPath:
+		for _, part := range SplitSelectors(expr) {
+			if _, err := xpath.Compile(part); err != nil {
+				return fmt.Errorf("invalid XPath %q: %w", part, err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown selector type %q (want css or xpath)", typ)
+}
+
+// SplitSelectors splits a comma-separated selector list into its
+// selectors. Commas inside brackets, parentheses or quotes don't split, so
+// attribute values, :is() groups and XPath function arguments stay whole.
+func SplitSelectors(expr string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote rune
+	for i, c := range expr {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case c == ',' && depth == 0:
+			if part := strings.TrimSpace(expr[start:i]); part != "" {
+				parts = append(parts, part)
+			}
+			start = i + 1
+		}
+	}
+	if part := strings.TrimSpace(expr[start:]); part != "" {
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// SelectText returns the text of each part of an HTML document the
+// selector picks, with scripts and styles left out. An XPath expression
+// may also evaluate to a string, number or boolean, returned as one text.
+//
+// A comma-separated list of selectors is applied one selector at a time,
+// and the texts come back in list order: everything the first selector
+// picks, in document order, then everything the second picks, and so on,
+// wherever the regions sit in the page. missed lists the selectors that
+// picked nothing.
+func SelectText(typ, expr, content string) (selected, missed []string, err error) {
+	var sel func(part string) ([]string, error)
+	if typ == SelectorXPath {
+		doc, err := htmlquery.Parse(strings.NewReader(content))
+		if err != nil {
+			return nil, nil, err
+		}
+		sel = func(part string) ([]string, error) { return selectXPath(part, doc) }
+	} else {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+		if err != nil {
+			return nil, nil, err
+		}
+		sel = func(part string) ([]string, error) {
+			var texts []string
+			doc.Find(part).Each(func(i int, s *goquery.Selection) {
+				texts = append(texts, strings.TrimSpace(selectionText(s)))
+			})
+			return texts, nil
+		}
+	}
+	for _, part := range SplitSelectors(expr) {
+		texts, err := sel(part)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(texts) == 0 {
+			missed = append(missed, part)
+		}
+		selected = append(selected, texts...)
+	}
+	return selected, missed, nil
+}
+
+func selectXPath(expr string, doc *html.Node) ([]string, error) {
+	compiled, err := xpath.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid XPath %q: %w", expr, err)
+	}
+	switch v := compiled.Evaluate(htmlquery.CreateXPathNavigator(doc)).(type) {
+	case *xpath.NodeIterator:
+		var selected []string
+		for v.MoveNext() {
+			nav := v.Current().(*htmlquery.NodeNavigator)
+			if nav.NodeType() == xpath.AttributeNode {
+				selected = append(selected, strings.TrimSpace(nav.Value()))
+			} else {
+				selected = append(selected, strings.TrimSpace(nodeText(nav.Current())))
+			}
+		}
+		return selected, nil
+	case string:
+		return []string{v}, nil
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	}
+	return nil, nil
+}
+
+// selectionText is the text of a CSS selection. Scripts and styles inside
+// it are left out so CSS/JS doesn't pollute extracted text, unless the
+// selector picked the script or style itself.
+func selectionText(s *goquery.Selection) string {
+	var sb strings.Builder
+	for _, n := range s.Nodes {
+		if n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style") {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				sb.WriteString(nodeText(c))
+			}
+			continue
+		}
+		sb.WriteString(nodeText(n))
+	}
+	return sb.String()
+}
+
+// nodeText is the text of a node without its scripts and styles.
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			sb.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if n.Data == "script" || n.Data == "style" {
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
@@ -0,0 +1,31 @@
+package checker
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitSelectors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{in: "div.price", want: []string{"div.price"}},
+		{in: "h1, .price ,#stock", want: []string{"h1", ".price", "#stock"}},
+		{in: `a[title="x, y"], b`, want: []string{`a[title="x, y"]`, "b"}},
+		{in: "a[title='x, y'], b", want: []string{"a[title='x, y']", "b"}},
+		{in: ":is(h1, h2) span, p", want: []string{":is(h1, h2) span", "p"}},
+		{in: "//div[contains(@class, 'price')], //h1", want: []string{"//div[contains(@class, 'price')]", "//h1"}},
+		{in: `concat("a", 'b, c')`, want: []string{`concat("a", 'b, c')`}},
+		{in: "h1,, ,p,", want: []string{"h1", "p"}},
+		{in: " ", want: nil},
+		{in: "", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := SplitSelectors(tt.in); !slices.Equal(got, tt.want) {
+				t.Errorf("SplitSelectors(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
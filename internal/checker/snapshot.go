@@ -0,0 +1,71 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// When a check's content is stored as a snapshot, set per target
+// (snapshot_mode) or as the configured default.
+const (
+	SnapshotAlways   = "always"    // every check with content
+	SnapshotOnChange = "on_change" // only content that changed (the default)
+	SnapshotNever    = "never"     // no content; changes are found by hash alone
+)
+
+// ValidateSnapshotMode checks a snapshot_mode value. Empty means the
+// configured default.
+func ValidateSnapshotMode(s string) error {
+	switch s {
+	case "", SnapshotAlways, SnapshotOnChange, SnapshotNever:
+		return nil
+	}
+	return fmt.Errorf("unknown snapshot mode %q (want always, on_change or never)", s)
+}
+
+// defaultSnapshotMode is the configured snapshot mode.
+var defaultSnapshotMode string
+
+// SetDefaultSnapshotMode sets when snapshots are stored for targets that
+// don't choose themselves. An unknown value counts as on_change.
+func SetDefaultSnapshotMode(s string) {
+	defaultSnapshotMode = s
+}
+
+// SnapshotMode returns the target's effective snapshot mode, falling back
+// to the one in s.
+func SnapshotMode(target *db.Target, s Settings) string {
+	mode := target.SnapshotMode
+	if mode == "" {
+		mode = s.SnapshotMode
+	}
+	switch mode {
+	case SnapshotAlways, SnapshotNever:
+		return mode
+	}
+	return SnapshotOnChange
+}
+
+// WantSnapshot reports whether r's content should be stored, given the
+// target's latest snapshot (nil for none) and the settings it was checked
+// with. Outside never mode the first content is always stored, so later
+// checks have a baseline.
+func WantSnapshot(target *db.Target, r *Result, latest *db.Snapshot, s Settings) bool {
+	if r.Content == "" || r.ContentHash == "" {
+		return false
+	}
+	switch SnapshotMode(target, s) {
+	case SnapshotNever:
+		return false
+	case SnapshotAlways:
+		return true
+	}
+	if latest == nil {
+		return true
+	}
+	// Content a change_threshold target judged unchanged is not stored, so
+	// small edits add up against the last real change instead of moving
+	// the baseline along with them.
+	return r.Status != "unchanged" && latest.Hash != r.ContentHash
+}
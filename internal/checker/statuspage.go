@@ -0,0 +1,60 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// statuspageFilter picks the indicator and description of a Statuspage
+// status JSON, one per line, as the content a check hashes.
+const statuspageFilter = `.status.indicator, .status.description`
+
+// StatuspageURL returns the status JSON of a Statuspage page: the URL as
+// given, or /api/v2/status.json when it is the page's root.
+func StatuspageURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Path != "" && u.Path != "/") {
+		return raw
+	}
+	u.Path = "/api/v2/status.json"
+	return u.String()
+}
+
+// checkStatuspage reports a provider's declared status from its
+// Statuspage (statuspage.io format) status JSON, fetched like any http
+// target. The overall indicator maps to a status: none is up, minor and
+// maintenance are up with a warning, major and critical are down. A change
+// of indicator is a content change, so it notifies as "changed" too.
+func checkStatuspage(ctx context.Context, target *db.Target) *Result {
+	probe := *target
+	probe.URL = StatuspageURL(target.URL)
+	probe.JQFilter = statuspageFilter
+	probe.JSONPath = ""
+	probe.Selector = ""
+	probe.HashHeaders = nil
+	result := checkHTTP(ctx, &probe)
+	if result.Status == "down" || result.Status == "error" {
+		return result
+	}
+
+	indicator, description, _ := strings.Cut(result.Content, "\n")
+	switch indicator {
+	case "none":
+	case "minor", "maintenance":
+		addWarning(result, fmt.Sprintf("%s: %s", indicator, description))
+	case "major", "critical":
+		result.Status = "down"
+		result.Error = fmt.Sprintf("%s: %s", indicator, description)
+	case "null":
+		result.Status = "error"
+		result.Error = "no status.indicator in the response; is this a Statuspage status JSON?"
+	default:
+		result.Status = "error"
+		result.Error = fmt.Sprintf("unknown status indicator %q", indicator)
+	}
+	return result
+}
@@ -0,0 +1,73 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+)
+
+// DefaultStreamReadBytes caps a stream-mode read when the target sets no
+// read_bytes.
+const DefaultStreamReadBytes = 64 << 10
+
+// streamReadWindow is how long a stream-mode check keeps reading after the
+// response headers arrived.
+const streamReadWindow = 2 * time.Second
+
+// readStream reads the start of a response body that may never end, as
+// served by SSE and long-poll endpoints. It stops at EOF, once limit bytes
+// arrived, once expect (when set) appears, or when window runs out; none of
+// these is an error. Only a failed read or a cancelled ctx is. The caller
+// closes body, which also ends the background read.
+func readStream(ctx context.Context, body io.Reader, limit int64, expect string, window time.Duration) ([]byte, error) {
+	type chunk struct {
+		data []byte
+		err  error
+	}
+	chunks := make(chan chunk)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := body.Read(buf)
+			c := chunk{data: append([]byte(nil), buf[:n]...), err: err}
+			select {
+			case chunks <- c:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	timer := time.NewTimer(window)
+	defer timer.Stop()
+
+	var data []byte
+	for {
+		select {
+		case c := <-chunks:
+			data = append(data, c.data...)
+			if int64(len(data)) >= limit {
+				return data[:limit], nil
+			}
+			if expect != "" && bytes.Contains(data, []byte(expect)) {
+				return data, nil
+			}
+			if c.err == io.EOF {
+				return data, nil
+			}
+			if c.err != nil {
+				return data, c.err
+			}
+		case <-timer.C:
+			return data, nil
+		case <-ctx.Done():
+			return data, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package checker
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// DefaultTCPPingPort is the port a tcp-ping target connects to when it
+// doesn't set one.
+const DefaultTCPPingPort = 443
+
+// TCPPingPort returns the port the target's tcp-ping connects to.
+func TCPPingPort(target *db.Target) int {
+	if target.Port > 0 {
+		return target.Port
+	}
+	return DefaultTCPPingPort
+}
+
+// checkTCPPing measures latency to a host as the time a TCP handshake takes,
+// for networks where ICMP is blocked. The name is resolved first, so the
+// response time is the connect alone, like a ping round trip. Every check
+// opens a fresh connection and closes it as soon as it is established.
+func checkTCPPing(ctx context.Context, target *db.Target) *Result {
+	start := time.Now()
+	result := &Result{}
+
+	timeout := time.Duration(target.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ip := target.URL
+	if net.ParseIP(ip) == nil {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, target.URL)
+		if err != nil {
+			result.Status = "down"
+			result.Error = err.Error()
+			result.ResponseTime = time.Since(start)
+			return result
+		}
+		ip = addrs[0]
+	}
+	result.Timing.DNS = time.Since(start)
+
+	dialer := net.Dialer{}
+	dial := dialWithConnectTimeout(dialer.DialContext)
+	connectStart := time.Now()
+	conn, err := dial(withConnectTimeout(ctx, time.Duration(target.ConnectTimeout)*time.Second),
+		"tcp", net.JoinHostPort(ip, strconv.Itoa(TCPPingPort(target))))
+	result.Timing.Connect = time.Since(connectStart)
+	result.ResponseTime = result.Timing.Connect
+	if err != nil {
+		result.Status = "down"
+		result.Error = err.Error()
+		return result
+	}
+	conn.Close()
+
+	result.RemoteIP = ip
+	checkIPChange(ctx, target, result)
+	result.Status = "up"
+	return result
+}
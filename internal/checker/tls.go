@@ -0,0 +1,178 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// TLSInfo describes a TLS connection: what was negotiated and whether the
+// presented chain verifies against the system roots and any CA file.
+type TLSInfo struct {
+	Version    string     `json:"version"`
+	Cipher     string     `json:"cipher"`
+	ChainValid bool       `json:"chain_valid"`
+	ChainError string     `json:"chain_error,omitempty"`
+	Chain      []CertInfo `json:"chain"`
+}
+
+// CertInfo summarizes one certificate of a presented chain, leaf first.
+type CertInfo struct {
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	DNSNames    []string  `json:"dns_names,omitempty"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+	Fingerprint string    `json:"sha256"`
+}
+
+var tlsVersions = []struct {
+	id   uint16
+	name string
+}{
+	{tls.VersionTLS10, "1.0"},
+	{tls.VersionTLS11, "1.1"},
+	{tls.VersionTLS12, "1.2"},
+	{tls.VersionTLS13, "1.3"},
+}
+
+// TLSVersionName renders a negotiated version as "TLS 1.2".
+func TLSVersionName(v uint16) string {
+	for _, tv := range tlsVersions {
+		if tv.id == v {
+			return "TLS " + tv.name
+		}
+	}
+	return fmt.Sprintf("0x%04x", v)
+}
+
+// ParseTLSVersion accepts "1.2", "TLS1.2" or "tls 1.2" and returns the
+// version id together with its canonical "1.2" spelling.
+func ParseTLSVersion(s string) (uint16, string, error) {
+	v := strings.TrimSpace(strings.ToLower(s))
+	v = strings.TrimSpace(strings.TrimPrefix(v, "tls"))
+	v = strings.TrimPrefix(v, "v")
+	for _, tv := range tlsVersions {
+		if tv.name == v {
+			return tv.id, tv.name, nil
+		}
+	}
+	return 0, "", fmt.Errorf("unknown TLS version %q (want 1.0, 1.1, 1.2 or 1.3)", s)
+}
+
+// tlsInfo builds a TLSInfo from a finished handshake. When verification was
+// skipped (insecure targets) the chain is verified here against roots (nil
+// for the system roots) so the result still says whether it would have
+// passed.
+func tlsInfo(cs *tls.ConnectionState, host string, roots *x509.CertPool) *TLSInfo {
+	info := &TLSInfo{
+		Version: TLSVersionName(cs.Version),
+		Cipher:  tls.CipherSuiteName(cs.CipherSuite),
+	}
+	for _, c := range cs.PeerCertificates {
+		info.Chain = append(info.Chain, CertInfo{
+			Subject:     c.Subject.String(),
+			Issuer:      c.Issuer.String(),
+			DNSNames:    c.DNSNames,
+			NotBefore:   c.NotBefore,
+			NotAfter:    c.NotAfter,
+			Fingerprint: certFingerprint(c),
+		})
+	}
+
+	if len(cs.VerifiedChains) > 0 {
+		info.ChainValid = true
+		return info
+	}
+	if len(cs.PeerCertificates) == 0 {
+		info.ChainError = "no certificates presented"
+		return info
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		info.ChainError = err.Error()
+	} else {
+		info.ChainValid = true
+	}
+	return info
+}
+
+// checkMinTLS returns an error when the negotiated version is below the
+// target's expect_min_tls.
+func checkMinTLS(target *db.Target, cs *tls.ConnectionState) error {
+	if target.ExpectMinTLS == "" {
+		return nil
+	}
+	min, name, err := ParseTLSVersion(target.ExpectMinTLS)
+	if err != nil {
+		return err
+	}
+	if cs == nil {
+		return fmt.Errorf("expected TLS %s or newer, but the connection is not encrypted", name)
+	}
+	if cs.Version < min {
+		return fmt.Errorf("%s negotiated, below the required TLS %s", TLSVersionName(cs.Version), name)
+	}
+	return nil
+}
+
+// InspectTLS opens a TLS connection to an http target's host and reports
+// the negotiated parameters and the full presented chain. Verification is
+// done separately so an invalid chain is still described.
+func InspectTLS(ctx context.Context, target *db.Target) (*TLSInfo, error) {
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("%s is not an https URL", target.URL)
+	}
+	roots, err := loadCAPool(caFile(ctx, target))
+	if err != nil {
+		return nil, err
+	}
+	certs, err := clientCertificates(clientCert(ctx, target))
+	if err != nil {
+		return nil, err
+	}
+	host := u.Hostname()
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+
+	timeout := time.Duration(target.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	d := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: time.Duration(target.ConnectTimeout) * time.Second},
+		Config:    &tls.Config{ServerName: host, InsecureSkipVerify: true, Certificates: certs},
+	}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	cs := conn.(*tls.Conn).ConnectionState()
+	return tlsInfo(&cs, host, roots), nil
+}
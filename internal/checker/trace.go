@@ -0,0 +1,120 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timing breaks an http check's response time down by phase. DNS, Connect
+// and TLS are summed over redirect hops and stay zero when a pooled
+// connection was reused; FirstByte is measured from the start of the check
+// to the first byte of the final response. DNSCached marks a DNS phase
+// answered by the DNS cache, which has no time to measure.
+type Timing struct {
+	DNS       time.Duration
+	DNSCached bool
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration
+}
+
+// dnsCachedKey carries the callback the DNS cache calls on a hit.
+type dnsCachedKey struct{}
+
+// timingTrace collects a Timing from httptrace callbacks. The callbacks can
+// fire from the transport's dial goroutines, hence the mutex.
+type timingTrace struct {
+	mu    sync.Mutex
+	start time.Time
+	t     Timing
+
+	dnsStart, connectStart, tlsStart time.Time
+	ip                               string // remote IP of the last connection used
+}
+
+func newTimingTrace(start time.Time) *timingTrace {
+	return &timingTrace{start: start}
+}
+
+// withTrace returns ctx with the trace's callbacks attached.
+func (tt *timingTrace) withTrace(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, dnsCachedKey{}, func() {
+		tt.mu.Lock()
+		tt.t.DNSCached = true
+		tt.mu.Unlock()
+	})
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			tt.mu.Lock()
+			tt.dnsStart = time.Now()
+			tt.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			tt.mu.Lock()
+			if !tt.dnsStart.IsZero() {
+				tt.t.DNS += time.Since(tt.dnsStart)
+				tt.dnsStart = time.Time{}
+			}
+			tt.mu.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			tt.mu.Lock()
+			if tt.connectStart.IsZero() {
+				tt.connectStart = time.Now()
+			}
+			tt.mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			// With several addresses (happy eyeballs) only the first
+			// successful dial counts, timed from the first attempt.
+			tt.mu.Lock()
+			if err == nil && !tt.connectStart.IsZero() {
+				tt.t.Connect += time.Since(tt.connectStart)
+				tt.connectStart = time.Time{}
+			}
+			tt.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			tt.mu.Lock()
+			tt.tlsStart = time.Now()
+			tt.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			tt.mu.Lock()
+			if !tt.tlsStart.IsZero() {
+				tt.t.TLS += time.Since(tt.tlsStart)
+				tt.tlsStart = time.Time{}
+			}
+			tt.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			// Pooled connections too, so every check records an IP
+			tt.mu.Lock()
+			tt.ip = addrIP(info.Conn.RemoteAddr())
+			tt.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			tt.mu.Lock()
+			tt.t.FirstByte = time.Since(tt.start)
+			tt.mu.Unlock()
+		},
+	})
+}
+
+// timing returns what has been collected so far.
+func (tt *timingTrace) timing() Timing {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	return tt.t
+}
+
+// remoteIP returns the IP of the connection the final request went over,
+// or "" if it never got one.
+func (tt *timingTrace) remoteIP() string {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	return tt.ip
+}
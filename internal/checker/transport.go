@@ -0,0 +1,157 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TransportOptions tune the HTTP transports shared by all http checks.
+// Zero values fall back to the defaults below.
+type TransportOptions struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	DisableKeepAlives   bool
+	DNSCacheTTL         time.Duration // 0 resolves hosts on every new connection
+}
+
+const (
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 4
+	defaultIdleConnTimeout     = 90 * time.Second
+)
+
+// transportKey identifies the per-target settings that have to live on the
+// transport itself. Targets sharing a key share pooled connections.
+type transportKey struct {
+	insecure   bool
+	caFile     string // PEM bundle trusted on top of the system roots
+	clientCert string // PEM certificate presented for mutual TLS
+	clientKey  string // its private key
+	socket     string // unix socket path for http+unix targets
+	proxy      string // proxy URL, credentials included
+}
+
+var (
+	transportMu   sync.Mutex
+	transportOpts TransportOptions
+	transports    = make(map[transportKey]*http.Transport)
+	dnsCacheInst  *dnsCache
+)
+
+// SetTransportOptions replaces the shared transport settings. Existing
+// transports are dropped (after closing their idle connections) so the next
+// check picks up the new limits.
+func SetTransportOptions(o TransportOptions) {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	for k, t := range transports {
+		t.CloseIdleConnections()
+		delete(transports, k)
+	}
+	transportOpts = o
+	dnsCacheInst = nil
+	if o.DNSCacheTTL > 0 {
+		dnsCacheInst = newDNSCache(o.DNSCacheTTL)
+	}
+}
+
+// sharedTransport returns the pooled transport for a target's TLS settings,
+// creating it on first use. Reusing it keeps connections and TLS sessions
+// alive between checks of the same host. CA and client certificate files are
+// read when their transport is created, so replaced files are picked up on
+// restart.
+func sharedTransport(key transportKey) (*http.Transport, error) {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	if t, ok := transports[key]; ok {
+		return t, nil
+	}
+	roots, err := loadCAPool(key.caFile)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := clientCertificates(key.clientCert, key.clientKey)
+	if err != nil {
+		return nil, err
+	}
+
+	o := transportOpts
+	if o.MaxIdleConns <= 0 {
+		o.MaxIdleConns = defaultMaxIdleConns
+	}
+	if o.MaxIdleConnsPerHost <= 0 {
+		o.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+	if o.IdleConnTimeout <= 0 {
+		o.IdleConnTimeout = defaultIdleConnTimeout
+	}
+
+	t := &http.Transport{
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: key.insecure, RootCAs: roots, Certificates: certs},
+		MaxIdleConns:        o.MaxIdleConns,
+		MaxIdleConnsPerHost: o.MaxIdleConnsPerHost,
+		IdleConnTimeout:     o.IdleConnTimeout,
+		DisableKeepAlives:   o.DisableKeepAlives,
+	}
+	if key.proxy != "" {
+		if err := setProxy(t, key.proxy); err != nil {
+			return nil, err
+		}
+	}
+	var d net.Dialer
+	dial := d.DialContext
+	if key.socket != "" {
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, "unix", key.socket)
+		}
+	} else if dnsCacheInst != nil {
+		dial = dnsCacheInst.dialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+	}
+	t.DialContext = dialWithConnectTimeout(dial)
+	transports[key] = t
+	return t, nil
+}
+
+// connectTimeoutKey carries a target's connect_timeout to the dialer of the
+// shared transport, which can't hold per-target settings.
+type connectTimeoutKey struct{}
+
+// withConnectTimeout returns ctx carrying the time allowed to establish a
+// connection. Zero leaves connecting bounded only by ctx.
+func withConnectTimeout(ctx context.Context, d time.Duration) context.Context {
+	if d <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, connectTimeoutKey{}, d)
+}
+
+// dialWithConnectTimeout bounds each dial by the connect timeout in its
+// context, if any, so an unreachable host fails fast while a slow response
+// still gets the whole timeout.
+func dialWithConnectTimeout(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		d, ok := ctx.Value(connectTimeoutKey{}).(time.Duration)
+		if !ok {
+			return dial(ctx, network, addr)
+		}
+		deadline := time.Now().Add(d)
+		if parent, ok := ctx.Deadline(); ok && !parent.After(deadline) {
+			return dial(ctx, network, addr) // the check times out first anyway
+		}
+		dialCtx, cancel := context.WithDeadline(ctx, deadline)
+		defer cancel()
+		conn, err := dial(dialCtx, network, addr)
+		var ne net.Error
+		if err != nil && ctx.Err() == nil && errors.As(err, &ne) && ne.Timeout() {
+			return nil, fmt.Errorf("connect timeout (%s): %w", d, err)
+		}
+		return conn, err
+	}
+}
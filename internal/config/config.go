@@ -4,6 +4,8 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -12,25 +14,139 @@ type Config struct {
 	Defaults   Defaults          `yaml:"defaults"`
 	Display    Display           `yaml:"display"`
 	Thresholds Thresholds        `yaml:"thresholds"`
-	Headers    map[string]string `yaml:"headers,omitempty"`
+	Headers    map[string]string `yaml:"headers,omitempty"` // sent with every http check; per-target headers win
+	Storage    Storage           `yaml:"storage,omitempty"`
+	HTTP       HTTP              `yaml:"http,omitempty"`
+	Log        Log               `yaml:"log,omitempty"`
+
+	// Escalations are named alerting policies targets opt into with
+	// --escalation. While a target stays down, each step notifies its
+	// channels once the incident has lasted the step's delay.
+	Escalations map[string][]EscalationStep `yaml:"escalations,omitempty"`
+
+	// SoftDownKeywords mark a 2xx HTML response as down when its body
+	// contains any of them (case-insensitive), catching error pages served
+	// with a success status. Targets can override the list.
+	SoftDownKeywords []string `yaml:"soft_down_keywords,omitempty"`
+
+	// NotifyOnStartup sends one summary of all targets when the daemon
+	// starts or 'upp check' runs over every target, instead of alerting
+	// again for each target that was already down.
+	NotifyOnStartup bool `yaml:"notify_on_startup,omitempty"`
+
+	// Flapping detects targets that keep going down and up again: such a
+	// target alerts once that it is flapping instead of on every change.
+	Flapping Flapping `yaml:"flapping,omitempty"`
+
+	// ResponseTimeRegression tunes the response_time_regression trigger,
+	// which alerts when a check is much slower than the target's recent
+	// median.
+	ResponseTimeRegression ResponseTimeRegression `yaml:"response_time_regression,omitempty"`
+
+	// ConfirmURL is another upp instance running 'upp confirm-serve'. A
+	// target that fails here is checked there too, and only alerts when it
+	// fails there as well.
+	ConfirmURL   string `yaml:"confirm_url,omitempty"`
+	ConfirmToken string `yaml:"confirm_token,omitempty"` // bearer token for confirm_url; also what confirm-serve requires
+
+	// Concurrency caps how many checks the daemon runs at once, in total
+	// and against any one host, so a degraded host can't take every slot.
+	Concurrency Concurrency `yaml:"concurrency,omitempty"`
+
+	// Daemon tunes the daemon's scheduling: how recent a stored result must
+	// be for a restarted daemon to skip checking the target again.
+	Daemon Daemon `yaml:"daemon,omitempty"`
+}
+
+// Concurrency limits the daemon's checks in flight. Zero means no limit.
+type Concurrency struct {
+	MaxChecks  int `yaml:"max_checks,omitempty"`   // checks running at once across all targets
+	MaxPerHost int `yaml:"max_per_host,omitempty"` // checks running at once against one host
+}
+
+// Daemon tunes how the daemon schedules checks.
+type Daemon struct {
+	DedupSlack int `yaml:"dedup_slack,omitempty"` // seconds short of its interval a stored result still counts as current at startup (default: 10; negative turns the guard off)
+}
+
+// Flapping configures flap detection, which is off while Window is 0.
+type Flapping struct {
+	Window    int `yaml:"window,omitempty"`    // number of recent checks looked at
+	Threshold int `yaml:"threshold,omitempty"` // status changes within the window that mean flapping (default: half the window)
+}
+
+// ResponseTimeRegression sets how slow is a regression: more than Multiplier
+// times the median response time of the Window checks before.
+type ResponseTimeRegression struct {
+	Multiplier float64 `yaml:"multiplier,omitempty"` // default: 3; a target's rule can set its own
+	Window     int     `yaml:"window,omitempty"`     // recent checks the median is taken over (default: 20)
+}
+
+// EscalationStep is one tier of an escalation policy.
+type EscalationStep struct {
+	After  string   `yaml:"after"`  // how long the target must have been down, e.g. 0, 15m, 1h
+	Notify []string `yaml:"notify"` // notification channel names, as shown by 'upp notify list'
 }
 
 type Defaults struct {
-	Interval    int    `yaml:"interval"`       // default check interval in seconds
-	Type        string `yaml:"type"`           // default check type
-	Timeout     int    `yaml:"timeout"`        // HTTP timeout in seconds
-	RetryCount  int    `yaml:"retry_count"`    // retries before marking down
-	UserAgent   string `yaml:"user_agent"`
+	Interval         int    `yaml:"interval"`    // default check interval in seconds
+	Type             string `yaml:"type"`        // default check type
+	Timeout          int    `yaml:"timeout"`     // HTTP timeout in seconds
+	RetryCount       int    `yaml:"retry_count"` // extra attempts before marking down
+	UserAgent        string `yaml:"user_agent"`
+	MaxTotalTime     int    `yaml:"max_total_time,omitempty"`     // ceiling in seconds on one check across all retries; 0 for none
+	RedirectStatus   string `yaml:"redirect_status,omitempty"`    // how a 3xx is reported for targets without their own: up (default), warn, redirect
+	SnapshotMode     string `yaml:"snapshot_mode,omitempty"`      // when content is stored for targets without their own: always, on_change (default), never
+	FailOnEmpty      bool   `yaml:"fail_on_empty,omitempty"`      // every http target is down on an empty or whitespace-only response
+	FirstCheckStatus string `yaml:"first_check_status,omitempty"` // status of the first content check, which stores the baseline: baseline (default) or up
+	CAFile           string `yaml:"ca_file,omitempty"`            // PEM CA bundle trusted on top of the system roots by targets without their own
+	ClientCert       string `yaml:"client_cert,omitempty"`        // PEM client certificate for mutual TLS, for targets without their own
+	ClientKey        string `yaml:"client_key,omitempty"`         // private key of client_cert; empty when the certificate file holds it
+	CheckOnAdd       bool   `yaml:"check_on_add,omitempty"`       // check a target once right after 'upp add', as --check does
 }
 
 type Display struct {
-	Color   bool   `yaml:"color"`
-	Format  string `yaml:"format"` // table, json, compact
-	Verbose bool   `yaml:"verbose"`
+	Color        bool              `yaml:"color"`
+	Format       string            `yaml:"format"` // table, json, compact
+	Verbose      bool              `yaml:"verbose"`
+	ErrorWidth   int               `yaml:"error_width,omitempty"`   // max characters of an error shown inline (default: 40)
+	PreviewBytes int               `yaml:"preview_bytes,omitempty"` // bytes of content, diffs and notification errors shown before cutting (default: 4096)
+	Timezone     string            `yaml:"timezone,omitempty"`      // local (default), utc, or an IANA name like Europe/Berlin
+	TimeFormat   string            `yaml:"time_format,omitempty"`   // Go layout or rfc3339, rfc1123, datetime, kitchen; empty keeps each command's own
+	RelativeTime bool              `yaml:"relative_time,omitempty"` // add "2m ago" after absolute timestamps in view
+	Theme        string            `yaml:"theme,omitempty"`         // default, high-contrast, colorblind-safe or monochrome
+	Symbols      map[string]string `yaml:"symbols,omitempty"`       // per-status symbol overrides, e.g. down: "!"
+	Pager        string            `yaml:"pager,omitempty"`         // pager for long output of history, search, diff and replay (default: $PAGER, else less); off disables
 }
 
 type Thresholds struct {
-	SSLWarnDays int `yaml:"ssl_warn_days"` // show SSL expiry warning when days left < this (default: 30)
+	SSLWarnDays int     `yaml:"ssl_warn_days"`          // show SSL expiry warning when days left < this (default: 30)
+	StaleFactor float64 `yaml:"stale_factor,omitempty"` // a target unchecked for this many intervals is stale (default: 3; negative turns it off)
+}
+
+type Storage struct {
+	DSN               string `yaml:"dsn,omitempty"`                // Postgres connection string; empty uses the local sqlite file
+	Path              string `yaml:"path,omitempty"`               // sqlite database file; empty uses ~/.upp/upp.db
+	CompressSnapshots bool   `yaml:"compress_snapshots,omitempty"` // gzip new snapshot content; older rows still read either way
+	RetentionDays     int    `yaml:"retention_days,omitempty"`     // days of check history kept by 'upp prune' and the daemon; 0 keeps everything
+}
+
+// HTTP tunes connection reuse for http checks. Zero values use built-in
+// defaults (100 idle connections, 4 per host, 90s idle timeout, no DNS
+// cache, 10 MiB bodies).
+type HTTP struct {
+	MaxIdleConns        int   `yaml:"max_idle_conns,omitempty"`
+	MaxIdleConnsPerHost int   `yaml:"max_idle_conns_per_host,omitempty"`
+	IdleConnTimeout     int   `yaml:"idle_conn_timeout,omitempty"` // seconds
+	DisableKeepAlives   bool  `yaml:"disable_keep_alives,omitempty"`
+	DNSCacheTTL         int   `yaml:"dns_cache_ttl,omitempty"`  // seconds to reuse a resolved address; 0 disables the cache
+	MaxBodyBytes        int64 `yaml:"max_body_bytes,omitempty"` // largest response body a check reads (default: 10 MiB)
+}
+
+// Log controls diagnostic logging to stderr.
+type Log struct {
+	Level  string `yaml:"level,omitempty"`  // debug, info, warn, error (default: info)
+	Format string `yaml:"format,omitempty"` // text or json (default: text)
 }
 
 var current *Config
@@ -41,7 +157,7 @@ func Default() *Config {
 			Interval:   300,
 			Type:       "http",
 			Timeout:    30,
-			RetryCount: 1,
+			RetryCount: 0,
 			UserAgent:  "upp/1.0",
 		},
 		Display: Display{
@@ -135,6 +251,90 @@ func (c *Config) SSLWarnDays() int {
 	return c.Thresholds.SSLWarnDays
 }
 
+// StaleFactor returns how many intervals may pass without a check before a
+// target counts as stale, defaulting to 3. Zero means staleness is off.
+func (c *Config) StaleFactor() float64 {
+	switch f := c.Thresholds.StaleFactor; {
+	case f < 0:
+		return 0
+	case f == 0:
+		return 3
+	default:
+		return f
+	}
+}
+
+// DedupSlack returns how far short of a target's interval its last stored
+// result may be for the daemon to skip its first check, defaulting to 10
+// seconds. A negative result means the guard is off.
+func (c *Config) DedupSlack() time.Duration {
+	switch s := c.Daemon.DedupSlack; {
+	case s < 0:
+		return -1
+	case s == 0:
+		return 10 * time.Second
+	default:
+		return time.Duration(s) * time.Second
+	}
+}
+
+// ErrorWidth returns how many characters of an error to show inline in
+// list and status, defaulting to 40.
+func (c *Config) ErrorWidth() int {
+	if c.Display.ErrorWidth <= 0 {
+		return 40
+	}
+	return c.Display.ErrorWidth
+}
+
+// PreviewBytes returns how much content, diff or error text is shown
+// before it is cut short, defaulting to 4 KiB.
+func (c *Config) PreviewBytes() int {
+	if c.Display.PreviewBytes <= 0 {
+		return 4 << 10
+	}
+	return c.Display.PreviewBytes
+}
+
+// Location returns the zone timestamps are shown in. An unknown zone falls
+// back to UTC.
+func (c *Config) Location() *time.Location {
+	switch strings.ToLower(c.Display.Timezone) {
+	case "", "local":
+		return time.Local
+	case "utc":
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(c.Display.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// TimeLayout returns the Go layout for display.time_format, or fallback
+// when none is set. A format without any date or time element falls back
+// to RFC3339.
+func (c *Config) TimeLayout(fallback string) string {
+	switch strings.ToLower(c.Display.TimeFormat) {
+	case "":
+		return fallback
+	case "rfc3339":
+		return time.RFC3339
+	case "rfc1123":
+		return time.RFC1123
+	case "datetime":
+		return time.DateTime
+	case "kitchen":
+		return time.Kitchen
+	}
+	ref := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	if ref.Format(c.Display.TimeFormat) == c.Display.TimeFormat {
+		return time.RFC3339
+	}
+	return c.Display.TimeFormat
+}
+
 func Get() *Config {
 	if current == nil {
 		return Load()
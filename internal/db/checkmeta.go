@@ -0,0 +1,117 @@
+package db
+
+import "encoding/json"
+
+// CheckMeta holds the extras of a check result that don't need columns of
+// their own: values that are shown but never queried on, keyed by name.
+// New captures go here, so they need no migration; fields that are queried
+// or aggregated (status, status code, response time) stay columns.
+// Read and write it through the typed accessors on CheckResult, or
+// Get and Set.
+type CheckMeta map[string]json.RawMessage
+
+// Keys of the values stored in CheckMeta.
+const (
+	MetaProtocol  = "protocol"            // negotiated HTTP protocol, e.g. "HTTP/2.0"
+	MetaScore     = "score"               // content score of a target with score rules
+	MetaDNSCached = "dns_cached"          // the DNS cache answered, so dns_ms wasn't measured
+	MetaOverlaps  = "skipped_overlapping" // daemon checks skipped since the previous result because one was still running
+)
+
+// Get decodes the value stored under key into v. It reports false when
+// there is none, or it doesn't decode into v.
+func (m CheckMeta) Get(key string, v any) bool {
+	raw, ok := m[key]
+	return ok && json.Unmarshal(raw, v) == nil
+}
+
+// Set stores v under key, allocating the map on first use.
+func (m *CheckMeta) Set(key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if *m == nil {
+		*m = make(CheckMeta)
+	}
+	(*m)[key] = raw
+	return nil
+}
+
+// Protocol returns the HTTP protocol the check negotiated, or "".
+func (r *CheckResult) Protocol() string {
+	var s string
+	r.Meta.Get(MetaProtocol, &s)
+	return s
+}
+
+// SetProtocol records the HTTP protocol the check negotiated; "" records
+// nothing.
+func (r *CheckResult) SetProtocol(proto string) {
+	if proto != "" {
+		r.Meta.Set(MetaProtocol, proto)
+	}
+}
+
+// Score returns the content score of the check, and whether it has one.
+func (r *CheckResult) Score() (int, bool) {
+	var n int
+	ok := r.Meta.Get(MetaScore, &n)
+	return n, ok
+}
+
+// SetScore records the content score of the check.
+func (r *CheckResult) SetScore(score int) {
+	r.Meta.Set(MetaScore, score)
+}
+
+// DNSCached reports whether the check's host address came from the DNS
+// cache, leaving no DNS time to measure.
+func (r *CheckResult) DNSCached() bool {
+	var b bool
+	r.Meta.Get(MetaDNSCached, &b)
+	return b
+}
+
+// SetDNSCached records that the DNS cache answered the check's lookup.
+func (r *CheckResult) SetDNSCached() {
+	r.Meta.Set(MetaDNSCached, true)
+}
+
+// SkippedOverlapping returns how many due checks the daemon skipped since
+// the previous result because a check of the target was still running.
+func (r *CheckResult) SkippedOverlapping() int {
+	var n int
+	r.Meta.Get(MetaOverlaps, &n)
+	return n
+}
+
+// SetSkippedOverlapping records n skipped overlapping checks; 0 records
+// nothing.
+func (r *CheckResult) SetSkippedOverlapping(n int) {
+	if n > 0 {
+		r.Meta.Set(MetaOverlaps, n)
+	}
+}
+
+// encodeCheckMeta stores a result's meta as a JSON object, or "" when empty.
+func encodeCheckMeta(m CheckMeta) string {
+	if len(m) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(m)
+	return string(b)
+}
+
+// decodeCheckMeta reverses encodeCheckMeta. A value that isn't a JSON
+// object decodes to no meta rather than failing the read.
+func decodeCheckMeta(s string) CheckMeta {
+	if s == "" {
+		return nil
+	}
+	var m CheckMeta
+	if json.Unmarshal([]byte(s), &m) != nil {
+		return nil
+	}
+	return m
+}
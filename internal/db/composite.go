@@ -0,0 +1,49 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CompositePrefix starts the URL of a composite target, followed by the
+// comma-separated ids of its members: "composite:3,5,7".
+const CompositePrefix = "composite:"
+
+// CompositeURL builds a composite target URL from member ids.
+func CompositeURL(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return CompositePrefix + strings.Join(parts, ",")
+}
+
+// CompositeMembers returns the member ids of a composite target URL.
+func CompositeMembers(rawURL string) ([]int64, error) {
+	list, ok := strings.CutPrefix(rawURL, CompositePrefix)
+	if !ok {
+		return nil, fmt.Errorf("composite targets take %s<id>,<id>,... (got %q)", CompositePrefix, rawURL)
+	}
+	seen := make(map[int64]bool)
+	var ids []int64
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid composite member %q (want a target id)", part)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("composite member %d is listed twice", id)
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("composite target has no members")
+	}
+	return ids, nil
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// compressSnapshots controls whether new snapshots are gzipped before being
+// stored. Existing rows keep their own compressed flag, so toggling it never
+// affects reading.
+var compressSnapshots bool
+
+// SetSnapshotCompression turns gzip compression of newly stored snapshot
+// content on or off.
+func SetSnapshotCompression(on bool) {
+	compressSnapshots = on
+}
+
+// compressSnapshot gzips content when compression is enabled. ok is false
+// when compression is off or would not make the content smaller, in which
+// case the content is stored as-is.
+func compressSnapshot(content string) (gz []byte, ok bool) {
+	if !compressSnapshots || content == "" {
+		return nil, false
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(w, content); err != nil {
+		return nil, false
+	}
+	if err := w.Close(); err != nil {
+		return nil, false
+	}
+	if buf.Len() >= len(content) {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+func decompressSnapshot(gz []byte) (string, error) {
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
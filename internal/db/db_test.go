@@ -0,0 +1,304 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testStores opens every backend the Store contract is checked against:
+// sqlite always, and Postgres when UPP_TEST_POSTGRES_DSN names a database
+// the tests may write to.
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	stores := make(map[string]Store)
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "upp.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	stores["sqlite"] = s
+	if dsn := os.Getenv("UPP_TEST_POSTGRES_DSN"); dsn != "" {
+		s, err := OpenPostgres(dsn)
+		if err != nil {
+			t.Fatalf("open postgres: %v", err)
+		}
+		stores["postgres"] = s
+	}
+	t.Cleanup(func() {
+		for _, s := range stores {
+			s.Close()
+		}
+	})
+	return stores
+}
+
+// forEachStore runs fn against every backend as a subtest.
+func forEachStore(t *testing.T, fn func(t *testing.T, s Store)) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) { fn(t, s) })
+	}
+}
+
+// uniqueName keeps targets of one run apart from those of earlier runs on
+// a shared Postgres database.
+func uniqueName(t *testing.T) string {
+	return fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+}
+
+func TestStoreTargetRoundTrip(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		name := uniqueName(t)
+		opts := AddTargetOpts{
+			TriggerRule:          `{"type":"contains","value":"sale"}`,
+			HashHeaders:          []string{"ETag", "Last-Modified"},
+			SoftDownKeywords:     []string{"sorry, we're closed", "maintenance"},
+			Meta:                 map[string]string{"team": "web"},
+			ScoreRules:           []string{"required:2:ok"},
+			ExpectAbsent:         "maintenance",
+			Proxy:                "http://proxy:8080",
+			AlertOnRegression:    true,
+			RegressionMultiplier: 4,
+		}
+		added, err := s.AddTarget(name, "https://example.com/"+name, "http", 60, "#main", `{"Accept":"text/html"}`, "Welcome", 10, 2, 5, opts)
+		if err != nil {
+			t.Fatalf("AddTarget: %v", err)
+		}
+		got, err := s.GetTarget(name)
+		if err != nil {
+			t.Fatalf("GetTarget: %v", err)
+		}
+		if got.ID != added.ID || got.URL != added.URL || got.Selector != "#main" || got.Headers != `{"Accept":"text/html"}` ||
+			got.Expect != "Welcome" || got.Timeout != 10 || got.Retries != 2 || got.TriggerRule != opts.TriggerRule ||
+			len(got.HashHeaders) != 2 || got.Meta["team"] != "web" || len(got.ScoreRules) != 1 ||
+			got.ExpectAbsent != "maintenance" || got.Proxy != "http://proxy:8080" ||
+			!got.AlertOnRegression || got.RegressionMultiplier != 4 ||
+			!slices.Equal(got.SoftDownKeywords, opts.SoftDownKeywords) {
+			t.Errorf("GetTarget after AddTarget = %+v", got)
+		}
+
+		got.Name = name + "-renamed"
+		got.Interval = 300
+		got.Meta = map[string]string{"team": "ops"}
+		got.HashHeaders = nil
+		got.Proxy = ""
+		if err := s.UpdateTarget(got); err != nil {
+			t.Fatalf("UpdateTarget: %v", err)
+		}
+		updated, err := s.GetTarget(fmt.Sprint(added.ID))
+		if err != nil {
+			t.Fatalf("GetTarget by id: %v", err)
+		}
+		if updated.Name != name+"-renamed" || updated.Interval != 300 || updated.Meta["team"] != "ops" ||
+			len(updated.HashHeaders) != 0 || updated.Proxy != "" || updated.ExpectAbsent != "maintenance" {
+			t.Errorf("GetTarget after UpdateTarget = %+v", updated)
+		}
+
+		if err := s.UpdateTarget(&Target{ID: -1, Name: "missing"}); err == nil {
+			t.Error("UpdateTarget of a missing target succeeded")
+		}
+	})
+}
+
+func TestStoreLatestErrors(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		name := uniqueName(t)
+		add := func(suffix string) *Target {
+			t.Helper()
+			target, err := s.AddTarget(name+suffix, "https://example.com/"+name+suffix, "http", 60, "", "", "", 10, 0, 5, AddTargetOpts{})
+			if err != nil {
+				t.Fatalf("AddTarget: %v", err)
+			}
+			return target
+		}
+		save := func(id int64, status, msg string) {
+			t.Helper()
+			if err := s.SaveCheckResult(&CheckResult{TargetID: id, Status: status, Error: msg}); err != nil {
+				t.Fatalf("SaveCheckResult: %v", err)
+			}
+		}
+		failing, recovered, healthy := add("-failing"), add("-recovered"), add("-healthy")
+		save(failing.ID, "up", "")
+		save(failing.ID, "down", "HTTP 503")
+		save(recovered.ID, "error", "timeout")
+		save(recovered.ID, "up", "")
+		save(healthy.ID, "up", "")
+
+		errs, err := s.GetLatestErrors([]int64{failing.ID, recovered.ID, healthy.ID})
+		if err != nil {
+			t.Fatalf("GetLatestErrors: %v", err)
+		}
+		if len(errs) != 1 || errs[failing.ID] != "HTTP 503" {
+			t.Errorf("GetLatestErrors = %v, want only %d: HTTP 503", errs, failing.ID)
+		}
+
+		// Only the ids asked for are looked at
+		errs, err = s.GetLatestErrors([]int64{healthy.ID})
+		if err != nil {
+			t.Fatalf("GetLatestErrors: %v", err)
+		}
+		if len(errs) != 0 {
+			t.Errorf("GetLatestErrors of a healthy target = %v, want none", errs)
+		}
+		if errs, err := s.GetLatestErrors(nil); err != nil || len(errs) != 0 {
+			t.Errorf("GetLatestErrors(nil) = %v, %v, want none", errs, err)
+		}
+	})
+}
+
+func TestStoreRecentHistory(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		name := uniqueName(t)
+		var ids []int64
+		for i, n := range []int{3, 1, 0} {
+			target, err := s.AddTarget(fmt.Sprintf("%s-%d", name, i), fmt.Sprintf("https://example.com/%s/%d", name, i), "http", 60, "", "", "", 10, 0, 5, AddTargetOpts{})
+			if err != nil {
+				t.Fatalf("AddTarget: %v", err)
+			}
+			ids = append(ids, target.ID)
+			for j := 0; j < n; j++ {
+				if err := s.SaveCheckResult(&CheckResult{TargetID: target.ID, Status: "up", ResponseTime: int64(j)}); err != nil {
+					t.Fatalf("SaveCheckResult: %v", err)
+				}
+			}
+		}
+
+		history, err := s.GetRecentHistory(ids, 2)
+		if err != nil {
+			t.Fatalf("GetRecentHistory: %v", err)
+		}
+		if got := history[ids[0]]; len(got) != 2 || got[0].ResponseTime != 2 || got[1].ResponseTime != 1 {
+			t.Errorf("history of the first target = %+v, want its 2 latest, newest first", got)
+		}
+		if got := history[ids[1]]; len(got) != 1 {
+			t.Errorf("history of the second target has %d results, want 1", len(got))
+		}
+		if got, ok := history[ids[2]]; ok {
+			t.Errorf("history of a target never checked = %+v, want none", got)
+		}
+	})
+}
+
+func TestStoreTargetStatsFor(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		name := uniqueName(t)
+		var ids []int64
+		for i := 0; i < 3; i++ {
+			target, err := s.AddTarget(fmt.Sprintf("%s-%d", name, i), fmt.Sprintf("https://example.com/%s/%d", name, i), "http", 60, "", "", "", 10, 0, 5, AddTargetOpts{})
+			if err != nil {
+				t.Fatalf("AddTarget: %v", err)
+			}
+			ids = append(ids, target.ID)
+		}
+		for _, st := range []string{"up", "down", "error", "up", "down"} {
+			if err := s.SaveCheckResult(&CheckResult{TargetID: ids[0], Status: st, ResponseTime: 10}); err != nil {
+				t.Fatalf("SaveCheckResult: %v", err)
+			}
+		}
+		if err := s.SaveCheckResult(&CheckResult{TargetID: ids[1], Status: "down"}); err != nil {
+			t.Fatalf("SaveCheckResult: %v", err)
+		}
+
+		since := time.Now().Add(-time.Hour)
+		st, err := s.GetTargetStatsFor(ids[0], since)
+		if err != nil {
+			t.Fatalf("GetTargetStatsFor: %v", err)
+		}
+		if st.TargetID != ids[0] || st.Checks != 5 || st.Up != 2 || st.Outages != 2 {
+			t.Errorf("stats of the first target = %+v, want 5 checks, 2 up, 2 outages", st)
+		}
+		if st, err := s.GetTargetStatsFor(ids[2], since); err != nil || st != (TargetStats{TargetID: ids[2]}) {
+			t.Errorf("stats of a target never checked = %+v, %v, want zero", st, err)
+		}
+	})
+}
+
+func TestStoreSnapshotBefore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		name := uniqueName(t)
+		target, err := s.AddTarget(name, "https://example.com/"+name, "http", 60, "", "", "", 10, 0, 5, AddTargetOpts{})
+		if err != nil {
+			t.Fatalf("AddTarget: %v", err)
+		}
+		for _, content := range []string{"first", "second"} {
+			if err := s.SaveSnapshot(target.ID, content, content, ""); err != nil {
+				t.Fatalf("SaveSnapshot: %v", err)
+			}
+		}
+
+		snap, err := s.GetSnapshotBefore(target.ID, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("GetSnapshotBefore: %v", err)
+		}
+		if snap == nil || snap.Content != "second" {
+			t.Errorf("GetSnapshotBefore after both = %+v, want the second", snap)
+		}
+		if snap, err := s.GetSnapshotBefore(target.ID, time.Now().Add(-time.Hour)); err != nil || snap != nil {
+			t.Errorf("GetSnapshotBefore before both = %+v, %v, want none", snap, err)
+		}
+	})
+}
+
+func TestStoreCheckLease(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		target, err := s.AddTarget(uniqueName(t), "https://example.com/"+uniqueName(t), "http", 60, "", "", "", 10, 0, 5, AddTargetOpts{})
+		if err != nil {
+			t.Fatalf("AddTarget: %v", err)
+		}
+		acquire := func(holder string, ttl time.Duration, want bool) {
+			t.Helper()
+			ok, err := s.AcquireCheckLease(target.ID, holder, ttl)
+			if err != nil {
+				t.Fatalf("AcquireCheckLease(%s): %v", holder, err)
+			}
+			if ok != want {
+				t.Errorf("AcquireCheckLease(%s, %s) = %v, want %v", holder, ttl, ok, want)
+			}
+		}
+		acquire("a", time.Minute, true)
+		acquire("b", time.Minute, false) // held by a
+		acquire("a", time.Minute, true)  // renewed by its holder
+		acquire("a", -time.Second, true) // a's lease now lies in the past
+		acquire("b", time.Minute, true)  // so b takes it over
+		acquire("a", time.Minute, false)
+	})
+}
+
+func TestStoreMigrations(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		statuses, err := s.Migrations()
+		if err != nil {
+			t.Fatalf("Migrations: %v", err)
+		}
+		if v := SchemaVersion(statuses); v != LatestSchemaVersion() {
+			t.Errorf("schema version after open = %d, want %d", v, LatestSchemaVersion())
+		}
+		if len(statuses) < len(migrations) {
+			t.Errorf("Migrations listed %d, want at least %d", len(statuses), len(migrations))
+		}
+		applied, err := s.Migrate()
+		if err != nil {
+			t.Fatalf("Migrate: %v", err)
+		}
+		if len(applied) != 0 {
+			t.Errorf("Migrate on an up-to-date store applied %v", applied)
+		}
+	})
+}
+
+func TestPostgresRebind(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT * FROM targets WHERE id = ?", "SELECT * FROM targets WHERE id = $1"},
+		{"UPDATE targets SET name=?, url=? WHERE id=?", "UPDATE targets SET name=$1, url=$2 WHERE id=$3"},
+		{"SELECT id FROM targets WHERE url LIKE '%?%' AND id = ?", "SELECT id FROM targets WHERE url LIKE '%?%' AND id = $1"},
+		{"SELECT 'it''s?' AS \"why?\", ?", "SELECT 'it''s?' AS \"why?\", $1"},
+	}
+	for _, tt := range tests {
+		if got := postgresRebind(tt.in); got != tt.want {
+			t.Errorf("postgresRebind(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// migration is one numbered step of the schema history. Each runs once
+// per database, in version order, inside a transaction that also records
+// its name in schema_migrations.
+type migration struct {
+	version  int
+	name     string
+	sqlite   func(db sqlConn) error
+	postgres func(db sqlConn) error
+}
+
+// migrations is the schema history. Released steps are never edited or
+// renumbered; a schema change is a new step appended at the end. The base
+// schema is frozen as of version 1, so new columns are added here (and not
+// to its CREATE TABLE statements), for both backends.
+var migrations = []migration{
+	{version: 1, name: "base_schema", sqlite: sqliteBaseSchema, postgres: postgresBaseSchema},
+	{version: 2, name: "retries_are_extra", sqlite: execAll(retriesAreExtra), postgres: execAll(retriesAreExtra)},
+	{version: 3, name: "add_targets_redirect_status",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN redirect_status TEXT DEFAULT ''"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS redirect_status TEXT NOT NULL DEFAULT ''")},
+	{version: 4, name: "add_targets_channels",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN channels TEXT DEFAULT ''"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS channels TEXT NOT NULL DEFAULT ''")},
+	{version: 5, name: "add_targets_severity",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN severity TEXT DEFAULT ''"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS severity TEXT NOT NULL DEFAULT ''")},
+	{version: 6, name: "add_targets_selector_type",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN selector_type TEXT DEFAULT ''"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS selector_type TEXT NOT NULL DEFAULT ''")},
+	{version: 7, name: "add_targets_json_path",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN json_path TEXT DEFAULT ''"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS json_path TEXT NOT NULL DEFAULT ''")},
+	{version: 8, name: "add_targets_alert_on_ip_change",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN alert_on_ip_change INTEGER DEFAULT 0"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS alert_on_ip_change BOOLEAN NOT NULL DEFAULT FALSE")},
+	{version: 9, name: "add_check_results_remote_ip",
+		sqlite:   execAll("ALTER TABLE check_results ADD COLUMN remote_ip TEXT DEFAULT ''"),
+		postgres: execAll("ALTER TABLE check_results ADD COLUMN IF NOT EXISTS remote_ip TEXT NOT NULL DEFAULT ''")},
+	{version: 10, name: "add_targets_connect_timeout",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN connect_timeout INTEGER DEFAULT 0"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS connect_timeout INTEGER NOT NULL DEFAULT 0")},
+	{version: 11, name: "add_targets_meta",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN meta TEXT DEFAULT ''"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS meta TEXT NOT NULL DEFAULT ''")},
+	{version: 12, name: "add_targets_snapshot_mode",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN snapshot_mode TEXT DEFAULT ''"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS snapshot_mode TEXT NOT NULL DEFAULT ''")},
+	{version: 13, name: "add_targets_strict_selector",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN strict_selector INTEGER DEFAULT 0"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS strict_selector BOOLEAN NOT NULL DEFAULT FALSE")},
+	{version: 14, name: "add_targets_expect_hash",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN expect_hash TEXT DEFAULT ''"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS expect_hash TEXT NOT NULL DEFAULT ''")},
+	{version: 15, name: "add_targets_fail_on_empty",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN fail_on_empty INTEGER DEFAULT 0"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS fail_on_empty BOOLEAN NOT NULL DEFAULT FALSE")},
+	{version: 16, name: "add_check_results_cert_expires_at",
+		sqlite:   execAll("ALTER TABLE check_results ADD COLUMN cert_expires_at DATETIME"),
+		postgres: execAll("ALTER TABLE check_results ADD COLUMN IF NOT EXISTS cert_expires_at TIMESTAMPTZ")},
+	{version: 17, name: "add_targets_head_only",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN head_only INTEGER DEFAULT 0"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS head_only BOOLEAN NOT NULL DEFAULT FALSE")},
+	{version: 18, name: "add_targets_retention_days",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN retention_days INTEGER DEFAULT 0"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS retention_days INTEGER NOT NULL DEFAULT 0")},
+	{version: 19, name: "add_targets_port",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN port INTEGER DEFAULT 0"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS port INTEGER NOT NULL DEFAULT 0")},
+	{version: 20, name: "add_targets_priority",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN priority INTEGER DEFAULT 0"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0")},
+	{version: 21, name: "add_targets_ca_file",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN ca_file TEXT DEFAULT ''"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS ca_file TEXT NOT NULL DEFAULT ''")},
+	{version: 22, name: "add_targets_client_cert",
+		sqlite: execAll(
+			"ALTER TABLE targets ADD COLUMN client_cert TEXT DEFAULT ''",
+			"ALTER TABLE targets ADD COLUMN client_key TEXT DEFAULT ''"),
+		postgres: execAll(
+			"ALTER TABLE targets ADD COLUMN IF NOT EXISTS client_cert TEXT NOT NULL DEFAULT ''",
+			"ALTER TABLE targets ADD COLUMN IF NOT EXISTS client_key TEXT NOT NULL DEFAULT ''")},
+	{version: 23, name: "add_targets_score",
+		sqlite: execAll(
+			"ALTER TABLE targets ADD COLUMN score_rules TEXT DEFAULT ''",
+			"ALTER TABLE targets ADD COLUMN score_warn INTEGER DEFAULT 0",
+			"ALTER TABLE targets ADD COLUMN score_min INTEGER DEFAULT 0"),
+		postgres: execAll(
+			"ALTER TABLE targets ADD COLUMN IF NOT EXISTS score_rules TEXT NOT NULL DEFAULT ''",
+			"ALTER TABLE targets ADD COLUMN IF NOT EXISTS score_warn INTEGER NOT NULL DEFAULT 0",
+			"ALTER TABLE targets ADD COLUMN IF NOT EXISTS score_min INTEGER NOT NULL DEFAULT 0")},
+	{version: 24, name: "add_check_results_meta",
+		sqlite:   execAll("ALTER TABLE check_results ADD COLUMN meta TEXT DEFAULT ''"),
+		postgres: execAll("ALTER TABLE check_results ADD COLUMN IF NOT EXISTS meta TEXT NOT NULL DEFAULT ''")},
+	{version: 25, name: "add_targets_expect_absent",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN expect_absent TEXT DEFAULT ''"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS expect_absent TEXT NOT NULL DEFAULT ''")},
+	{version: 26, name: "add_targets_proxy",
+		sqlite:   execAll("ALTER TABLE targets ADD COLUMN proxy TEXT DEFAULT ''"),
+		postgres: execAll("ALTER TABLE targets ADD COLUMN IF NOT EXISTS proxy TEXT NOT NULL DEFAULT ''")},
+	{version: 27, name: "regression_out_of_trigger_rule",
+		sqlite: regressionOutOfTrigger(
+			[]string{
+				"ALTER TABLE targets ADD COLUMN alert_on_regression INTEGER DEFAULT 0",
+				"ALTER TABLE targets ADD COLUMN regression_multiplier REAL DEFAULT 0",
+			},
+			"UPDATE targets SET alert_on_regression = 1, regression_multiplier = ?, trigger_rule = '' WHERE id = ?"),
+		postgres: regressionOutOfTrigger(
+			[]string{
+				"ALTER TABLE targets ADD COLUMN IF NOT EXISTS alert_on_regression BOOLEAN NOT NULL DEFAULT FALSE",
+				"ALTER TABLE targets ADD COLUMN IF NOT EXISTS regression_multiplier DOUBLE PRECISION NOT NULL DEFAULT 0",
+			},
+			"UPDATE targets SET alert_on_regression = TRUE, regression_multiplier = $1, trigger_rule = '' WHERE id = $2")},
+	{version: 28, name: "soft_down_keywords_json",
+		sqlite:   softDownKeywordsJSON("UPDATE targets SET soft_down_keywords = ? WHERE id = ?"),
+		postgres: softDownKeywordsJSON("UPDATE targets SET soft_down_keywords = $1 WHERE id = $2")},
+}
+
+// retriesAreExtra stored retries as the total attempt count until retries
+// became the number of extra attempts after a failure. Existing targets drop
+// one so they keep checking exactly as often as before.
+const retriesAreExtra = "UPDATE targets SET retries = retries - 1 WHERE retries > 0"
+
+// regressionOutOfTrigger adds the regression alert columns and moves each
+// response_time_regression rule out of trigger_rule into them, so a target
+// can have a regression alert and a content trigger at once. update sets
+// a target's multiplier and id in the backend's placeholder syntax.
+func regressionOutOfTrigger(alter []string, update string) func(db sqlConn) error {
+	return func(db sqlConn) error {
+		if err := execAll(alter...)(db); err != nil {
+			return err
+		}
+		rows, err := db.Query("SELECT id, trigger_rule FROM targets WHERE trigger_rule LIKE '%response_time_regression%'")
+		if err != nil {
+			return err
+		}
+		moved := make(map[int64]float64)
+		for rows.Next() {
+			var id int64
+			var rule string
+			if err := rows.Scan(&id, &rule); err != nil {
+				rows.Close()
+				return err
+			}
+			var r struct{ Type, Value string }
+			if json.Unmarshal([]byte(rule), &r) != nil || r.Type != "response_time_regression" {
+				continue
+			}
+			moved[id], _ = strconv.ParseFloat(r.Value, 64)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for id, multiplier := range moved {
+			if _, err := db.Exec(update, multiplier, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// softDownKeywordsJSON rewrites soft_down_keywords from a comma-separated
+// list, which split phrases that contain a comma, to a JSON array.
+func softDownKeywordsJSON(update string) func(db sqlConn) error {
+	return func(db sqlConn) error {
+		rows, err := db.Query("SELECT id, soft_down_keywords FROM targets WHERE soft_down_keywords <> ''")
+		if err != nil {
+			return err
+		}
+		lists := make(map[int64]string)
+		for rows.Next() {
+			var id int64
+			var list string
+			if err := rows.Scan(&id, &list); err != nil {
+				rows.Close()
+				return err
+			}
+			lists[id] = encodeList(splitList(list))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for id, list := range lists {
+			if _, err := db.Exec(update, list, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func execAll(stmts ...string) func(db sqlConn) error {
+	return func(db sqlConn) error {
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// dialect is what the migration runner needs to know about a backend.
+type dialect struct {
+	name            string
+	migrationsTable string           // creates schema_migrations
+	record          string           // inserts a name into schema_migrations unless it is there
+	busy            func(error) bool // reports a lock held by another connection; nil if writes never retry
+}
+
+func (m migration) step(d *dialect) func(db sqlConn) error {
+	if d.name == "postgres" {
+		return m.postgres
+	}
+	return m.sqlite
+}
+
+// autoMigrate makes opening a database apply pending migrations.
+var autoMigrate = true
+
+// SetAutoMigrate controls whether opening a database applies pending
+// migrations. upp db turns it off so the schema can be inspected before
+// it is upgraded.
+func SetAutoMigrate(on bool) {
+	autoMigrate = on
+}
+
+// MigrationStatus reports one schema migration and when it was applied.
+// Version is 0 for a migration recorded by a newer upp that this build
+// doesn't know.
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// LatestSchemaVersion is the schema version this build migrates to.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// SchemaVersion is the highest version in statuses applied together with
+// every version before it.
+func SchemaVersion(statuses []MigrationStatus) int {
+	version := 0
+	for _, st := range statuses {
+		if st.Version != version+1 || st.AppliedAt == nil {
+			break
+		}
+		version = st.Version
+	}
+	return version
+}
+
+// open prepares a freshly connected store: it makes sure schema_migrations
+// exists and, unless auto-migration is off, applies pending migrations.
+func (s *sqlStore) open() error {
+	if _, err := s.exec(s.dialect.migrationsTable); err != nil {
+		return err
+	}
+	if !autoMigrate {
+		return nil
+	}
+	// Migrating takes the write lock, so look first: a command opening the
+	// database while the daemon writes shouldn't wait when there's nothing
+	// to apply
+	statuses, err := s.Migrations()
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(statuses, func(st MigrationStatus) bool { return st.AppliedAt == nil }) {
+		return nil
+	}
+	_, err = s.Migrate()
+	return err
+}
+
+// Migrate applies the pending migrations in order and returns the ones it
+// applied. Migrations another process applies concurrently are skipped.
+func (s *sqlStore) Migrate() ([]MigrationStatus, error) {
+	var applied []MigrationStatus
+	for _, m := range migrations {
+		ok, err := s.applyMigration(m)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		if ok {
+			now := time.Now()
+			applied = append(applied, MigrationStatus{Version: m.version, Name: m.name, AppliedAt: &now})
+		}
+	}
+	return applied, nil
+}
+
+// applyMigration runs m unless it was applied already, recording it in the
+// same transaction so it either fully happens once or not at all.
+func (s *sqlStore) applyMigration(m migration) (bool, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(s.dialect.record, m.name)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil // already applied
+	}
+	if err := m.step(s.dialect)(tx); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Migrations lists every known migration in order, with when it was
+// applied, followed by any recorded migrations this build doesn't know.
+func (s *sqlStore) Migrations() ([]MigrationStatus, error) {
+	rows, err := s.query("SELECT name, applied_at FROM schema_migrations ORDER BY applied_at, name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	recorded := make(map[string]time.Time)
+	var order []string
+	for rows.Next() {
+		var name string
+		var at time.Time
+		if err := rows.Scan(&name, &at); err != nil {
+			return nil, err
+		}
+		recorded[name] = at
+		order = append(order, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(migrations))
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		known[m.name] = true
+		st := MigrationStatus{Version: m.version, Name: m.name}
+		if at, ok := recorded[m.name]; ok {
+			st.AppliedAt = &at
+		}
+		statuses = append(statuses, st)
+	}
+	for _, name := range order {
+		if !known[name] {
+			at := recorded[name]
+			statuses = append(statuses, MigrationStatus{Name: name, AppliedAt: &at})
+		}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq"
+)
+
+// OpenPostgres connects to the Postgres database described by dsn and
+// brings its schema up to date. dsn accepts either a postgres:// URL or a
+// key=value connection string.
+func OpenPostgres(dsn string) (Store, error) {
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	s := &sqlStore{db: conn, rebind: postgresRebind, dialect: &postgresDialect}
+	if err := s.open(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+var postgresDialect = dialect{
+	name: "postgres",
+	migrationsTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		name TEXT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	record: "INSERT INTO schema_migrations (name) VALUES ($1) ON CONFLICT DO NOTHING",
+}
+
+// postgresBaseSchema creates the same logical schema as sqliteBaseSchema
+// using native Postgres types.
+func postgresBaseSchema(db sqlConn) error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS targets (
+		id BIGSERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		url TEXT NOT NULL,
+		type TEXT NOT NULL DEFAULT 'http',
+		interval_seconds INTEGER NOT NULL DEFAULT 300,
+		selector TEXT NOT NULL DEFAULT '',
+		headers TEXT NOT NULL DEFAULT '',
+		expect TEXT NOT NULL DEFAULT '',
+		timeout INTEGER NOT NULL DEFAULT 30,
+		retries INTEGER NOT NULL DEFAULT 0,
+		threshold DOUBLE PRECISION NOT NULL DEFAULT 5.0,
+		trigger_rule TEXT NOT NULL DEFAULT '',
+		jq_filter TEXT NOT NULL DEFAULT '',
+		method TEXT NOT NULL DEFAULT '',
+		body TEXT NOT NULL DEFAULT '',
+		no_follow BOOLEAN NOT NULL DEFAULT FALSE,
+		accept_status TEXT NOT NULL DEFAULT '',
+		insecure BOOLEAN NOT NULL DEFAULT FALSE,
+		hash_headers TEXT NOT NULL DEFAULT '',
+		expect_content_type TEXT NOT NULL DEFAULT '',
+		soft_down_keywords TEXT NOT NULL DEFAULT '',
+		cert_pin TEXT NOT NULL DEFAULT '',
+		alert_cert_change BOOLEAN NOT NULL DEFAULT FALSE,
+		expect_min_tls TEXT NOT NULL DEFAULT '',
+		escalation TEXT NOT NULL DEFAULT '',
+		notify_on_recovery BOOLEAN NOT NULL DEFAULT FALSE,
+		max_total_time INTEGER NOT NULL DEFAULT 0,
+		stream_mode BOOLEAN NOT NULL DEFAULT FALSE,
+		read_bytes INTEGER NOT NULL DEFAULT 0,
+		quorum INTEGER NOT NULL DEFAULT 0,
+		change_threshold DOUBLE PRECISION NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		paused BOOLEAN NOT NULL DEFAULT FALSE,
+		muted BOOLEAN NOT NULL DEFAULT FALSE,
+		UNIQUE(url, type, selector)
+	);
+
+	CREATE TABLE IF NOT EXISTS check_results (
+		id BIGSERIAL PRIMARY KEY,
+		target_id BIGINT NOT NULL REFERENCES targets(id) ON DELETE CASCADE,
+		status TEXT NOT NULL,
+		status_code INTEGER NOT NULL DEFAULT 0,
+		response_time_ms BIGINT NOT NULL DEFAULT 0,
+		content_hash TEXT NOT NULL DEFAULT '',
+		content_type TEXT NOT NULL DEFAULT '',
+		dns_ms BIGINT NOT NULL DEFAULT 0,
+		connect_ms BIGINT NOT NULL DEFAULT 0,
+		tls_ms BIGINT NOT NULL DEFAULT 0,
+		first_byte_ms BIGINT NOT NULL DEFAULT 0,
+		cert_fingerprint TEXT NOT NULL DEFAULT '',
+		tls_version TEXT NOT NULL DEFAULT '',
+		tls_cipher TEXT NOT NULL DEFAULT '',
+		tls_chain_valid BOOLEAN NOT NULL DEFAULT FALSE,
+		attempt_errors TEXT NOT NULL DEFAULT '',
+		request_bytes BIGINT NOT NULL DEFAULT 0,
+		response_bytes BIGINT NOT NULL DEFAULT 0,
+		retry_after_ms BIGINT NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		checked_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+
+	CREATE TABLE IF NOT EXISTS snapshots (
+		id BIGSERIAL PRIMARY KEY,
+		target_id BIGINT NOT NULL REFERENCES targets(id) ON DELETE CASCADE,
+		content TEXT NOT NULL,
+		hash TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		compressed BOOLEAN NOT NULL DEFAULT FALSE,
+		content_gz BYTEA,
+		size BIGINT NOT NULL DEFAULT 0,
+		signature TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS notify_configs (
+		id BIGSERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		type TEXT NOT NULL,
+		config TEXT NOT NULL,
+		enabled BOOLEAN NOT NULL DEFAULT TRUE
+	);
+
+	CREATE TABLE IF NOT EXISTS target_tags (
+		target_id BIGINT NOT NULL REFERENCES targets(id) ON DELETE CASCADE,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (target_id, tag)
+	);
+
+	CREATE TABLE IF NOT EXISTS check_leases (
+		target_id BIGINT PRIMARY KEY,
+		holder TEXT NOT NULL,
+		expires_at BIGINT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS incidents (
+		id BIGSERIAL PRIMARY KEY,
+		target_id BIGINT NOT NULL REFERENCES targets(id) ON DELETE CASCADE,
+		started_at TIMESTAMPTZ NOT NULL,
+		resolved_at TIMESTAMPTZ,
+		steps_sent INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_incidents_open ON incidents(target_id, resolved_at);
+	CREATE INDEX IF NOT EXISTS idx_results_target ON check_results(target_id, checked_at);
+	CREATE INDEX IF NOT EXISTS idx_snapshots_target ON snapshots(target_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_target_tags ON target_tags(tag);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+
+	// Columns added after the initial Postgres schema
+	for _, stmt := range []string{
+		"ALTER TABLE targets ADD COLUMN IF NOT EXISTS soft_down_keywords TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE check_results ADD COLUMN IF NOT EXISTS dns_ms BIGINT NOT NULL DEFAULT 0",
+		"ALTER TABLE check_results ADD COLUMN IF NOT EXISTS connect_ms BIGINT NOT NULL DEFAULT 0",
+		"ALTER TABLE check_results ADD COLUMN IF NOT EXISTS tls_ms BIGINT NOT NULL DEFAULT 0",
+		"ALTER TABLE check_results ADD COLUMN IF NOT EXISTS first_byte_ms BIGINT NOT NULL DEFAULT 0",
+		"ALTER TABLE check_results ADD COLUMN IF NOT EXISTS cert_fingerprint TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE targets ADD COLUMN IF NOT EXISTS cert_pin TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE targets ADD COLUMN IF NOT EXISTS alert_cert_change BOOLEAN NOT NULL DEFAULT FALSE",
+		"ALTER TABLE check_results ADD COLUMN IF NOT EXISTS tls_version TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE check_results ADD COLUMN IF NOT EXISTS tls_cipher TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE check_results ADD COLUMN IF NOT EXISTS tls_chain_valid BOOLEAN NOT NULL DEFAULT FALSE",
+		"ALTER TABLE targets ADD COLUMN IF NOT EXISTS expect_min_tls TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE check_results ADD COLUMN IF NOT EXISTS attempt_errors TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE check_results ADD COLUMN IF NOT EXISTS request_bytes BIGINT NOT NULL DEFAULT 0",
+		"ALTER TABLE check_results ADD COLUMN IF NOT EXISTS response_bytes BIGINT NOT NULL DEFAULT 0",
+		"ALTER TABLE targets ADD COLUMN IF NOT EXISTS muted BOOLEAN NOT NULL DEFAULT FALSE",
+		"ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS compressed BOOLEAN NOT NULL DEFAULT FALSE",
+		"ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS content_gz BYTEA",
+		"ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS size BIGINT NOT NULL DEFAULT 0",
+		"ALTER TABLE targets ADD COLUMN IF NOT EXISTS escalation TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE targets ADD COLUMN IF NOT EXISTS notify_on_recovery BOOLEAN NOT NULL DEFAULT FALSE",
+		"ALTER TABLE targets ADD COLUMN IF NOT EXISTS max_total_time INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE targets ADD COLUMN IF NOT EXISTS stream_mode BOOLEAN NOT NULL DEFAULT FALSE",
+		"ALTER TABLE targets ADD COLUMN IF NOT EXISTS read_bytes INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE targets ADD COLUMN IF NOT EXISTS quorum INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE check_results ADD COLUMN IF NOT EXISTS retry_after_ms BIGINT NOT NULL DEFAULT 0",
+		"ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS signature TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE targets ADD COLUMN IF NOT EXISTS change_threshold DOUBLE PRECISION NOT NULL DEFAULT 0",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postgresRebind rewrites '?' placeholders to Postgres' positional $1, $2, ...
+// A '?' inside a quoted string literal or identifier is left alone.
+func postgresRebind(query string) string {
+	var sb strings.Builder
+	n := 0
+	var quote rune // the quote of the literal being copied, or 0
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			// A doubled quote closes and reopens the literal, so it
+			// needs no special case
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,821 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// sqlStore implements Store on top of database/sql. Queries are written with
+// '?' placeholders and portable SQL; rebind adapts them to the driver's
+// placeholder syntax. Inside WithTx, tx is set and queries run on it.
+type sqlStore struct {
+	db      *sql.DB
+	tx      *sql.Tx
+	rebind  func(query string) string
+	dialect *dialect
+}
+
+// sqlConn is the query surface shared by *sql.DB and *sql.Tx.
+type sqlConn interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (s *sqlStore) conn() sqlConn {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+func (s *sqlStore) exec(query string, args ...interface{}) (sql.Result, error) {
+	var res sql.Result
+	err := s.retryBusy(func() (err error) {
+		res, err = s.conn().Exec(s.rebind(query), args...)
+		return err
+	})
+	return res, err
+}
+
+func (s *sqlStore) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return s.conn().Query(s.rebind(query), args...)
+}
+
+func (s *sqlStore) queryRow(query string, args ...interface{}) *sql.Row {
+	return s.conn().QueryRow(s.rebind(query), args...)
+}
+
+// begin starts a transaction, retrying while another connection holds the
+// write lock.
+func (s *sqlStore) begin() (*sql.Tx, error) {
+	var tx *sql.Tx
+	err := s.retryBusy(func() (err error) {
+		tx, err = s.db.Begin()
+		return err
+	})
+	return tx, err
+}
+
+// A write that still finds the database locked once the driver's own busy
+// wait is over is retried this many times, the delay doubling each time.
+const (
+	busyRetries = 5
+	busyBackoff = 50 * time.Millisecond
+)
+
+// retryBusy runs fn, retrying it with backoff while the database reports
+// a lock held by another connection, such as the daemon writing while a
+// CLI command runs. Statements inside a transaction aren't retried: the
+// transaction took the write lock when it began.
+func (s *sqlStore) retryBusy(fn func() error) error {
+	err := fn()
+	if s.tx != nil || s.dialect.busy == nil {
+		return err
+	}
+	delay := busyBackoff
+	for i := 0; i < busyRetries && err != nil && s.dialect.busy(err); i++ {
+		time.Sleep(delay)
+		delay *= 2
+		err = fn()
+	}
+	return err
+}
+
+func (s *sqlStore) Close() error {
+	if s.tx != nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// WithTx runs fn against a store bound to a single transaction. The
+// transaction commits if fn returns nil and rolls back otherwise. Nested
+// calls reuse the outer transaction.
+func (s *sqlStore) WithTx(fn func(tx Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(&sqlStore{db: s.db, tx: tx, rebind: s.rebind, dialect: s.dialect}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) AddTarget(name, url, typ string, interval int, selector, headers, expect string, timeout, retries int, threshold float64, opts AddTargetOpts) (*Target, error) {
+	if name == "" {
+		name = url
+	}
+	if typ == "" {
+		typ = "http"
+	}
+	if timeout <= 0 {
+		timeout = 30
+	}
+	if retries < NoRetry {
+		retries = 0
+	}
+	if threshold <= 0 {
+		threshold = 5.0
+	}
+	var id int64
+	err := s.retryBusy(func() error {
+		return s.queryRow(
+			"INSERT INTO targets (name, url, type, interval_seconds, selector, headers, expect, timeout, retries, threshold, trigger_rule, jq_filter, method, body, no_follow, accept_status, insecure, hash_headers, expect_content_type, soft_down_keywords, cert_pin, alert_cert_change, expect_min_tls, escalation, notify_on_recovery, max_total_time, stream_mode, read_bytes, quorum, change_threshold, redirect_status, channels, severity, selector_type, json_path, alert_on_ip_change, connect_timeout, meta, snapshot_mode, strict_selector, expect_hash, fail_on_empty, head_only, retention_days, port, priority, ca_file, client_cert, client_key, score_rules, score_warn, score_min, expect_absent, proxy, alert_on_regression, regression_multiplier) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id",
+			name, url, typ, interval, selector, headers, expect, timeout, retries, threshold, opts.TriggerRule, opts.JQFilter, opts.Method, opts.Body, opts.NoFollow, opts.AcceptStatus, opts.Insecure, joinList(opts.HashHeaders), opts.ExpectContentType, encodeList(opts.SoftDownKeywords), opts.CertPin, opts.AlertCertChange, opts.ExpectMinTLS, opts.Escalation, opts.NotifyOnRecovery, opts.MaxTotalTime, opts.StreamMode, opts.ReadBytes, opts.Quorum, opts.ChangeThreshold, opts.RedirectStatus, joinList(opts.Channels), opts.Severity, opts.SelectorType, opts.JSONPath, opts.AlertOnIPChange, opts.ConnectTimeout, encodeMeta(opts.Meta), opts.SnapshotMode, opts.StrictSelector, opts.ExpectHash, opts.FailOnEmpty, opts.HeadOnly, opts.RetentionDays, opts.Port, opts.Priority, opts.CAFile, opts.ClientCert, opts.ClientKey, joinLines(opts.ScoreRules), opts.ScoreWarn, opts.ScoreMin, opts.ExpectAbsent, opts.Proxy, opts.AlertOnRegression, opts.RegressionMultiplier,
+		).Scan(&id)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add target (may already exist): %w", err)
+	}
+	return &Target{ID: id, Name: name, URL: url, Type: typ, Interval: interval, Selector: selector, Headers: headers, Expect: expect, Timeout: timeout, Retries: retries, Threshold: threshold, TriggerRule: opts.TriggerRule, JQFilter: opts.JQFilter, Method: opts.Method, Body: opts.Body, NoFollow: opts.NoFollow, AcceptStatus: opts.AcceptStatus, Insecure: opts.Insecure, HashHeaders: opts.HashHeaders, ExpectContentType: opts.ExpectContentType, SoftDownKeywords: opts.SoftDownKeywords, CertPin: opts.CertPin, AlertCertChange: opts.AlertCertChange, ExpectMinTLS: opts.ExpectMinTLS, Escalation: opts.Escalation, NotifyOnRecovery: opts.NotifyOnRecovery, MaxTotalTime: opts.MaxTotalTime, StreamMode: opts.StreamMode, ReadBytes: opts.ReadBytes, Quorum: opts.Quorum, ChangeThreshold: opts.ChangeThreshold, RedirectStatus: opts.RedirectStatus, Channels: opts.Channels, Severity: opts.Severity, SelectorType: opts.SelectorType, JSONPath: opts.JSONPath, AlertOnIPChange: opts.AlertOnIPChange, ConnectTimeout: opts.ConnectTimeout, Meta: opts.Meta, SnapshotMode: opts.SnapshotMode, StrictSelector: opts.StrictSelector, ExpectHash: opts.ExpectHash, FailOnEmpty: opts.FailOnEmpty, HeadOnly: opts.HeadOnly, RetentionDays: opts.RetentionDays, Port: opts.Port, Priority: opts.Priority, CAFile: opts.CAFile, ClientCert: opts.ClientCert, ClientKey: opts.ClientKey, ScoreRules: opts.ScoreRules, ScoreWarn: opts.ScoreWarn, ScoreMin: opts.ScoreMin, ExpectAbsent: opts.ExpectAbsent, Proxy: opts.Proxy, AlertOnRegression: opts.AlertOnRegression, RegressionMultiplier: opts.RegressionMultiplier, CreatedAt: time.Now()}, nil
+}
+
+func (s *sqlStore) RemoveTarget(identifier string) error {
+	// Try by name first, then URL, then ID
+	res, err := s.exec("DELETE FROM targets WHERE name = ? OR url = ? OR CAST(id AS TEXT) = ?", identifier, identifier, identifier)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return fmt.Errorf("target not found: %s", identifier)
+	}
+	return nil
+}
+
+func (s *sqlStore) ListTargets() ([]Target, error) {
+	rows, err := s.query("SELECT " + targetColumns + " FROM targets ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var targets []Target
+	for rows.Next() {
+		t, err := scanTarget(rows)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, *t)
+	}
+	return targets, nil
+}
+
+// targetOrders are the ORDER BY clauses of TargetOrders.
+var targetOrders = map[string]string{
+	"":         "t.id",
+	"id":       "t.id",
+	"name":     "LOWER(t.name), t.id",
+	"priority": "t.priority DESC, t.id",
+}
+
+func (s *sqlStore) ListTargetsPage(tag, order string, page Page) ([]Target, int, error) {
+	orderBy, ok := targetOrders[order]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown order %q (want %s)", order, strings.Join(TargetOrders, ", "))
+	}
+	from, args := " FROM targets t", []interface{}{}
+	if tag != "" {
+		from += " INNER JOIN target_tags tt ON t.id = tt.target_id WHERE tt.tag = ?"
+		args = append(args, tag)
+	}
+
+	var total int
+	if err := s.queryRow("SELECT COUNT(*)"+from, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit, limitArgs := page.clause()
+	rows, err := s.query("SELECT "+prefixColumns("t", targetColumns)+from+" ORDER BY "+orderBy+limit, append(args, limitArgs...)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var targets []Target
+	for rows.Next() {
+		t, err := scanTarget(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		targets = append(targets, *t)
+	}
+	return targets, total, rows.Err()
+}
+
+func (s *sqlStore) GetTarget(identifier string) (*Target, error) {
+	row := s.queryRow(
+		"SELECT "+targetColumns+" FROM targets WHERE name = ? OR url = ? OR CAST(id AS TEXT) = ?",
+		identifier, identifier, identifier,
+	)
+	t, err := scanTarget(row)
+	if err != nil {
+		return nil, fmt.Errorf("target not found: %s", identifier)
+	}
+	return t, nil
+}
+
+func (s *sqlStore) UpdateTarget(t *Target) error {
+	res, err := s.exec(
+		`UPDATE targets SET name=?, url=?, type=?, interval_seconds=?, selector=?, headers=?, expect=?, timeout=?, retries=?, threshold=?, trigger_rule=?, jq_filter=?, method=?, body=?, no_follow=?, accept_status=?, insecure=?, hash_headers=?, expect_content_type=?, soft_down_keywords=?, cert_pin=?, alert_cert_change=?, expect_min_tls=?, escalation=?, notify_on_recovery=?, max_total_time=?, stream_mode=?, read_bytes=?, quorum=?, change_threshold=?, redirect_status=?, channels=?, severity=?, selector_type=?, json_path=?, alert_on_ip_change=?, connect_timeout=?, meta=?, snapshot_mode=?, strict_selector=?, expect_hash=?, fail_on_empty=?, head_only=?, retention_days=?, port=?, priority=?, ca_file=?, client_cert=?, client_key=?, score_rules=?, score_warn=?, score_min=?, expect_absent=?, proxy=?, alert_on_regression=?, regression_multiplier=? WHERE id=?`,
+		t.Name, t.URL, t.Type, t.Interval, t.Selector, t.Headers, t.Expect, t.Timeout, t.Retries, t.Threshold, t.TriggerRule, t.JQFilter, t.Method, t.Body, t.NoFollow, t.AcceptStatus, t.Insecure, joinList(t.HashHeaders), t.ExpectContentType, encodeList(t.SoftDownKeywords), t.CertPin, t.AlertCertChange, t.ExpectMinTLS, t.Escalation, t.NotifyOnRecovery, t.MaxTotalTime, t.StreamMode, t.ReadBytes, t.Quorum, t.ChangeThreshold, t.RedirectStatus, joinList(t.Channels), t.Severity, t.SelectorType, t.JSONPath, t.AlertOnIPChange, t.ConnectTimeout, encodeMeta(t.Meta), t.SnapshotMode, t.StrictSelector, t.ExpectHash, t.FailOnEmpty, t.HeadOnly, t.RetentionDays, t.Port, t.Priority, t.CAFile, t.ClientCert, t.ClientKey, joinLines(t.ScoreRules), t.ScoreWarn, t.ScoreMin, t.ExpectAbsent, t.Proxy, t.AlertOnRegression, t.RegressionMultiplier, t.ID,
+	)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return fmt.Errorf("target not found: %d", t.ID)
+	}
+	return nil
+}
+
+func (s *sqlStore) SaveCheckResult(r *CheckResult) error {
+	_, err := s.exec(
+		"INSERT INTO check_results (target_id, status, status_code, response_time_ms, content_hash, content_type, error, dns_ms, connect_ms, tls_ms, first_byte_ms, cert_fingerprint, tls_version, tls_cipher, tls_chain_valid, attempt_errors, request_bytes, response_bytes, retry_after_ms, remote_ip, cert_expires_at, meta) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		r.TargetID, r.Status, r.StatusCode, r.ResponseTime, r.ContentHash, r.ContentType, r.Error, r.DNSMs, r.ConnectMs, r.TLSMs, r.FirstByteMs, r.CertFingerprint, r.TLSVersion, r.TLSCipher, r.TLSChainValid, joinLines(r.AttemptErrors), r.RequestBytes, r.ResponseBytes, r.RetryAfterMs, r.RemoteIP, r.CertExpiresAt, encodeCheckMeta(r.Meta),
+	)
+	return err
+}
+
+func (s *sqlStore) GetCheckHistory(targetID int64, limit int) ([]CheckResult, error) {
+	rows, err := s.query(
+		"SELECT "+resultColumns+" FROM check_results WHERE target_id = ? ORDER BY checked_at DESC LIMIT ?",
+		targetID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []CheckResult
+	for rows.Next() {
+		r, err := scanResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *r)
+	}
+	return results, nil
+}
+
+func (s *sqlStore) GetRecentHistory(ids []int64, limit int) (map[int64][]CheckResult, error) {
+	m := make(map[int64][]CheckResult)
+	if len(ids) == 0 || limit <= 0 {
+		return m, nil
+	}
+	in, args := idList(ids)
+	rows, err := s.query(
+		`SELECT `+resultColumns+` FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY target_id ORDER BY checked_at DESC, id DESC) AS n
+			FROM check_results WHERE target_id IN (`+in+`)
+		) recent WHERE n <= ? ORDER BY target_id, n`,
+		append(args, limit)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r, err := scanResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		m[r.TargetID] = append(m[r.TargetID], *r)
+	}
+	return m, rows.Err()
+}
+
+func (s *sqlStore) GetCheckHistoryPage(targetID int64, page Page) ([]CheckResult, int, error) {
+	var total int
+	if err := s.queryRow("SELECT COUNT(*) FROM check_results WHERE target_id = ?", targetID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit, limitArgs := page.clause()
+	rows, err := s.query(
+		"SELECT "+resultColumns+" FROM check_results WHERE target_id = ? ORDER BY checked_at DESC, id DESC"+limit,
+		append([]interface{}{targetID}, limitArgs...)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []CheckResult
+	for rows.Next() {
+		r, err := scanResult(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		results = append(results, *r)
+	}
+	return results, total, rows.Err()
+}
+
+// LastCertFingerprint skips checks that never got as far as a TLS
+// handshake, so a failed check doesn't reset what counts as "last seen".
+func (s *sqlStore) LastCertFingerprint(targetID int64) (string, error) {
+	var fp string
+	err := s.queryRow(
+		"SELECT cert_fingerprint FROM check_results WHERE target_id = ? AND cert_fingerprint != '' ORDER BY id DESC LIMIT 1",
+		targetID,
+	).Scan(&fp)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return fp, err
+}
+
+// LastRemoteIP likewise skips checks that failed before connecting.
+func (s *sqlStore) LastRemoteIP(targetID int64) (string, error) {
+	var ip string
+	err := s.queryRow(
+		"SELECT remote_ip FROM check_results WHERE target_id = ? AND remote_ip != '' ORDER BY id DESC LIMIT 1",
+		targetID,
+	).Scan(&ip)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return ip, err
+}
+
+func (s *sqlStore) SaveSnapshot(targetID int64, content, hash, signature string) error {
+	if gz, ok := compressSnapshot(content); ok {
+		_, err := s.exec(
+			"INSERT INTO snapshots (target_id, content, hash, signature, compressed, content_gz, size) VALUES (?, '', ?, ?, ?, ?, ?)",
+			targetID, hash, signature, true, gz, len(content),
+		)
+		return err
+	}
+	_, err := s.exec(
+		"INSERT INTO snapshots (target_id, content, hash, signature, size) VALUES (?, ?, ?, ?, ?)",
+		targetID, content, hash, signature, len(content),
+	)
+	return err
+}
+
+func (s *sqlStore) GetLatestSnapshots(targetID int64, limit int) ([]Snapshot, error) {
+	rows, err := s.query(
+		"SELECT id, target_id, content, hash, signature, created_at, compressed, content_gz FROM snapshots WHERE target_id = ? ORDER BY created_at DESC LIMIT ?",
+		targetID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanSnapshots(rows)
+}
+
+func (s *sqlStore) GetSnapshotsSince(targetID int64, since time.Time) ([]Snapshot, error) {
+	rows, err := s.query(
+		"SELECT id, target_id, content, hash, signature, created_at, compressed, content_gz FROM snapshots WHERE target_id = ? AND created_at >= ? ORDER BY created_at, id",
+		targetID, since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanSnapshots(rows)
+}
+
+func (s *sqlStore) GetSnapshotBefore(targetID int64, before time.Time) (*Snapshot, error) {
+	rows, err := s.query(
+		"SELECT id, target_id, content, hash, signature, created_at, compressed, content_gz FROM snapshots WHERE target_id = ? AND created_at < ? ORDER BY created_at DESC, id DESC LIMIT 1",
+		targetID, before,
+	)
+	if err != nil {
+		return nil, err
+	}
+	snaps, err := scanSnapshots(rows)
+	if err != nil || len(snaps) == 0 {
+		return nil, err
+	}
+	return &snaps[0], nil
+}
+
+// scanSnapshots reads snapshot rows, decompressing their content.
+func scanSnapshots(rows *sql.Rows) ([]Snapshot, error) {
+	defer rows.Close()
+	var snaps []Snapshot
+	for rows.Next() {
+		var snap Snapshot
+		var compressed bool
+		var gz []byte
+		err := rows.Scan(&snap.ID, &snap.TargetID, &snap.Content, &snap.Hash, &snap.Signature, &snap.CreatedAt, &compressed, &gz)
+		if err != nil {
+			return nil, err
+		}
+		if compressed {
+			if snap.Content, err = decompressSnapshot(gz); err != nil {
+				return nil, fmt.Errorf("snapshot %d: %w", snap.ID, err)
+			}
+		}
+		snaps = append(snaps, snap)
+	}
+	return snaps, rows.Err()
+}
+
+func (s *sqlStore) GetSnapshotStorage() (SnapshotStorage, error) {
+	var st SnapshotStorage
+	err := s.queryRow(
+		`SELECT COUNT(*),
+			COALESCE(SUM(CASE WHEN compressed THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN size > 0 THEN size ELSE LENGTH(content) END), 0),
+			COALESCE(SUM(CASE WHEN compressed THEN LENGTH(content_gz) ELSE LENGTH(content) END), 0)
+		FROM snapshots`,
+	).Scan(&st.Snapshots, &st.Compressed, &st.RawBytes, &st.StoredBytes)
+	return st, err
+}
+
+func (s *sqlStore) PruneHistory(targetID int64, before time.Time) (PruneStats, error) {
+	var st PruneStats
+	res, err := s.exec(
+		`DELETE FROM check_results WHERE target_id = ? AND checked_at < ?
+		AND id <> (SELECT MAX(id) FROM check_results WHERE target_id = ?)`,
+		targetID, before, targetID,
+	)
+	if err != nil {
+		return st, err
+	}
+	st.Results, _ = res.RowsAffected()
+	res, err = s.exec(
+		`DELETE FROM snapshots WHERE target_id = ? AND created_at < ?
+		AND id <> (SELECT MAX(id) FROM snapshots WHERE target_id = ?)`,
+		targetID, before, targetID,
+	)
+	if err != nil {
+		return st, err
+	}
+	st.Snapshots, _ = res.RowsAffected()
+	return st, nil
+}
+
+// upStatuses are the result statuses that count as up.
+var upStatuses = []string{"up", "baseline", "unchanged", "changed", "redirect", "down-local-only"}
+
+// upStatus is the SQL condition for a result that counts as up.
+var upStatus = "status IN ('" + strings.Join(upStatuses, "', '") + "')"
+
+// IsUp reports whether a result status counts as up, the same way uptime
+// stats count it.
+func IsUp(status string) bool {
+	return slices.Contains(upStatuses, status)
+}
+
+func (s *sqlStore) GetUptimeStats(targetID int64, since time.Time) (total int, up int, avgResponseMs float64, err error) {
+	err = s.queryRow(
+		`SELECT COUNT(*), COALESCE(SUM(CASE WHEN `+upStatus+` THEN 1 ELSE 0 END), 0), COALESCE(AVG(response_time_ms), 0)
+		FROM check_results WHERE target_id = ? AND checked_at >= ?`,
+		targetID, since,
+	).Scan(&total, &up, &avgResponseMs)
+	return
+}
+
+func (s *sqlStore) GetBandwidth(targetID int64, since time.Time) (requestBytes, responseBytes int64, err error) {
+	err = s.queryRow(
+		`SELECT COALESCE(SUM(request_bytes), 0), COALESCE(SUM(response_bytes), 0)
+		FROM check_results WHERE target_id = ? AND checked_at >= ?`,
+		targetID, since,
+	).Scan(&requestBytes, &responseBytes)
+	return
+}
+
+// GetTargetStats counts an outage at each down or error result whose
+// previous result in the window, if any, was neither.
+func (s *sqlStore) GetTargetStats(since time.Time) ([]TargetStats, error) {
+	return s.targetStats("checked_at >= ?", since)
+}
+
+func (s *sqlStore) GetTargetStatsFor(targetID int64, since time.Time) (TargetStats, error) {
+	stats, err := s.targetStats("target_id = ? AND checked_at >= ?", targetID, since)
+	if err != nil || len(stats) == 0 {
+		return TargetStats{TargetID: targetID}, err
+	}
+	return stats[0], nil
+}
+
+// targetStats aggregates the check results matching where per target.
+func (s *sqlStore) targetStats(where string, args ...interface{}) ([]TargetStats, error) {
+	rows, err := s.query(
+		`SELECT target_id, COUNT(*), SUM(CASE WHEN `+upStatus+` THEN 1 ELSE 0 END), AVG(response_time_ms), MAX(response_time_ms),
+			SUM(CASE WHEN status IN ('down', 'error') AND (prev_status IS NULL OR prev_status NOT IN ('down', 'error')) THEN 1 ELSE 0 END)
+		FROM (
+			SELECT target_id, status, response_time_ms, LAG(status) OVER (PARTITION BY target_id ORDER BY id) AS prev_status
+			FROM check_results WHERE `+where+`
+		) r
+		GROUP BY target_id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []TargetStats
+	for rows.Next() {
+		var st TargetStats
+		if err := rows.Scan(&st.TargetID, &st.Checks, &st.Up, &st.AvgResponseMs, &st.MaxResponseMs, &st.Outages); err != nil {
+			return nil, err
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
+
+func (s *sqlStore) SaveNotifyConfig(name, typ, config string) error {
+	_, err := s.exec("INSERT INTO notify_configs (name, type, config) VALUES (?, ?, ?)", name, typ, config)
+	return err
+}
+
+func (s *sqlStore) ListNotifyConfigs() ([]NotifyConfig, error) {
+	rows, err := s.query("SELECT id, name, type, config, enabled FROM notify_configs ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var configs []NotifyConfig
+	for rows.Next() {
+		var c NotifyConfig
+		err := rows.Scan(&c.ID, &c.Name, &c.Type, &c.Config, &c.Enabled)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, c)
+	}
+	return configs, nil
+}
+
+func (s *sqlStore) SetPaused(identifier string, paused bool) error {
+	res, err := s.exec("UPDATE targets SET paused = ? WHERE name = ? OR url = ? OR CAST(id AS TEXT) = ?", paused, identifier, identifier, identifier)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return fmt.Errorf("target not found: %s", identifier)
+	}
+	return nil
+}
+
+func (s *sqlStore) SetMuted(identifier string, muted bool) error {
+	res, err := s.exec("UPDATE targets SET muted = ? WHERE name = ? OR url = ? OR CAST(id AS TEXT) = ?", muted, identifier, identifier, identifier)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return fmt.Errorf("target not found: %s", identifier)
+	}
+	return nil
+}
+
+const incidentColumns = "id, target_id, started_at, resolved_at, steps_sent, error"
+
+func scanIncident(row rowScanner) (*Incident, error) {
+	var inc Incident
+	var resolved sql.NullTime
+	if err := row.Scan(&inc.ID, &inc.TargetID, &inc.StartedAt, &resolved, &inc.StepsSent, &inc.Error); err != nil {
+		return nil, err
+	}
+	if resolved.Valid {
+		inc.ResolvedAt = &resolved.Time
+	}
+	return &inc, nil
+}
+
+func (s *sqlStore) OpenIncident(targetID int64, errMsg string) (*Incident, error) {
+	inc, err := scanIncident(s.queryRow("SELECT "+incidentColumns+" FROM incidents WHERE target_id = ? AND resolved_at IS NULL", targetID))
+	if err == nil {
+		return inc, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	var id int64
+	err = s.retryBusy(func() error {
+		return s.queryRow(
+			"INSERT INTO incidents (target_id, started_at, steps_sent, error) VALUES (?, ?, 0, ?) RETURNING id",
+			targetID, now, errMsg,
+		).Scan(&id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Incident{ID: id, TargetID: targetID, StartedAt: now, Error: errMsg}, nil
+}
+
+func (s *sqlStore) ListOpenIncidents() ([]Incident, error) {
+	rows, err := s.query("SELECT " + incidentColumns + " FROM incidents WHERE resolved_at IS NULL ORDER BY started_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var incs []Incident
+	for rows.Next() {
+		inc, err := scanIncident(rows)
+		if err != nil {
+			return nil, err
+		}
+		incs = append(incs, *inc)
+	}
+	return incs, rows.Err()
+}
+
+func (s *sqlStore) AdvanceIncident(id int64, from, to int) (bool, error) {
+	res, err := s.exec("UPDATE incidents SET steps_sent = ? WHERE id = ? AND steps_sent = ? AND resolved_at IS NULL", to, id, from)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (s *sqlStore) ResolveIncident(targetID int64) (*Incident, error) {
+	inc, err := scanIncident(s.queryRow("SELECT "+incidentColumns+" FROM incidents WHERE target_id = ? AND resolved_at IS NULL", targetID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	res, err := s.exec("UPDATE incidents SET resolved_at = ? WHERE id = ? AND resolved_at IS NULL", now, inc.ID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil // resolved concurrently
+	}
+	inc.ResolvedAt = &now
+	return inc, nil
+}
+
+func (s *sqlStore) RemoveNotifyConfig(identifier string) error {
+	res, err := s.exec("DELETE FROM notify_configs WHERE name = ? OR CAST(id AS TEXT) = ?", identifier, identifier)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return fmt.Errorf("notification config not found: %s", identifier)
+	}
+	return nil
+}
+
+// Tag operations
+
+func (s *sqlStore) AddTags(targetID int64, tags []string) error {
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		_, err := s.exec("INSERT INTO target_tags (target_id, tag) VALUES (?, ?) ON CONFLICT DO NOTHING", targetID, tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlStore) RemoveTags(targetID int64, tags []string) error {
+	for _, tag := range tags {
+		s.exec("DELETE FROM target_tags WHERE target_id = ? AND tag = ?", targetID, strings.TrimSpace(tag))
+	}
+	return nil
+}
+
+func (s *sqlStore) ClearTags(targetID int64) error {
+	_, err := s.exec("DELETE FROM target_tags WHERE target_id = ?", targetID)
+	return err
+}
+
+func (s *sqlStore) GetTags(targetID int64) ([]string, error) {
+	rows, err := s.query("SELECT tag FROM target_tags WHERE target_id = ? ORDER BY tag", targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tags []string
+	for rows.Next() {
+		var tag string
+		rows.Scan(&tag)
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func (s *sqlStore) ListAllTags() ([]string, error) {
+	rows, err := s.query("SELECT DISTINCT tag FROM target_tags ORDER BY tag")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tags []string
+	for rows.Next() {
+		var tag string
+		rows.Scan(&tag)
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// GetTagMap returns a map of targetID -> []tags for all targets.
+func (s *sqlStore) GetTagMap() (map[int64][]string, error) {
+	rows, err := s.query("SELECT target_id, tag FROM target_tags ORDER BY target_id, tag")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	m := make(map[int64][]string)
+	for rows.Next() {
+		var id int64
+		var tag string
+		rows.Scan(&id, &tag)
+		m[id] = append(m[id], tag)
+	}
+	return m, nil
+}
+
+// GetLatestErrors returns targetID -> error message for each of ids whose
+// most recent check failed, in a single query.
+func (s *sqlStore) GetLatestErrors(ids []int64) (map[int64]string, error) {
+	m := make(map[int64]string)
+	if len(ids) == 0 {
+		return m, nil
+	}
+	in, args := idList(ids)
+	rows, err := s.query(`SELECT cr.target_id, cr.error FROM check_results cr
+		JOIN (SELECT target_id, MAX(id) AS id FROM check_results WHERE target_id IN (`+in+`) GROUP BY target_id) latest ON cr.id = latest.id
+		WHERE cr.status IN ('down', 'error') AND cr.error != ''`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var msg string
+		if err := rows.Scan(&id, &msg); err != nil {
+			return nil, err
+		}
+		m[id] = msg
+	}
+	return m, rows.Err()
+}
+
+// idList returns the placeholders and arguments of an IN list of ids.
+func idList(ids []int64) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "), args
+}
+
+// ListTargetsByTag returns targets that have the specified tag.
+func (s *sqlStore) ListTargetsByTag(tag string) ([]Target, error) {
+	rows, err := s.query(
+		`SELECT `+prefixColumns("t", targetColumns)+`
+		FROM targets t INNER JOIN target_tags tt ON t.id = tt.target_id
+		WHERE tt.tag = ? ORDER BY t.id`, tag,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var targets []Target
+	for rows.Next() {
+		t, err := scanTarget(rows)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, *t)
+	}
+	return targets, nil
+}
+
+func (s *sqlStore) AcquireCheckLease(targetID int64, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	// Insert a fresh lease, or take over one that has expired or is already ours.
+	res, err := s.exec(
+		`INSERT INTO check_leases (target_id, holder, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (target_id) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE check_leases.expires_at <= ? OR check_leases.holder = excluded.holder`,
+		targetID, holder, now.Add(ttl).Unix(), now.Unix(),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
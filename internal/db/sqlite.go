@@ -0,0 +1,445 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteParams configure every connection to the database, so the daemon
+// and CLI commands can use it at once: WAL lets readers run alongside a
+// writer, a connection finding the database locked waits up to the busy
+// timeout (in milliseconds) for it, and transactions take the write lock
+// when they begin rather than failing to upgrade a read lock midway.
+const sqliteParams = "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+
+// OpenSQLite opens (creating if needed) the sqlite database at path and
+// brings its schema up to date.
+func OpenSQLite(path string) (Store, error) {
+	conn, err := sql.Open("sqlite", path+sqliteParams)
+	if err != nil {
+		return nil, err
+	}
+	s := &sqlStore{db: conn, rebind: func(q string) string { return q }, dialect: &sqliteDialect}
+	if err := s.open(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+var sqliteDialect = dialect{
+	name: "sqlite",
+	migrationsTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		name TEXT PRIMARY KEY,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	record: "INSERT OR IGNORE INTO schema_migrations (name) VALUES (?)",
+	busy:   sqliteBusy,
+}
+
+// sqliteBusy reports whether err is SQLITE_BUSY or SQLITE_LOCKED, which
+// another connection holding a lock causes and a later attempt may not.
+func sqliteBusy(err error) bool {
+	var serr *sqlite.Error
+	if !errors.As(err, &serr) {
+		return false
+	}
+	// Extended result codes keep the primary code in the low byte
+	switch serr.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return true
+	}
+	return false
+}
+
+// sqliteBaseSchema creates the schema as of the first versioned migration
+// and applies the column migrations of databases created before that.
+func sqliteBaseSchema(db sqlConn) error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS targets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		url TEXT NOT NULL,
+		type TEXT NOT NULL DEFAULT 'http',
+		interval_seconds INTEGER NOT NULL DEFAULT 300,
+		selector TEXT DEFAULT '',
+		headers TEXT DEFAULT '',
+		expect TEXT DEFAULT '',
+		timeout INTEGER DEFAULT 30,
+		retries INTEGER DEFAULT 0,
+		threshold REAL DEFAULT 5.0,
+		trigger_rule TEXT DEFAULT '',
+		jq_filter TEXT DEFAULT '',
+		method TEXT DEFAULT '',
+		body TEXT DEFAULT '',
+		no_follow INTEGER DEFAULT 0,
+		accept_status TEXT DEFAULT '',
+		insecure INTEGER DEFAULT 0,
+		hash_headers TEXT DEFAULT '',
+		expect_content_type TEXT DEFAULT '',
+		soft_down_keywords TEXT DEFAULT '',
+		cert_pin TEXT DEFAULT '',
+		alert_cert_change INTEGER DEFAULT 0,
+		expect_min_tls TEXT DEFAULT '',
+		escalation TEXT DEFAULT '',
+		notify_on_recovery INTEGER DEFAULT 0,
+		max_total_time INTEGER DEFAULT 0,
+		stream_mode INTEGER DEFAULT 0,
+		read_bytes INTEGER DEFAULT 0,
+		quorum INTEGER DEFAULT 0,
+		change_threshold REAL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		paused INTEGER DEFAULT 0,
+		muted INTEGER DEFAULT 0,
+		UNIQUE(url, type, selector)
+	);
+
+	CREATE TABLE IF NOT EXISTS check_results (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		target_id INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		status_code INTEGER DEFAULT 0,
+		response_time_ms INTEGER DEFAULT 0,
+		content_hash TEXT DEFAULT '',
+		content_type TEXT DEFAULT '',
+		dns_ms INTEGER DEFAULT 0,
+		connect_ms INTEGER DEFAULT 0,
+		tls_ms INTEGER DEFAULT 0,
+		first_byte_ms INTEGER DEFAULT 0,
+		cert_fingerprint TEXT DEFAULT '',
+		tls_version TEXT DEFAULT '',
+		tls_cipher TEXT DEFAULT '',
+		tls_chain_valid INTEGER DEFAULT 0,
+		attempt_errors TEXT DEFAULT '',
+		request_bytes INTEGER DEFAULT 0,
+		response_bytes INTEGER DEFAULT 0,
+		retry_after_ms INTEGER DEFAULT 0,
+		error TEXT DEFAULT '',
+		checked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		target_id INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		hash TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		compressed INTEGER DEFAULT 0,
+		content_gz BLOB,
+		size INTEGER DEFAULT 0,
+		signature TEXT DEFAULT '',
+		FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS notify_configs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		type TEXT NOT NULL,
+		config TEXT NOT NULL,
+		enabled INTEGER DEFAULT 1
+	);
+
+	CREATE TABLE IF NOT EXISTS target_tags (
+		target_id INTEGER NOT NULL,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (target_id, tag),
+		FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS check_leases (
+		target_id INTEGER PRIMARY KEY,
+		holder TEXT NOT NULL,
+		expires_at INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS incidents (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		target_id INTEGER NOT NULL,
+		started_at DATETIME NOT NULL,
+		resolved_at DATETIME,
+		steps_sent INTEGER DEFAULT 0,
+		error TEXT DEFAULT '',
+		FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_incidents_open ON incidents(target_id, resolved_at);
+	CREATE INDEX IF NOT EXISTS idx_results_target ON check_results(target_id, checked_at);
+	CREATE INDEX IF NOT EXISTS idx_snapshots_target ON snapshots(target_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_target_tags ON target_tags(tag);
+	`
+	_, err := db.Exec(schema)
+	if err != nil {
+		return err
+	}
+
+	// Migration: Add threshold column if it doesn't exist (for existing databases)
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN threshold REAL DEFAULT 5.0")
+	if err != nil {
+		// Column might already exist, which is fine
+		// SQLite returns an error if column already exists
+		if !strings.Contains(err.Error(), "duplicate column name") {
+			// If it's not a duplicate column error, something else went wrong
+			return err
+		}
+	}
+
+	// Migration: Add trigger_rule column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN trigger_rule TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add jq_filter column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN jq_filter TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add method column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN method TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add body column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN body TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add no_follow column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN no_follow INTEGER DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add accept_status column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN accept_status TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add insecure column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN insecure INTEGER DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add hash_headers column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN hash_headers TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add expect_content_type column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN expect_content_type TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add content_type column to check results
+	_, err = db.Exec("ALTER TABLE check_results ADD COLUMN content_type TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add soft_down_keywords column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN soft_down_keywords TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add timing breakdown columns to check results
+	for _, col := range []string{"dns_ms", "connect_ms", "tls_ms", "first_byte_ms"} {
+		_, err = db.Exec("ALTER TABLE check_results ADD COLUMN " + col + " INTEGER DEFAULT 0")
+		if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return err
+		}
+	}
+
+	// Migration: Add cert_fingerprint column to check results
+	_, err = db.Exec("ALTER TABLE check_results ADD COLUMN cert_fingerprint TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add cert_pin column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN cert_pin TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add alert_cert_change column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN alert_cert_change INTEGER DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add tls_version column to check results
+	_, err = db.Exec("ALTER TABLE check_results ADD COLUMN tls_version TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add tls_cipher column to check results
+	_, err = db.Exec("ALTER TABLE check_results ADD COLUMN tls_cipher TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add tls_chain_valid column to check results
+	_, err = db.Exec("ALTER TABLE check_results ADD COLUMN tls_chain_valid INTEGER DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add expect_min_tls column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN expect_min_tls TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add attempt_errors column to check results
+	_, err = db.Exec("ALTER TABLE check_results ADD COLUMN attempt_errors TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add request_bytes column to check results
+	_, err = db.Exec("ALTER TABLE check_results ADD COLUMN request_bytes INTEGER DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add response_bytes column to check results
+	_, err = db.Exec("ALTER TABLE check_results ADD COLUMN response_bytes INTEGER DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add retry_after_ms column to check results
+	_, err = db.Exec("ALTER TABLE check_results ADD COLUMN retry_after_ms INTEGER DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add muted column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN muted INTEGER DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add snapshot compression columns
+	for _, stmt := range []string{
+		"ALTER TABLE snapshots ADD COLUMN compressed INTEGER DEFAULT 0",
+		"ALTER TABLE snapshots ADD COLUMN content_gz BLOB",
+		"ALTER TABLE snapshots ADD COLUMN size INTEGER DEFAULT 0",
+	} {
+		_, err = db.Exec(stmt)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return err
+		}
+	}
+
+	// Migration: Add escalation column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN escalation TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add notify_on_recovery column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN notify_on_recovery INTEGER DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add max_total_time column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN max_total_time INTEGER DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add stream_mode column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN stream_mode INTEGER DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add read_bytes column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN read_bytes INTEGER DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add quorum column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN quorum INTEGER DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add snapshot signature column
+	_, err = db.Exec("ALTER TABLE snapshots ADD COLUMN signature TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add change_threshold column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN change_threshold REAL DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Update unique constraint from (url, selector) to (url, type, selector)
+	// SQLite can't alter constraints, so we recreate the table
+	var tableSql string
+	db.QueryRow("SELECT sql FROM sqlite_master WHERE type='table' AND name='targets'").Scan(&tableSql)
+	if strings.Contains(tableSql, "UNIQUE(url, selector)") && !strings.Contains(tableSql, "UNIQUE(url, type, selector)") {
+		db.Exec(`CREATE TABLE targets_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			url TEXT NOT NULL,
+			type TEXT NOT NULL DEFAULT 'http',
+			interval_seconds INTEGER NOT NULL DEFAULT 300,
+			selector TEXT DEFAULT '',
+			headers TEXT DEFAULT '',
+			expect TEXT DEFAULT '',
+			timeout INTEGER DEFAULT 30,
+			retries INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			paused INTEGER DEFAULT 0,
+			threshold REAL DEFAULT 5.0,
+			trigger_rule TEXT DEFAULT '',
+			jq_filter TEXT DEFAULT '',
+			method TEXT DEFAULT '',
+			body TEXT DEFAULT '',
+			no_follow INTEGER DEFAULT 0,
+			accept_status TEXT DEFAULT '',
+			insecure INTEGER DEFAULT 0,
+			hash_headers TEXT DEFAULT '',
+			expect_content_type TEXT DEFAULT '',
+			soft_down_keywords TEXT DEFAULT '',
+			cert_pin TEXT DEFAULT '',
+			alert_cert_change INTEGER DEFAULT 0,
+			expect_min_tls TEXT DEFAULT '',
+			muted INTEGER DEFAULT 0,
+			escalation TEXT DEFAULT '',
+			notify_on_recovery INTEGER DEFAULT 0,
+			max_total_time INTEGER DEFAULT 0,
+			stream_mode INTEGER DEFAULT 0,
+			read_bytes INTEGER DEFAULT 0,
+			quorum INTEGER DEFAULT 0,
+			change_threshold REAL DEFAULT 0,
+			UNIQUE(url, type, selector)
+		)`)
+		db.Exec(`INSERT INTO targets_new SELECT * FROM targets`)
+		db.Exec(`DROP TABLE targets`)
+		db.Exec(`ALTER TABLE targets_new RENAME TO targets`)
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/naru-bot/upp/internal/unixurl"
+	"github.com/naru-bot/upp/internal/urltemplate"
+)
+
+// ValidateTarget checks that t's URL has the shape its check type expects
+// and runs ValidateOptions, so a mistyped target is rejected up front
+// instead of failing on every check.
+func ValidateTarget(t *Target) error {
+	if err := validateAddress(t.Type, t.URL); err != nil {
+		return err
+	}
+	return ValidateOptions(t)
+}
+
+// ValidateOptions checks the options that would otherwise never fire or
+// never pass, leaving t's URL alone: an expect-absent keyword and score
+// rules need an http target, a score warn level must be above the down
+// level, and a HEAD-only check can't use an option that reads the body.
+// Other options a type ignores aren't checked.
+func ValidateOptions(t *Target) error {
+	if t.ExpectAbsent != "" && t.Type != "" && t.Type != "http" && t.Type != "https" {
+		return fmt.Errorf("an expect-absent keyword only works with http targets, not %s", t.Type)
+	}
+	if len(t.ScoreRules) > 0 {
+		if t.Type != "" && t.Type != "http" && t.Type != "https" {
+			return fmt.Errorf("score rules only work with http targets, not %s", t.Type)
+		}
+		// 0 leaves the warn level unset
+		if t.ScoreWarn != 0 && t.ScoreWarn <= t.ScoreMin {
+			return fmt.Errorf("the score warn level (%d) must be above the down level (%d), or a check is down before it warns", t.ScoreWarn, t.ScoreMin)
+		}
+	}
+	if t.HeadOnly {
+		if opt := bodyOption(t); opt != "" {
+			return fmt.Errorf("a HEAD-only check reads no body, so it can't use %s", opt)
+		}
+	}
+	return nil
+}
+
+// bodyOption names the first option of t that needs the response body,
+// or returns "".
+func bodyOption(t *Target) string {
+	switch {
+	case t.Expect != "":
+		return "an expect keyword"
+	case t.ExpectAbsent != "":
+		return "an expect-absent keyword"
+	case t.Selector != "":
+		return "a selector"
+	case t.JQFilter != "":
+		return "a jq filter"
+	case t.JSONPath != "":
+		return "a JSON path"
+	case t.ExpectHash != "":
+		return "an expect hash"
+	case len(t.ScoreRules) > 0:
+		return "score rules"
+	case t.FailOnEmpty:
+		return "fail-on-empty"
+	}
+	return ""
+}
+
+// validateAddress checks that rawURL has the shape the check type expects.
+func validateAddress(typ, rawURL string) error {
+	if strings.TrimSpace(rawURL) == "" {
+		return fmt.Errorf("URL is required")
+	}
+
+	if typ == "composite" {
+		_, err := CompositeMembers(rawURL)
+		return err
+	}
+
+	if urltemplate.Has(rawURL) {
+		if typ != "" && typ != "http" && typ != "https" {
+			return fmt.Errorf("${...} URL templates only work with http targets, not %s", typ)
+		}
+		expanded, err := urltemplate.Sample(rawURL)
+		if err != nil {
+			return fmt.Errorf("invalid URL template: %w", err)
+		}
+		rawURL = expanded
+	}
+
+	if unixurl.Is(rawURL) {
+		if typ != "" && typ != "http" && typ != "https" {
+			return fmt.Errorf("%s URLs only work with http targets, not %s", unixurl.Scheme, typ)
+		}
+		socket, _, err := unixurl.Split(rawURL)
+		if err != nil {
+			return err
+		}
+		fi, err := os.Stat(socket)
+		if err != nil {
+			return fmt.Errorf("unix socket %s: %w", socket, errors.Unwrap(err))
+		}
+		if fi.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("%s is not a unix socket", socket)
+		}
+		return nil
+	}
+
+	switch typ {
+	case "", "http", "https", "visual", "statuspage":
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("invalid URL %q: %v", rawURL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			if u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("%s targets need a full URL with scheme, e.g. https://%s", typeLabel(typ), strings.TrimPrefix(rawURL, "//"))
+			}
+			return fmt.Errorf("%s targets need an http:// or https:// URL, got scheme %q", typeLabel(typ), u.Scheme)
+		}
+		if u.Hostname() == "" {
+			return fmt.Errorf("URL %q has no host", rawURL)
+		}
+	case "tcp":
+		if strings.Contains(rawURL, "://") {
+			hint := "host:port"
+			if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+				hint = u.Host
+				if u.Port() == "" {
+					hint += ":<port>"
+				}
+			}
+			return fmt.Errorf("tcp targets take host:port, not a URL (try %s)", hint)
+		}
+		host, port, err := net.SplitHostPort(rawURL)
+		if err != nil {
+			return fmt.Errorf("tcp targets take host:port, e.g. db.example.com:5432 (got %q)", rawURL)
+		}
+		if err := validateHost(host); err != nil {
+			return fmt.Errorf("tcp target: %w", err)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("tcp target: invalid port %q (want 1-65535)", port)
+		}
+	case "ping", "tcp-ping", "dns":
+		if strings.Contains(rawURL, "://") {
+			hint := "a hostname"
+			if u, err := url.Parse(rawURL); err == nil && u.Hostname() != "" {
+				hint = u.Hostname()
+			}
+			return fmt.Errorf("%s targets take a bare host, not a URL (try %s)", typ, hint)
+		}
+		if host, _, err := net.SplitHostPort(rawURL); err == nil {
+			return fmt.Errorf("%s targets take a bare host without a port (try %s)", typ, host)
+		}
+		if err := validateHost(rawURL); err != nil {
+			return fmt.Errorf("%s target: %w", typ, err)
+		}
+	case "whois":
+		// Accepts a bare domain or a full URL; the checker extracts the domain.
+		host := rawURL
+		if strings.Contains(host, "://") {
+			u, err := url.Parse(host)
+			if err != nil {
+				return fmt.Errorf("invalid URL %q: %v", rawURL, err)
+			}
+			host = u.Hostname()
+		}
+		if err := validateHost(host); err != nil {
+			return fmt.Errorf("whois target: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown check type %q (want http, tcp, ping, tcp-ping, dns, visual, whois, statuspage or composite)", typ)
+	}
+	return nil
+}
+
+// validateHost accepts a hostname or IP literal without port, path or
+// whitespace. A leading dash is rejected since the host is passed to ping.
+func validateHost(host string) error {
+	if host == "" {
+		return fmt.Errorf("host is empty")
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if strings.HasPrefix(host, "-") || strings.ContainsAny(host, " \t/:?#@") {
+		return fmt.Errorf("invalid host %q (want a bare hostname or IP, e.g. example.com)", host)
+	}
+	return nil
+}
+
+func typeLabel(typ string) string {
+	if typ == "" {
+		return "http"
+	}
+	return typ
+}
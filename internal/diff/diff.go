@@ -93,6 +93,44 @@ func FormatPlain(d *DiffResult) string {
 	return sb.String()
 }
 
+// maxAddedLines bounds the lines AddedLines diffs with LCS, which takes
+// memory for their product; longer content falls back to counting lines.
+const maxAddedLines = 4000
+
+// AddedLines returns the lines of newContent that Diff reports as added
+// relative to oldContent, in order. Content too long for an LCS diff is
+// compared as multisets of lines instead: a line is added when it occurs
+// more often than before, so moved lines don't count.
+func AddedLines(oldContent, newContent string) []string {
+	if oldContent == newContent {
+		return nil
+	}
+	oldLines := strings.Split(oldContent, "\n")
+	newLines := strings.Split(newContent, "\n")
+	var added []string
+	if len(oldLines)+len(newLines) <= maxAddedLines {
+		lcs := lcsMatrix(oldLines, newLines)
+		for _, c := range backtrack(lcs, oldLines, newLines, len(oldLines), len(newLines)) {
+			if c.Type == "added" {
+				added = append(added, c.Line)
+			}
+		}
+		return added
+	}
+	seen := make(map[string]int, len(oldLines))
+	for _, l := range oldLines {
+		seen[l]++
+	}
+	for _, l := range newLines {
+		if seen[l] > 0 {
+			seen[l]--
+			continue
+		}
+		added = append(added, l)
+	}
+	return added
+}
+
 func lcsMatrix(a, b []string) [][]int {
 	m := len(a)
 	n := len(b)
@@ -0,0 +1,41 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAddedLines(t *testing.T) {
+	// Long enough to skip the LCS diff and compare line counts instead
+	long := make([]string, maxAddedLines)
+	for i := range long {
+		long[i] = fmt.Sprintf("line %d", i)
+	}
+	longContent := strings.Join(long, "\n")
+	moved := strings.Join(append(slices.Clone(long[1:]), long[0]), "\n")
+
+	tests := []struct {
+		name     string
+		old, new string
+		want     []string
+	}{
+		{"identical", "a\nb", "a\nb", nil},
+		{"appended", "a\nb", "a\nb\nc", []string{"c"}},
+		{"replaced", "a\nb\nc", "a\nB\nc", []string{"B"}},
+		{"removed only", "a\nb\nc", "a\nc", nil},
+		{"from empty", "", "a\nb", []string{"a", "b"}},
+		{"moved line is added to LCS", "a\nb\nc", "b\nc\na", []string{"a"}},
+		{"moved line is not added to long content", longContent, moved, nil},
+		{"repeated line in long content", longContent, longContent + "\nline 7", []string{"line 7"}},
+		{"new line in long content", longContent, "fresh\n" + longContent, []string{"fresh"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AddedLines(tt.old, tt.new); !slices.Equal(got, tt.want) {
+				t.Errorf("AddedLines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
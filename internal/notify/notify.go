@@ -3,25 +3,154 @@ package notify
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os/exec"
 	"strings"
+	"text/template"
 	"time"
 )
 
 type Event struct {
-	Target   string `json:"target"`
-	URL      string `json:"url"`
-	Status   string `json:"status"`
-	OldHash  string `json:"old_hash,omitempty"`
-	NewHash  string `json:"new_hash,omitempty"`
-	Error    string `json:"error,omitempty"`
-	Time     string `json:"time"`
-	Message  string `json:"message"`
+	Target     string            `json:"target"`
+	URL        string            `json:"url"`
+	Type       string            `json:"type,omitempty"` // check type of the target
+	Status     string            `json:"status"`
+	PrevStatus string            `json:"prev_status,omitempty"` // status of the check before this one
+	StatusCode int               `json:"status_code,omitempty"`
+	ResponseMs int64             `json:"response_time_ms,omitempty"`
+	Downtime   string            `json:"downtime,omitempty"` // how long the target has been (or was) down, e.g. "12m"
+	OldHash    string            `json:"old_hash,omitempty"`
+	NewHash    string            `json:"new_hash,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Tags       []string          `json:"tags,omitempty"`      // tags of the target
+	Severity   string            `json:"severity,omitempty"`  // info, warning or critical
+	Meta       map[string]string `json:"meta,omitempty"`      // the target's key/value context, e.g. owner, runbook
+	Summary    string            `json:"summary,omitempty"`   // state of all targets, for summary events
+	Up         int               `json:"up,omitempty"`        // summary events: targets up
+	Down       []string          `json:"down,omitempty"`      // summary events: names of the targets down
+	Unchecked  int               `json:"unchecked,omitempty"` // summary events: targets not checked yet
+	Time       string            `json:"time"`
+	Message    string            `json:"message"`
 }
 
+// DefaultTemplate renders Message for channels without their own template.
+// The target's meta follows the message, in key order.
+const DefaultTemplate = `[upp] {{.Target}} ({{.URL}}) is {{.Status}}{{with .Error}}: {{.}}{{end}}{{range $k, $v := .Meta}} | {{$k}}: {{$v}}{{end}}`
+
+// DefaultSummaryTemplate renders Message for summary events on channels
+// without their own summary_template. Summary events are about no single
+// target, so they never go through a channel's template.
+const DefaultSummaryTemplate = `[upp] {{.Summary}}`
+
+// channelOptions are the config keys shared by every channel type.
+type channelOptions struct {
+	Template        string `json:"template"`
+	SummaryTemplate string `json:"summary_template"`
+	MinSeverity     string `json:"min_severity"` // skip events from targets below this severity
+}
+
+// sampleEvent is rendered when a channel is configured, so template errors
+// that only show up on execution (like a misspelled field) surface then.
+var sampleEvent = Event{
+	Target: "My Site", URL: "https://example.com", Type: "http",
+	Status: "down", PrevStatus: "up", StatusCode: 503, ResponseMs: 120,
+	Downtime: "5m", Error: "HTTP 503", Tags: []string{"prod"}, Severity: SeverityCritical, Time: "2006-01-02T15:04:05Z",
+	Meta: map[string]string{"owner": "team-a", "runbook": "https://wiki.example.com/runbooks/my-site"},
+}
+
+// sampleSummary is rendered to check a channel's summary_template.
+var sampleSummary = Event{
+	Status: "summary", Summary: "18 up, 2 down: api, db (1 not checked yet)",
+	Up: 18, Down: []string{"api", "db"}, Unchecked: 1, Time: "2006-01-02T15:04:05Z",
+}
+
+// TestEvent is what 'upp notify test' sends: the sample event, with the
+// status "test" and the current time.
+func TestEvent() Event {
+	ev := sampleEvent
+	ev.Status, ev.PrevStatus = "test", ""
+	ev.StatusCode, ev.Downtime = 0, ""
+	ev.Error = "test notification from upp"
+	ev.Time = time.Now().UTC().Format(time.RFC3339)
+	return ev
+}
+
+// ValidateConfig checks a channel's JSON config, including its message
+// template, before a channel of type typ is saved.
+func ValidateConfig(typ, configJSON string) error {
+	var opts channelOptions
+	if err := json.Unmarshal([]byte(configJSON), &opts); err != nil {
+		return fmt.Errorf("invalid JSON config: %w", err)
+	}
+	if err := ValidateSeverity(strings.ToLower(opts.MinSeverity)); err != nil {
+		return fmt.Errorf("min_severity: %w", err)
+	}
+	switch typ {
+	case "opsgenie":
+		if err := validateOpsgenie(configJSON); err != nil {
+			return err
+		}
+	case "teams":
+		if err := validateTeams(configJSON); err != nil {
+			return err
+		}
+	}
+	if opts.Template != "" {
+		if _, err := render(opts.Template, sampleEvent); err != nil {
+			return err
+		}
+	}
+	if opts.SummaryTemplate != "" {
+		if _, err := render(opts.SummaryTemplate, sampleSummary); err != nil {
+			return fmt.Errorf("summary_template: %w", err)
+		}
+	}
+	return nil
+}
+
+// render executes a message template. A meta key the target doesn't have,
+// like {{.Meta.owner}}, renders empty.
+func render(text string, event Event) (string, error) {
+	tmpl, err := template.New("message").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("message template: %w", err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, event); err != nil {
+		return "", fmt.Errorf("message template: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// Send delivers event through one channel. Message is rendered from the
+// channel's template, or DefaultTemplate; summary events use its
+// summary_template, or DefaultSummaryTemplate. A template that fails to
+// render falls back to the default message, and its error is returned once
+// the notification went out. Events below the channel's min_severity are
+// dropped.
 func Send(typ, config string, event Event) error {
+	var opts channelOptions
+	json.Unmarshal([]byte(config), &opts)
+	if !passesMinSeverity(event, opts.MinSeverity) {
+		return nil
+	}
+	tmpl, fallback := opts.Template, DefaultTemplate
+	if event.Status == "summary" {
+		tmpl, fallback = opts.SummaryTemplate, DefaultSummaryTemplate
+	}
+	var tmplErr error
+	if tmpl != "" {
+		event.Message, tmplErr = render(tmpl, event)
+	}
+	if tmpl == "" || tmplErr != nil {
+		event.Message, _ = render(fallback, event)
+	}
+	return errors.Join(send(typ, config, event), tmplErr)
+}
+
+func send(typ, config string, event Event) error {
 	switch typ {
 	case "webhook":
 		return sendWebhook(config, event)
@@ -33,6 +162,10 @@ func Send(typ, config string, event Event) error {
 		return sendTelegram(config, event)
 	case "discord":
 		return sendDiscord(config, event)
+	case "opsgenie":
+		return sendOpsgenie(config, event)
+	case "teams":
+		return sendTeams(config, event)
 	default:
 		return fmt.Errorf("unknown notification type: %s", typ)
 	}
@@ -70,6 +203,7 @@ func sendCommand(configJSON string, event Event) error {
 	cmdStr = strings.ReplaceAll(cmdStr, "{target}", event.Target)
 	cmdStr = strings.ReplaceAll(cmdStr, "{url}", event.URL)
 	cmdStr = strings.ReplaceAll(cmdStr, "{status}", event.Status)
+	cmdStr = strings.ReplaceAll(cmdStr, "{severity}", event.Severity)
 	cmdStr = strings.ReplaceAll(cmdStr, "{message}", event.Message)
 
 	cmd := exec.Command("sh", "-c", cmdStr)
@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// opsgenieConfig is the config of an opsgenie channel.
+type opsgenieConfig struct {
+	APIKey string `json:"api_key"`
+	Region string `json:"region"` // "us" (default) or "eu"
+}
+
+// opsgenieHosts are the Alert API endpoints of each Opsgenie region.
+var opsgenieHosts = map[string]string{
+	"":   "https://api.opsgenie.com",
+	"us": "https://api.opsgenie.com",
+	"eu": "https://api.eu.opsgenie.com",
+}
+
+// ClosesAlerts reports whether channels of type typ keep alerts open until
+// a recovered event closes them, so they need that event even from targets
+// that don't set notify_on_recovery.
+func ClosesAlerts(typ string) bool {
+	return typ == "opsgenie"
+}
+
+func validateOpsgenie(configJSON string) error {
+	var cfg opsgenieConfig
+	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+		return fmt.Errorf("invalid JSON config: %w", err)
+	}
+	if cfg.APIKey == "" {
+		return fmt.Errorf("opsgenie config needs an api_key")
+	}
+	if _, ok := opsgenieHosts[strings.ToLower(cfg.Region)]; !ok {
+		return fmt.Errorf("unknown opsgenie region %q (want us or eu)", cfg.Region)
+	}
+	return nil
+}
+
+// opsgenieAlias keys an alert to its target, so Opsgenie folds repeated
+// alerts into the open one and a recovery closes it. Alerts other than
+// down and error get their own alias per status; a flapping alert is
+// closed when the target is stable again.
+func opsgenieAlias(event Event) string {
+	switch event.Status {
+	case "down", "error", "recovered":
+		return "upp:" + event.Target
+	case "stable":
+		return "upp:" + event.Target + ":flapping"
+	case "summary":
+		return "upp:summary"
+	}
+	return "upp:" + event.Target + ":" + event.Status
+}
+
+// sendOpsgenie creates an alert for the event, or closes the target's
+// alert when it recovered. The target's tags become the alert's tags, its
+// meta extra details and its severity the alert's priority.
+func sendOpsgenie(configJSON string, event Event) error {
+	var cfg opsgenieConfig
+	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+		return err
+	}
+	host, ok := opsgenieHosts[strings.ToLower(cfg.Region)]
+	if !ok {
+		return fmt.Errorf("unknown opsgenie region %q (want us or eu)", cfg.Region)
+	}
+	alias := opsgenieAlias(event)
+
+	var endpoint string
+	var payload map[string]any
+	if event.Status == "recovered" || event.Status == "stable" {
+		endpoint = host + "/v2/alerts/" + url.PathEscape(alias) + "/close?identifierType=alias"
+		payload = map[string]any{"source": "upp", "note": event.Message}
+	} else {
+		message := event.Message
+		if r := []rune(message); len(r) > 130 { // Opsgenie's limit
+			message = string(r[:129]) + "…"
+		}
+		details := map[string]string{
+			"url":    event.URL,
+			"type":   event.Type,
+			"status": event.Status,
+			"error":  event.Error,
+		}
+		for k, v := range event.Meta {
+			if _, ok := details[k]; !ok {
+				details[k] = v
+			}
+		}
+		payload = map[string]any{
+			"message":     message,
+			"alias":       alias,
+			"description": event.Message,
+			"source":      "upp",
+			"details":     details,
+		}
+		if len(event.Tags) > 0 {
+			payload["tags"] = event.Tags
+		}
+		if event.Target != "" {
+			payload["priority"] = opsgeniePriority(event.Severity)
+		}
+		endpoint = host + "/v2/alerts"
+	}
+
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "GenieKey "+cfg.APIKey)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("opsgenie returned %d", resp.StatusCode)
+	}
+	return nil
+}
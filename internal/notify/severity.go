@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Target severities, lowest first. A target without one is critical, so it
+// alerts everywhere.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+var severityRank = map[string]int{
+	SeverityInfo:     1,
+	SeverityWarning:  2,
+	SeverityCritical: 3,
+}
+
+// ValidateSeverity checks a target severity or a channel's min_severity.
+// Empty means critical for a target and no minimum for a channel.
+func ValidateSeverity(s string) error {
+	if _, ok := severityRank[s]; ok || s == "" {
+		return nil
+	}
+	return fmt.Errorf("unknown severity %q (want info, warning or critical)", s)
+}
+
+// EffectiveSeverity returns s, or critical when s is empty.
+func EffectiveSeverity(s string) string {
+	if s == "" {
+		return SeverityCritical
+	}
+	return s
+}
+
+// passesMinSeverity reports whether an event of severity s reaches a channel
+// with the given min_severity. Events without a target, like summaries,
+// always do.
+func passesMinSeverity(event Event, min string) bool {
+	if min == "" || event.Target == "" {
+		return true
+	}
+	return severityRank[EffectiveSeverity(event.Severity)] >= severityRank[strings.ToLower(min)]
+}
+
+// opsgeniePriority translates a severity into an Opsgenie priority.
+func opsgeniePriority(s string) string {
+	switch EffectiveSeverity(s) {
+	case SeverityInfo:
+		return "P5"
+	case SeverityWarning:
+		return "P3"
+	}
+	return "P1"
+}
@@ -0,0 +1,208 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// teamsConfig is the config of a teams channel.
+type teamsConfig struct {
+	WebhookURL string `json:"webhook_url"`
+	Format     string `json:"format"` // "adaptive" (default) or "messagecard"
+}
+
+const (
+	teamsMaxPayload = 28 << 10 // Teams rejects webhook payloads over about 28 KB
+	teamsTimeout    = 15 * time.Second
+	teamsAttempts   = 3
+	teamsRetryDelay = 2 * time.Second
+)
+
+func validateTeams(configJSON string) error {
+	var cfg teamsConfig
+	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+		return fmt.Errorf("invalid JSON config: %w", err)
+	}
+	if cfg.WebhookURL == "" {
+		return fmt.Errorf("teams config needs a webhook_url")
+	}
+	switch strings.ToLower(cfg.Format) {
+	case "", "adaptive", "messagecard":
+		return nil
+	}
+	return fmt.Errorf("unknown teams format %q (want adaptive or messagecard)", cfg.Format)
+}
+
+// teamsStyle returns the Adaptive Card container style and the MessageCard
+// theme color that show the event's status.
+func teamsStyle(status string) (style, color string) {
+	switch status {
+	case "down", "error":
+		return "attention", "D9534F"
+	case "changed", "redirect", "cert-changed", "ip-changed", "flapping", "slow":
+		return "warning", "F0AD4E"
+	case "recovered", "stable", "up":
+		return "good", "5CB85C"
+	}
+	return "emphasis", "0078D7"
+}
+
+// teamsTitle is the card header, e.g. "api is down".
+func teamsTitle(event Event) string {
+	if event.Status == "summary" {
+		return "upp summary"
+	}
+	return fmt.Sprintf("%s is %s", event.Target, event.Status)
+}
+
+// teamsFacts lists the target details shown under the message.
+func teamsFacts(event Event) [][2]string {
+	var facts [][2]string
+	add := func(name, value string) {
+		if value != "" {
+			facts = append(facts, [2]string{name, value})
+		}
+	}
+	add("URL", event.URL)
+	add("Type", event.Type)
+	if event.PrevStatus != "" {
+		add("Status", event.PrevStatus+" → "+event.Status)
+	} else {
+		add("Status", event.Status)
+	}
+	if event.StatusCode != 0 {
+		add("HTTP status", fmt.Sprint(event.StatusCode))
+	}
+	if event.ResponseMs != 0 {
+		add("Response time", fmt.Sprintf("%dms", event.ResponseMs))
+	}
+	add("Severity", event.Severity)
+	add("Down for", event.Downtime)
+	add("Tags", strings.Join(event.Tags, ", "))
+	for _, k := range slices.Sorted(maps.Keys(event.Meta)) {
+		add(k, event.Meta[k])
+	}
+	add("Time", event.Time)
+	return facts
+}
+
+func teamsAdaptiveCard(event Event, text string) any {
+	style, _ := teamsStyle(event.Status)
+	var facts []map[string]string
+	for _, f := range teamsFacts(event) {
+		facts = append(facts, map[string]string{"title": f[0], "value": f[1]})
+	}
+	body := []any{
+		map[string]any{
+			"type":  "Container",
+			"style": style,
+			"bleed": true,
+			"items": []any{map[string]any{
+				"type": "TextBlock", "text": teamsTitle(event),
+				"weight": "Bolder", "size": "Medium", "wrap": true,
+			}},
+		},
+		map[string]any{"type": "TextBlock", "text": text, "wrap": true},
+	}
+	if event.Status != "summary" && len(facts) > 0 {
+		body = append(body, map[string]any{"type": "FactSet", "facts": facts})
+	}
+	return map[string]any{
+		"type": "message",
+		"attachments": []any{map[string]any{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]any{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"msteams": map[string]string{"width": "Full"},
+				"body":    body,
+			},
+		}},
+	}
+}
+
+func teamsMessageCard(event Event, text string) any {
+	_, color := teamsStyle(event.Status)
+	card := map[string]any{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"themeColor": color,
+		"summary":    teamsTitle(event),
+		"title":      teamsTitle(event),
+		"text":       text,
+	}
+	if event.Status != "summary" {
+		var facts []map[string]string
+		for _, f := range teamsFacts(event) {
+			facts = append(facts, map[string]string{"name": f[0], "value": f[1]})
+		}
+		card["sections"] = []any{map[string]any{"facts": facts}}
+	}
+	return card
+}
+
+// teamsPayload builds the card for event. A message too long for Teams'
+// size limit is cut to the longest prefix that fits.
+func teamsPayload(cfg teamsConfig, event Event) []byte {
+	build := func(text string) []byte {
+		var card any
+		if strings.EqualFold(cfg.Format, "messagecard") {
+			card = teamsMessageCard(event, text)
+		} else {
+			card = teamsAdaptiveCard(event, text)
+		}
+		body, _ := json.Marshal(card)
+		return body
+	}
+	body := build(event.Message)
+	if len(body) <= teamsMaxPayload {
+		return body
+	}
+	text := []rune(event.Message)
+	n := sort.Search(len(text), func(n int) bool {
+		return len(build(string(text[:n+1])+"…")) > teamsMaxPayload
+	})
+	return build(string(text[:n]) + "…")
+}
+
+// sendTeams posts the event as a card to a Teams incoming webhook. Teams
+// webhooks are sometimes slow, so a timed-out, throttled or failed post is
+// retried.
+func sendTeams(configJSON string, event Event) error {
+	var cfg teamsConfig
+	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+		return err
+	}
+	body := teamsPayload(cfg, event)
+	client := &http.Client{Timeout: teamsTimeout}
+	var err error
+	for attempt := 1; attempt <= teamsAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(teamsRetryDelay)
+		}
+		var resp *http.Response
+		resp, err = client.Post(cfg.WebhookURL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			continue
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		if resp.StatusCode < 400 {
+			return nil
+		}
+		err = fmt.Errorf("teams returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return err
+		}
+	}
+	return err
+}
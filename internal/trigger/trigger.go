@@ -4,18 +4,40 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/naru-bot/upp/internal/diff"
 )
 
 // Rule defines a trigger condition for notifications.
 type Rule struct {
-	Type  string `json:"type"`  // contains, not_contains, regex, not_regex
-	Value string `json:"value"` // text or regex pattern
+	Type  string `json:"type"`            // contains, not_contains, regex, not_regex, response_time_regression
+	Value string `json:"value"`           // text or regex pattern; for response_time_regression an optional multiplier
+	Scope string `json:"scope,omitempty"` // what a content rule looks at: content (default) or diff
 }
 
+// Scopes of a content rule: the whole content, or only the lines a change
+// added to it.
+const (
+	ScopeContent = "content"
+	ScopeDiff    = "diff"
+)
+
+// ResponseTimeRegression is the rule type that alerts when a check is much
+// slower than the target's recent median, rather than on its content.
+const ResponseTimeRegression = "response_time_regression"
+
 // ParseShorthand parses "type:value" shorthand into a JSON rule string.
 // e.g. "contains:out of stock" → {"type":"contains","value":"out of stock"}
+// response_time_regression takes an optional multiplier:
+// "response_time_regression" or "response_time_regression:3".
 func ParseShorthand(input string) (string, error) {
+	if input == ResponseTimeRegression {
+		input += ":"
+	}
 	idx := strings.Index(input, ":")
 	if idx < 0 {
 		return "", fmt.Errorf("invalid trigger rule: expected 'type:value' (e.g. 'contains:some text')")
@@ -26,8 +48,16 @@ func ParseShorthand(input string) (string, error) {
 
 	switch typ {
 	case "contains", "not_contains", "regex", "not_regex":
+	case ResponseTimeRegression:
+		if val != "" {
+			if m, err := strconv.ParseFloat(val, 64); err != nil || m <= 1 {
+				return "", fmt.Errorf("response_time_regression multiplier must be a number above 1, got %q", val)
+			}
+		}
+		b, _ := json.Marshal(Rule{Type: typ, Value: val})
+		return string(b), nil
 	default:
-		return "", fmt.Errorf("unknown trigger type %q (valid: contains, not_contains, regex, not_regex)", typ)
+		return "", fmt.Errorf("unknown trigger type %q (valid: contains, not_contains, regex, not_regex, response_time_regression)", typ)
 	}
 
 	if val == "" {
@@ -47,7 +77,8 @@ func ParseShorthand(input string) (string, error) {
 }
 
 // Evaluate checks whether the trigger condition is met for the given content.
-// Returns true if the notification should fire.
+// Returns true if the notification should fire. A response_time_regression
+// rule doesn't look at content, so it always fires; see Regressed.
 func Evaluate(ruleJSON string, content string) (bool, error) {
 	if ruleJSON == "" {
 		return true, nil
@@ -75,11 +106,109 @@ func Evaluate(ruleJSON string, content string) (bool, error) {
 			return true, fmt.Errorf("invalid regex: %w", err)
 		}
 		return !re.MatchString(content), nil
+	case ResponseTimeRegression:
+		return true, nil
 	default:
 		return true, fmt.Errorf("unknown trigger type: %s", r.Type)
 	}
 }
 
+// EvaluateChange is Evaluate for a changed check: a rule scoped to the diff
+// is evaluated against only the lines content added to previous, so it
+// fires on what changed rather than on what the page has always said.
+// With no previous content to compare (a first or down check), or for a
+// content-scoped rule, it is Evaluate.
+func EvaluateChange(ruleJSON, content, previous string) (bool, error) {
+	if previous != "" && Scope(ruleJSON) == ScopeDiff {
+		content = strings.Join(diff.AddedLines(previous, content), "\n")
+	}
+	return Evaluate(ruleJSON, content)
+}
+
+// Scope returns what a rule is evaluated against: ScopeDiff, or
+// ScopeContent for every other rule.
+func Scope(ruleJSON string) string {
+	var r Rule
+	if ruleJSON != "" && json.Unmarshal([]byte(ruleJSON), &r) == nil && r.Scope == ScopeDiff {
+		return ScopeDiff
+	}
+	return ScopeContent
+}
+
+// WithScope returns a content rule evaluated against scope, content or
+// diff.
+func WithScope(ruleJSON, scope string) (string, error) {
+	switch scope {
+	case ScopeContent, ScopeDiff:
+	default:
+		return "", fmt.Errorf("unknown trigger scope %q (valid: content, diff)", scope)
+	}
+	if ruleJSON == "" {
+		return "", fmt.Errorf("a trigger scope needs a trigger rule")
+	}
+	var r Rule
+	if err := json.Unmarshal([]byte(ruleJSON), &r); err != nil {
+		return "", fmt.Errorf("invalid trigger rule JSON: %w", err)
+	}
+	if r.Type == ResponseTimeRegression {
+		return "", fmt.Errorf("a response_time_regression rule doesn't look at content, so it has no scope")
+	}
+	r.Scope = ""
+	if scope == ScopeDiff {
+		r.Scope = ScopeDiff
+	}
+	b, _ := json.Marshal(r)
+	return string(b), nil
+}
+
+// RegressionMultiplier reports whether ruleJSON is a response_time_regression
+// rule, and the multiplier it sets; 0 means the configured default.
+func RegressionMultiplier(ruleJSON string) (multiplier float64, ok bool) {
+	var r Rule
+	if ruleJSON == "" || json.Unmarshal([]byte(ruleJSON), &r) != nil || r.Type != ResponseTimeRegression {
+		return 0, false
+	}
+	multiplier, _ = strconv.ParseFloat(r.Value, 64)
+	return multiplier, true
+}
+
+// Regressed reports whether current is more than multiplier times the
+// median of baseline, and that median. Against an empty baseline, or a
+// median of zero, nothing has regressed.
+func Regressed(current time.Duration, baseline []time.Duration, multiplier float64) (bool, time.Duration) {
+	if len(baseline) == 0 {
+		return false, 0
+	}
+	sorted := slices.Clone(baseline)
+	slices.Sort(sorted)
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + median) / 2
+	}
+	return median > 0 && float64(current) > multiplier*float64(median), median
+}
+
+// Pattern returns the rule's pattern as a regexp, regardless of whether the
+// rule fires on a match or on its absence. contains rules match literally.
+func Pattern(ruleJSON string) (*regexp.Regexp, error) {
+	var r Rule
+	if err := json.Unmarshal([]byte(ruleJSON), &r); err != nil {
+		return nil, fmt.Errorf("invalid trigger rule JSON: %w", err)
+	}
+	switch r.Type {
+	case "contains", "not_contains":
+		return regexp.MustCompile(regexp.QuoteMeta(r.Value)), nil
+	case "regex", "not_regex":
+		re, err := regexp.Compile(r.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		return re, nil
+	default:
+		return nil, fmt.Errorf("unknown trigger type: %s", r.Type)
+	}
+}
+
 // Describe returns a human-readable description of the trigger rule.
 func Describe(ruleJSON string) string {
 	if ruleJSON == "" {
@@ -89,6 +218,11 @@ func Describe(ruleJSON string) string {
 	if err := json.Unmarshal([]byte(ruleJSON), &r); err != nil {
 		return ruleJSON
 	}
+	if r.Scope == ScopeDiff {
+		r.Scope = ""
+		b, _ := json.Marshal(r)
+		return Describe(string(b)) + " in added lines"
+	}
 	switch r.Type {
 	case "contains":
 		return fmt.Sprintf("trigger if contains %q", r.Value)
@@ -98,6 +232,11 @@ func Describe(ruleJSON string) string {
 		return fmt.Sprintf("trigger if matches /%s/", r.Value)
 	case "not_regex":
 		return fmt.Sprintf("trigger if not matches /%s/", r.Value)
+	case ResponseTimeRegression:
+		if r.Value == "" {
+			return "trigger if slower than the configured multiple of the recent median"
+		}
+		return fmt.Sprintf("trigger if slower than %s× the recent median", r.Value)
 	default:
 		return ruleJSON
 	}
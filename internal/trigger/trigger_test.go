@@ -0,0 +1,81 @@
+package trigger
+
+import "testing"
+
+func TestWithScope(t *testing.T) {
+	contains := `{"type":"contains","value":"sale"}`
+	diffContains := `{"type":"contains","value":"sale","scope":"diff"}`
+	tests := []struct {
+		name    string
+		rule    string
+		scope   string
+		want    string
+		wantErr bool
+	}{
+		{"diff", contains, ScopeDiff, diffContains, false},
+		{"content drops the scope", diffContains, ScopeContent, contains, false},
+		{"diff again", diffContains, ScopeDiff, diffContains, false},
+		{"unknown scope", contains, "lines", "", true},
+		{"no rule", "", ScopeDiff, "", true},
+		{"invalid rule", "{", ScopeDiff, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := WithScope(tt.rule, tt.scope)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithScope(%q, %q) error = %v, want error %v", tt.rule, tt.scope, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("WithScope(%q, %q) = %s, want %s", tt.rule, tt.scope, got, tt.want)
+			}
+			if err == nil && Scope(got) != tt.scope {
+				t.Errorf("Scope(%s) = %q, want %q", got, Scope(got), tt.scope)
+			}
+		})
+	}
+}
+
+func TestScope(t *testing.T) {
+	tests := []struct{ rule, want string }{
+		{"", ScopeContent},
+		{"not json", ScopeContent},
+		{`{"type":"contains","value":"x"}`, ScopeContent},
+		{`{"type":"contains","value":"x","scope":"diff"}`, ScopeDiff},
+		{`{"type":"contains","value":"x","scope":"other"}`, ScopeContent},
+	}
+	for _, tt := range tests {
+		if got := Scope(tt.rule); got != tt.want {
+			t.Errorf("Scope(%q) = %q, want %q", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateChange(t *testing.T) {
+	contains := `{"type":"contains","value":"sale"}`
+	diffContains := `{"type":"contains","value":"sale","scope":"diff"}`
+	diffMissing := `{"type":"not_contains","value":"sale","scope":"diff"}`
+	tests := []struct {
+		name              string
+		rule              string
+		content, previous string
+		want              bool
+	}{
+		{"content rule sees old text", contains, "sale\nnew", "sale", true},
+		{"diff rule ignores old text", diffContains, "sale\nnew", "sale", false},
+		{"diff rule sees added text", diffContains, "old\nsale", "old", true},
+		{"diff rule without previous sees everything", diffContains, "sale", "", true},
+		{"negated diff rule", diffMissing, "sale\nnew", "sale", true},
+		{"no rule", "", "anything", "before", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EvaluateChange(tt.rule, tt.content, tt.previous)
+			if err != nil {
+				t.Fatalf("EvaluateChange: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("EvaluateChange(%s, %q, %q) = %v, want %v", tt.rule, tt.content, tt.previous, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package unixurl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scheme prefixes an http URL served over a unix domain socket:
+//
+//	http+unix:///var/run/app.sock:/health?verbose=1
+//
+// The socket path runs up to the first ':'; the rest is the request path
+// and query, defaulting to "/".
+const Scheme = "http+unix://"
+
+// Is reports whether raw is an http+unix URL.
+func Is(raw string) bool {
+	return strings.HasPrefix(strings.ToLower(raw), Scheme)
+}
+
+// Split returns the socket path and the request path of an http+unix URL.
+func Split(raw string) (socket, path string, err error) {
+	if !Is(raw) {
+		return "", "", fmt.Errorf("not an %s URL: %q", Scheme, raw)
+	}
+	rest := raw[len(Scheme):]
+	socket, path, _ = strings.Cut(rest, ":")
+	if socket == "" {
+		return "", "", fmt.Errorf("%s URL has no socket path (want e.g. %s/var/run/app.sock:/health)", Scheme, Scheme)
+	}
+	if !strings.HasPrefix(socket, "/") {
+		return "", "", fmt.Errorf("socket path %q must be absolute", socket)
+	}
+	if path == "" {
+		path = "/"
+	}
+	if !strings.HasPrefix(path, "/") {
+		return "", "", fmt.Errorf("request path %q must start with /", path)
+	}
+	return socket, path, nil
+}
+
+// RequestURL is the plain http URL sent over the socket. The host is a
+// placeholder; the socket decides where the request goes.
+func RequestURL(path string) string {
+	return "http://localhost" + path
+}
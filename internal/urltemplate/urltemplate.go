@@ -0,0 +1,94 @@
+package urltemplate
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Vars are the values available to a URL template at check time.
+type Vars struct {
+	Name string    // ${target.name}
+	ID   int64     // ${target.id}
+	Now  time.Time // ${now}, ${now:unix}, ${now:unixms}, ${now:<Go layout>}
+}
+
+var placeholder = regexp.MustCompile(`\$\{([^{}]*)\}`)
+
+// Has reports whether s contains any ${...} placeholder.
+func Has(s string) bool {
+	return strings.Contains(s, "${")
+}
+
+// Expand replaces every placeholder in tmpl. Substituted values are
+// escaped so they are safe in both the path and the query string.
+//
+//	${now}          current time, RFC3339 in UTC
+//	${now:unix}     Unix seconds
+//	${now:unixms}   Unix milliseconds
+//	${now:LAYOUT}   Go time layout in UTC, e.g. ${now:2006-01-02}
+//	${uuid}         random UUID (v4)
+//	${target.name}  the target's name
+//	${target.id}    the target's id
+func Expand(tmpl string, v Vars) (string, error) {
+	var firstErr error
+	out := placeholder.ReplaceAllStringFunc(tmpl, func(m string) string {
+		val, err := value(m[2:len(m)-1], v)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return escape(val)
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	if strings.Contains(out, "${") {
+		return "", fmt.Errorf("unterminated placeholder in %q", tmpl)
+	}
+	return out, nil
+}
+
+// Sample expands tmpl with placeholder values, for validating a template
+// before it is saved.
+func Sample(tmpl string) (string, error) {
+	return Expand(tmpl, Vars{Name: "target", ID: 1, Now: time.Now()})
+}
+
+func value(name string, v Vars) (string, error) {
+	now := v.Now.UTC()
+	switch {
+	case name == "now":
+		return now.Format(time.RFC3339), nil
+	case name == "now:unix":
+		return strconv.FormatInt(now.Unix(), 10), nil
+	case name == "now:unixms":
+		return strconv.FormatInt(now.UnixMilli(), 10), nil
+	case strings.HasPrefix(name, "now:") && len(name) > len("now:"):
+		return now.Format(name[len("now:"):]), nil
+	case name == "uuid":
+		return newUUID(), nil
+	case name == "target.name":
+		return v.Name, nil
+	case name == "target.id":
+		return strconv.FormatInt(v.ID, 10), nil
+	}
+	return "", fmt.Errorf("unknown placeholder ${%s} (want now, now:unix, now:unixms, now:<layout>, uuid, target.name or target.id)", name)
+}
+
+// escape percent-encodes a substituted value. PathEscape leaves '+' alone,
+// which a query string would read as a space.
+func escape(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), "+", "%2B")
+}
+
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,209 @@
+// Package watchdog runs upp's checks from Go code, without the CLI, its
+// config file or its database.
+//
+// A Checker takes a Target and returns a Result, just like upp check. What
+// the CLI reads from its database (the previous snapshot, result,
+// certificate and remote IP of a target) comes from the Checker's History, and what it
+// reads from config comes from its Settings:
+//
+//	c := &watchdog.Checker{History: watchdog.NewMemoryHistory()}
+//	t := &watchdog.Target{ID: 1, Name: "site", URL: "https://example.com", Type: "http", Timeout: 30}
+//	r := c.Check(ctx, t)
+//	fmt.Println(r.Status, r.ResponseTime)
+//
+// Targets use the same fields as the CLI, so every check type and option
+// upp supports works here too. Set ID to tell targets apart in the history.
+//
+// Transport options (connection pooling, proxy settings) are shared by
+// every check in the process, as in the CLI.
+package watchdog
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/naru-bot/upp/internal/checker"
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// Target is what to check and how; see the README for its fields.
+type Target = db.Target
+
+// Result is the outcome of one check.
+type Result = checker.Result
+
+// Snapshot is the stored content of a target at one check.
+type Snapshot = db.Snapshot
+
+// CheckResult is a result as stored in history.
+type CheckResult = db.CheckResult
+
+// History supplies the stored state a check compares against: content
+// checks report "changed" or "unchanged" against the latest snapshot,
+// certificate and IP changes are found against the last fingerprint and
+// remote IP, and a composite target rolls up the last results of its members. Methods
+// return nil or "" when nothing is stored.
+type History = checker.History
+
+// Settings are the check-wide options the CLI reads from config. Zero
+// fields use the built-in defaults.
+type Settings = checker.Settings
+
+// Recorder is a History that also stores results, so later checks compare
+// against them. A Checker records every result into a History that
+// implements it.
+type Recorder interface {
+	History
+	Record(target *Target, r *Result) error
+}
+
+// Checker runs checks. The zero value is ready to use: it checks with the
+// built-in defaults and no history, so every check is treated as the
+// target's first. A Checker is safe for concurrent use when its History is.
+type Checker struct {
+	History  History
+	Settings Settings
+}
+
+// Check runs a target's check, including its retries. Cancelling ctx
+// aborts it. When History is a Recorder, the result is recorded there,
+// with the Settings' snapshot mode filled in for a target without its
+// own; a failure to record is returned as the result's error only if the
+// check itself succeeded.
+func (c *Checker) Check(ctx context.Context, target *Target) *Result {
+	r := checker.Check(checker.WithEnv(ctx, c.History, c.Settings), target)
+	if rec, ok := c.History.(Recorder); ok {
+		recorded := *target
+		recorded.SnapshotMode = checker.SnapshotMode(target, c.Settings)
+		if err := rec.Record(&recorded, r); err != nil && r.Error == "" {
+			r.Error = "recording result: " + err.Error()
+		}
+	}
+	return r
+}
+
+// ContentSignature returns the fuzzy signature of content that
+// change_threshold compares, for a History that stores its own snapshots.
+func ContentSignature(content string) string {
+	return checker.ContentSignature(content)
+}
+
+// WantSnapshot reports whether r's content should become the target's new
+// snapshot under its snapshot_mode, given the latest snapshot (nil for
+// none), for a History that stores its own snapshots. A Checker records
+// targets with their snapshot mode already resolved from its Settings.
+func WantSnapshot(target *Target, r *Result, latest *Snapshot) bool {
+	return checker.WantSnapshot(target, r, latest, Settings{})
+}
+
+// MemoryHistory is a Recorder that keeps the latest snapshot and result of
+// each target in memory. It is safe for concurrent use.
+type MemoryHistory struct {
+	mu        sync.Mutex
+	targets   map[int64]Target
+	results   map[int64]CheckResult
+	snapshots map[int64]Snapshot
+	certs     map[int64]string
+	ips       map[int64]string
+}
+
+// NewMemoryHistory returns an empty MemoryHistory.
+func NewMemoryHistory() *MemoryHistory {
+	return &MemoryHistory{
+		targets:   make(map[int64]Target),
+		results:   make(map[int64]CheckResult),
+		snapshots: make(map[int64]Snapshot),
+		certs:     make(map[int64]string),
+		ips:       make(map[int64]string),
+	}
+}
+
+// Record stores r as the target's last result and, as the target's
+// snapshot mode asks (by default when its content changed), as the
+// target's latest snapshot.
+func (h *MemoryHistory) Record(target *Target, r *Result) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	h.targets[target.ID] = *target
+	rec := r.Record(target.ID)
+	rec.CheckedAt = now
+	h.results[target.ID] = *rec
+	if r.CertFingerprint != "" {
+		h.certs[target.ID] = r.CertFingerprint
+	}
+	if r.RemoteIP != "" {
+		h.ips[target.ID] = r.RemoteIP
+	}
+	var latest *Snapshot
+	if prev, ok := h.snapshots[target.ID]; ok {
+		latest = &prev
+	}
+	if checker.WantSnapshot(target, r, latest, Settings{}) {
+		h.snapshots[target.ID] = Snapshot{
+			TargetID:  target.ID,
+			Content:   r.Content,
+			Hash:      r.ContentHash,
+			Signature: checker.ContentSignature(r.Content),
+			CreatedAt: now,
+		}
+	}
+	return nil
+}
+
+// Add makes t known to the history without checking it, so a composite
+// target can list it as a member.
+func (h *MemoryHistory) Add(t *Target) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.targets[t.ID] = *t
+}
+
+// LatestSnapshot returns the target's latest snapshot, or nil.
+func (h *MemoryHistory) LatestSnapshot(targetID int64) (*Snapshot, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.snapshots[targetID]
+	if !ok {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+// LastResult returns the target's last result, or nil.
+func (h *MemoryHistory) LastResult(targetID int64) (*CheckResult, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r, ok := h.results[targetID]
+	if !ok {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+// LastCertFingerprint returns the last certificate fingerprint recorded
+// for the target, or "".
+func (h *MemoryHistory) LastCertFingerprint(targetID int64) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.certs[targetID], nil
+}
+
+// LastRemoteIP returns the last remote IP recorded for the target, or "".
+func (h *MemoryHistory) LastRemoteIP(targetID int64) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.ips[targetID], nil
+}
+
+// Targets returns every target recorded or added.
+func (h *MemoryHistory) Targets() ([]Target, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	targets := make([]Target, 0, len(h.targets))
+	for _, t := range h.targets {
+		targets = append(targets, t)
+	}
+	return targets, nil
+}